@@ -0,0 +1,249 @@
+// 管理员侧账号管理接口（员工账号增改查、禁用、重置密码、修改自己的密码）。
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"gamesocial/modules/admin"
+)
+
+// AdminAdminCreate 创建员工账号。
+// POST /admin/admins
+func AdminAdminCreate(svc admin.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1) 方法校验。
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		// 2) 依赖校验。
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+		operatorID := adminIDFromRequest(r)
+		if operatorID == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		// 3) 解析请求体。
+		var req admin.CreateAdminRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			SendJBizFail(w, "参数格式错误")
+			return
+		}
+
+		// 4) 调用业务层创建并返回详情。
+		out, err := svc.Create(r.Context(), operatorID, req)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
+
+// AdminAdminList 管理员列表。
+// GET /admin/admins?offset=0&limit=20&status=1
+func AdminAdminList(svc admin.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		q := r.URL.Query()
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		status, _ := strconv.Atoi(q.Get("status"))
+
+		out, err := svc.List(r.Context(), admin.ListAdminRequest{
+			Offset: offset,
+			Limit:  limit,
+			Status: status,
+		})
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
+
+// AdminAdminGet 获取管理员详情。
+// GET /admin/admins/{id}
+func AdminAdminGet(svc admin.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil || id == 0 {
+			SendJBizFail(w, "id 不合法")
+			return
+		}
+		out, err := svc.Get(r.Context(), id)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
+
+// AdminAdminUpdate 修改管理员角色/状态（status=0 禁用并立即吊销其会话）。
+// PUT /admin/admins/{id}
+func AdminAdminUpdate(svc admin.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+		operatorID := adminIDFromRequest(r)
+		if operatorID == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil || id == 0 {
+			SendJBizFail(w, "id 不合法")
+			return
+		}
+		var req admin.UpdateAdminRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			SendJBizFail(w, "参数格式错误")
+			return
+		}
+
+		out, err := svc.Update(r.Context(), operatorID, id, req)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
+
+// AdminAdminDisable 禁用管理员账号（软删除，保留审计关联）。
+// DELETE /admin/admins/{id}
+func AdminAdminDisable(svc admin.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+		operatorID := adminIDFromRequest(r)
+		if operatorID == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil || id == 0 {
+			SendJBizFail(w, "id 不合法")
+			return
+		}
+		status := 0
+		if _, err := svc.Update(r.Context(), operatorID, id, admin.UpdateAdminRequest{Status: &status}); err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, map[string]any{"disabled": true})
+	}
+}
+
+// AdminAdminResetPassword 强制重置他人密码（对方全部会话失效）。
+// PUT /admin/admins/{id}/password
+func AdminAdminResetPassword(svc admin.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+		operatorID := adminIDFromRequest(r)
+		if operatorID == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil || id == 0 {
+			SendJBizFail(w, "id 不合法")
+			return
+		}
+		var req struct {
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			SendJBizFail(w, "参数格式错误")
+			return
+		}
+
+		if err := svc.ResetPassword(r.Context(), operatorID, id, req.Password); err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, map[string]any{"reset": true})
+	}
+}
+
+// AdminAdminChangeOwnPassword 修改自己的密码（保留当前会话，其他设备需重新登录）。
+// PUT /admin/admins/me/password
+func AdminAdminChangeOwnPassword(svc admin.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+		adminID := adminIDFromRequest(r)
+		if adminID == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		var req struct {
+			OldPassword string `json:"oldPassword"`
+			NewPassword string `json:"newPassword"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			SendJBizFail(w, "参数格式错误")
+			return
+		}
+
+		if err := svc.ChangePassword(r.Context(), adminID, r.Header.Get("X-Admin-Session"), req.OldPassword, req.NewPassword); err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, map[string]any{"changed": true})
+	}
+}
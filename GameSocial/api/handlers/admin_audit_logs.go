@@ -2,15 +2,24 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// auditExportMaxDays 限制单次导出的时间跨度，避免一次扫描过多数据。
+const auditExportMaxDays = 93
+
 // AdminAuditLogItem 表示管理员审计日志列表项。
 type AdminAuditLogItem struct {
-	ID         uint64          `json:"id"`
+	ID uint64 `json:"id"`
+	// AdminID 为 0 表示非管理员操作（如登录限流拒绝）。
 	AdminID    uint64          `json:"adminId"`
 	Action     string          `json:"action"`
 	BizType    string          `json:"bizType"`
@@ -19,8 +28,107 @@ type AdminAuditLogItem struct {
 	CreatedAt  time.Time       `json:"createdAt"`
 }
 
+// auditLogFilter 是审计日志查询/导出共用的筛选条件。
+type auditLogFilter struct {
+	AdminID uint64
+	Action  string
+	BizType string
+	BizID   string
+	From    time.Time
+	To      time.Time
+	Keyword string
+}
+
+// parseAuditLogFilter 解析 query 中的筛选条件。
+// from/to 支持 RFC3339 或 2006-01-02；仅日期的 to 表示包含当天（内部转为次日 0 点，区间左闭右开）。
+func parseAuditLogFilter(q url.Values) (auditLogFilter, error) {
+	f := auditLogFilter{
+		AdminID: parseUint64(q.Get("adminId")),
+		Action:  strings.ToUpper(strings.TrimSpace(q.Get("action"))),
+		BizType: strings.ToUpper(strings.TrimSpace(q.Get("bizType"))),
+		BizID:   strings.TrimSpace(q.Get("bizId")),
+		Keyword: strings.TrimSpace(q.Get("q")),
+	}
+	if f.BizID != "" && f.BizType == "" {
+		return f, errors.New("bizId 需与 bizType 一起使用")
+	}
+	if v := strings.TrimSpace(q.Get("from")); v != "" {
+		tm, _, err := parseAuditTime(v)
+		if err != nil {
+			return f, errors.New("from 时间格式错误")
+		}
+		f.From = tm
+	}
+	if v := strings.TrimSpace(q.Get("to")); v != "" {
+		tm, dateOnly, err := parseAuditTime(v)
+		if err != nil {
+			return f, errors.New("to 时间格式错误")
+		}
+		if dateOnly {
+			tm = tm.AddDate(0, 0, 1)
+		}
+		f.To = tm
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return f, errors.New("from 必须早于 to")
+	}
+	return f, nil
+}
+
+func parseAuditTime(v string) (time.Time, bool, error) {
+	if tm, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return tm, true, nil
+	}
+	tm, err := time.Parse(time.RFC3339, v)
+	return tm, false, err
+}
+
+// where 组装 WHERE 子句：action 与 biz_type+biz_id 使用等值条件，以命中
+// idx_admin_audit_log_action / idx_admin_audit_log_biz；关键字在 detail_json 文本中模糊匹配。
+func (f auditLogFilter) where() (string, []any) {
+	conds := make([]string, 0, 7)
+	args := make([]any, 0, 7)
+	if f.AdminID != 0 {
+		conds = append(conds, "admin_id = ?")
+		args = append(args, f.AdminID)
+	}
+	if f.Action != "" {
+		conds = append(conds, "action = ?")
+		args = append(args, f.Action)
+	}
+	if f.BizType != "" {
+		conds = append(conds, "biz_type = ?")
+		args = append(args, f.BizType)
+		if f.BizID != "" {
+			conds = append(conds, "biz_id = ?")
+			args = append(args, f.BizID)
+		}
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, f.To)
+	}
+	if f.Keyword != "" {
+		conds = append(conds, "CAST(detail_json AS CHAR) LIKE ?")
+		args = append(args, "%"+escapeLike(f.Keyword)+"%")
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// escapeLike 转义 LIKE 通配符，使关键字按字面匹配。
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
 // AdminAuditLogs 查询管理员审计日志。
-// GET /admin/audit/logs
+// GET /admin/audit/logs?offset=0&limit=20&adminId=1&action=REDEEM_USE&bizType=REDEEM_ORDER&bizId=12&from=2026-01-01&to=2026-01-31&q=keyword
 func AdminAuditLogs(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -46,17 +154,16 @@ func AdminAuditLogs(db *sql.DB) http.HandlerFunc {
 			offset = 0
 		}
 
-		adminID := parseUint64(q.Get("adminId"))
-		where := ""
-		args := make([]any, 0, 4)
-		if adminID != 0 {
-			where = "WHERE admin_id = ?"
-			args = append(args, adminID)
+		filter, err := parseAuditLogFilter(q)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
 		}
+		where, args := filter.where()
 		args = append(args, limit, offset)
 
 		rows, err := db.QueryContext(r.Context(), `
-			SELECT id, admin_id, action, IFNULL(biz_type, ''), IFNULL(biz_id, ''), IFNULL(detail_json, JSON_OBJECT()), created_at
+			SELECT id, IFNULL(admin_id, 0), action, IFNULL(biz_type, ''), IFNULL(biz_id, ''), IFNULL(detail_json, JSON_OBJECT()), created_at
 			FROM admin_audit_log
 			`+where+`
 			ORDER BY id DESC
@@ -89,3 +196,104 @@ func AdminAuditLogs(db *sql.DB) http.HandlerFunc {
 		SendJSuccess(w, out)
 	}
 }
+
+// AdminAuditLogsExport 按时间范围流式导出审计日志 CSV（其余筛选条件与列表接口一致）。
+// GET /admin/audit/logs/export?from=2026-01-01&to=2026-01-31&action=REDEEM_USE
+func AdminAuditLogsExport(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1) 方法与依赖校验。
+		if r.Method != http.MethodGet {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if db == nil {
+			SendJBizFail(w, "database disabled")
+			return
+		}
+
+		// 2) 解析筛选条件：导出必须限定时间范围。
+		filter, err := parseAuditLogFilter(r.URL.Query())
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		if filter.From.IsZero() || filter.To.IsZero() {
+			SendJBizFail(w, "导出需指定 from 与 to")
+			return
+		}
+		if filter.To.Sub(filter.From) > auditExportMaxDays*24*time.Hour {
+			SendJBizFail(w, "导出时间跨度不能超过 93 天")
+			return
+		}
+		where, args := filter.where()
+
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT id, IFNULL(admin_id, 0), action, IFNULL(biz_type, ''), IFNULL(biz_id, ''), IFNULL(detail_json, JSON_OBJECT()), created_at
+			FROM admin_audit_log
+			`+where+`
+			ORDER BY id ASC
+		`, args...)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		defer rows.Close()
+
+		// 3) 边查边写：大范围导出可能超过 Server 的 WriteTimeout，这里单独放宽写超时。
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(5 * time.Minute))
+		filename := "audit_logs_" + filter.From.Format("20060102") + "_" + filter.To.AddDate(0, 0, -1).Format("20060102") + ".csv"
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.WriteHeader(http.StatusOK)
+		// UTF-8 BOM：便于 Excel 正确识别中文。
+		_, _ = w.Write([]byte("\xEF\xBB\xBF"))
+
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"id", "createdAt", "adminId", "action", "bizType", "bizId", "detailJson"})
+		n := 0
+		var scanErr error
+		for rows.Next() {
+			var (
+				id, adminID            uint64
+				action, bizType, bizID string
+				detailBytes            []byte
+				createdAt              time.Time
+			)
+			if err := rows.Scan(&id, &adminID, &action, &bizType, &bizID, &detailBytes, &createdAt); err != nil {
+				scanErr = err
+				break
+			}
+			_ = cw.Write([]string{
+				strconv.FormatUint(id, 10),
+				createdAt.Format(time.RFC3339),
+				strconv.FormatUint(adminID, 10),
+				csvSafe(action),
+				csvSafe(bizType),
+				csvSafe(bizID),
+				csvSafe(string(detailBytes)),
+			})
+			n++
+			if n%500 == 0 {
+				cw.Flush()
+				_ = http.NewResponseController(w).Flush()
+			}
+		}
+		// 4) 查询中途失败：响应头已写出无法改状态码，记日志并在末尾写 #ERROR 行，避免截断的文件被当作完整导出。
+		if scanErr == nil {
+			scanErr = rows.Err()
+		}
+		if scanErr != nil {
+			log.Printf("audit logs export: aborted after %d rows: %v", n, scanErr)
+			_ = cw.Write([]string{"#ERROR", "导出中断，文件不完整（已导出 " + strconv.Itoa(n) + " 行），请重新导出"})
+		}
+		cw.Flush()
+	}
+}
+
+// csvSafe 为以公式字符开头的单元格加前缀 '，防止在表格软件中被当作公式执行。
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+// 管理员侧登录/登出/当前管理员信息接口。
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"gamesocial/modules/admin"
+)
+
+// AdminAuthLogin 管理员登录：校验用户名密码并签发管理员 token。
+// 按 IP/用户名限流；同一用户名密码连续错误达到阈值后临时锁定。
+// POST /admin/auth/login
+func AdminAuthLogin(svc admin.Service, throttle *LoginThrottle) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1) 方法校验。
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		// 2) 依赖校验。
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		// 3) 解析请求体。
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			SendJBizFail(w, "参数格式错误")
+			return
+		}
+
+		// 4) 限流与锁定校验（用户名不区分大小写，避免换大小写绕过锁定）。
+		identity := strings.ToLower(strings.TrimSpace(req.Username))
+		if !throttle.allow(w, r, "ADMIN_LOGIN", identity) {
+			return
+		}
+		if throttle.locked(w, r, "ADMIN_LOGIN", identity) {
+			return
+		}
+
+		// 5) 调用业务层登录：仅账号/密码错误计入失败次数。
+		out, err := svc.Login(r.Context(), req.Username, req.Password)
+		if err != nil {
+			if errors.Is(err, admin.ErrInvalidCredentials) {
+				throttle.fail(r, "ADMIN_LOGIN", identity)
+			}
+			SendJBizFail(w, err.Error())
+			return
+		}
+		throttle.succeed(r, "ADMIN_LOGIN", identity)
+		SendJSuccess(w, out)
+	}
+}
+
+// AdminAuthMe 获取当前登录管理员信息。
+// GET /admin/auth/me
+func AdminAuthMe(svc admin.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		adminID := adminIDFromRequest(r)
+		if adminID == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+		out, err := svc.Get(r.Context(), adminID)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
+
+// AdminAuthLogout 管理员登出：吊销当前 token 对应的服务端会话。
+// POST /admin/auth/logout
+func AdminAuthLogout(svc admin.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		sessionID := r.Header.Get("X-Admin-Session")
+		if sessionID == "" {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+		if err := svc.Logout(r.Context(), sessionID); err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, map[string]any{"logout": true})
+	}
+}
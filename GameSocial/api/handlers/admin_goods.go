@@ -38,6 +38,14 @@ func AdminGoodsCreate(svc item.Service, store media.ServerStore, maxUploadBytes
 			req.PointsPrice, _ = strconv.ParseInt(strings.TrimSpace(r.FormValue("pointsPrice")), 10, 64)
 			req.Stock, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("stock")))
 			req.Status, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("status")))
+			if v := strings.TrimSpace(r.FormValue("pickupWindowHours")); v != "" {
+				hours, err := strconv.Atoi(v)
+				if err != nil {
+					SendJBizFail(w, "参数格式错误")
+					return
+				}
+				req.PickupWindowHours = &hours
+			}
 
 			if r.MultipartForm != nil && (len(r.MultipartForm.File["file"])+len(r.MultipartForm.File["files"]) > 0) {
 				outs, err := uploadImagesToStore(r, store, maxUploadBytes)
@@ -150,6 +158,14 @@ func AdminGoodsUpdate(svc item.Service, store media.ServerStore, maxUploadBytes
 			req.PointsPrice, _ = strconv.ParseInt(strings.TrimSpace(r.FormValue("pointsPrice")), 10, 64)
 			req.Stock, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("stock")))
 			req.Status, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("status")))
+			if v := strings.TrimSpace(r.FormValue("pickupWindowHours")); v != "" {
+				hours, err := strconv.Atoi(v)
+				if err != nil {
+					SendJBizFail(w, "参数格式错误")
+					return
+				}
+				req.PickupWindowHours = &hours
+			}
 
 			if r.MultipartForm != nil && (len(r.MultipartForm.File["file"])+len(r.MultipartForm.File["files"]) > 0) {
 				outs, err := uploadImagesToStore(r, store, maxUploadBytes)
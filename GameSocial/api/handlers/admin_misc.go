@@ -14,59 +14,6 @@ import (
 	"gamesocial/internal/media"
 )
 
-// AdminAuthLogin 管理员登录占位接口。
-// POST /admin/auth/login
-func AdminAuthLogin() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
-			return
-		}
-		SendJSuccess(w, map[string]any{
-			"token": "admin_token_placeholder",
-		})
-	}
-}
-
-// AdminAuthMe 管理员信息占位接口。
-// GET /admin/auth/me
-func AdminAuthMe() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
-			return
-		}
-		SendJSuccess(w, map[string]any{
-			"id":       1,
-			"username": "admin",
-		})
-	}
-}
-
-// AdminAuthLogout 管理员登出占位接口。
-// POST /admin/auth/logout
-func AdminAuthLogout() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
-			return
-		}
-		SendJSuccess(w, map[string]any{"logout": true})
-	}
-}
-
-// AdminPointsAdjust 积分调整占位接口。
-// POST /admin/points/adjust
-func AdminPointsAdjust() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
-			return
-		}
-		SendJSuccess(w, map[string]any{"adjusted": true})
-	}
-}
-
 // AdminUsersDrinksUse 消费饮品占位接口。
 // PUT /admin/users/{id}/drinks/use
 func AdminUsersDrinksUse() http.HandlerFunc {
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gamesocial/modules/notify"
+)
+
+// AdminNotifyDeliveries 订阅消息投递日志（按 id 倒序）。
+// GET /admin/notify/deliveries?offset=0&limit=20&status=FAILED&event=TOURNAMENT_START&userId=1001
+func AdminNotifyDeliveries(svc notify.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1) 方法校验。
+		if r.Method != http.MethodGet {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		// 2) 依赖校验。
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		// 3) 解析 query：分页 + 状态/事件/用户筛选。
+		q := r.URL.Query()
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		userID, _ := strconv.ParseUint(q.Get("userId"), 10, 64)
+
+		// 4) 查询并返回。
+		out, err := svc.ListDeliveries(r.Context(), notify.ListDeliveryRequest{
+			Offset: offset,
+			Limit:  limit,
+			UserID: userID,
+			Event:  q.Get("event"),
+			Status: q.Get("status"),
+		})
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
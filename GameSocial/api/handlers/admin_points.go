@@ -0,0 +1,134 @@
+// 管理员侧积分调整接口（发起调整、待审批列表、审批/驳回）。
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"gamesocial/modules/points"
+)
+
+// AdminPointsAdjust 管理员调整用户积分（超过审批阈值时生成待审批单）。
+// POST /admin/points/adjust
+func AdminPointsAdjust(svc points.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1) 方法校验。
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		// 2) 依赖校验。
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+		adminID := adminIDFromRequest(r)
+		if adminID == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		// 3) 解析请求体：userId/amount/reasonCode/reasonNote/idempotencyKey。
+		var req points.AdjustRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			SendJBizFail(w, "参数格式错误")
+			return
+		}
+
+		// 4) 调用业务层：入账或生成待审批单，并写审计日志。
+		out, err := svc.Adjust(r.Context(), adminID, req)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
+
+// AdminPointsAdjustmentList 积分调整单列表。
+// GET /admin/points/adjustments?offset=0&limit=20&status=PENDING&userId=1001
+func AdminPointsAdjustmentList(svc points.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		q := r.URL.Query()
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		out, err := svc.ListAdjustments(r.Context(), points.ListAdjustmentRequest{
+			Offset: offset,
+			Limit:  limit,
+			Status: q.Get("status"),
+			UserID: parseUint64(q.Get("userId")),
+		})
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
+
+// AdminPointsAdjustmentApprove 审批通过积分调整单并入账（审批人不能是发起人）。
+// POST /admin/points/adjustments/{id}/approve
+func AdminPointsAdjustmentApprove(svc points.Service) http.HandlerFunc {
+	return adminPointsAdjustmentReview(svc, true)
+}
+
+// AdminPointsAdjustmentReject 驳回积分调整单（不入账）。
+// POST /admin/points/adjustments/{id}/reject
+func AdminPointsAdjustmentReject(svc points.Service) http.HandlerFunc {
+	return adminPointsAdjustmentReview(svc, false)
+}
+
+func adminPointsAdjustmentReview(svc points.Service, approve bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1) 方法校验。
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		// 2) 依赖校验。
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+		adminID := adminIDFromRequest(r)
+		if adminID == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		// 3) 解析 id 与可选的审批意见。
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil || id == 0 {
+			SendJBizFail(w, "id 不合法")
+			return
+		}
+		var body struct {
+			Note string `json:"note"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		// 4) 调用业务层：审批入账或驳回，并写审计日志。
+		var out points.Adjustment
+		if approve {
+			out, err = svc.ApproveAdjustment(r.Context(), adminID, id, body.Note)
+		} else {
+			out, err = svc.RejectAdjustment(r.Context(), adminID, id, body.Note)
+		}
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"gamesocial/modules/redeem"
 )
 
-// AdminRedeemOrderCreate 创建兑换订单（最小 CRUD：不接入积分扣减流水）。
+// AdminRedeemOrderCreate 创建兑换订单（同一事务内扣减用户积分并写流水）。
 // POST /admin/redeem/orders
 func AdminRedeemOrderCreate(svc redeem.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -31,7 +31,7 @@ func AdminRedeemOrderCreate(svc redeem.Service) http.HandlerFunc {
 			return
 		}
 
-		// 4) 调用业务层：写入 redeem_order 与 redeem_order_item。
+		// 4) 调用业务层：写入 redeem_order 与 redeem_order_item，并扣减积分。
 		out, err := svc.CreateOrder(r.Context(), req)
 		if err != nil {
 			SendJBizFail(w, err.Error())
@@ -130,7 +130,14 @@ func AdminRedeemOrderUse(svc redeem.Service) http.HandlerFunc {
 			return
 		}
 
-		// 3) 解析 id。
+		// 3) 核销人取当前登录管理员（由 AdminAuth 注入），不再信任请求体。
+		adminID := adminIDFromRequest(r)
+		if adminID == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		// 4) 解析 id。
 		idRaw := r.PathValue("id")
 		id, err := strconv.ParseUint(idRaw, 10, 64)
 		if err != nil || id == 0 {
@@ -138,18 +145,7 @@ func AdminRedeemOrderUse(svc redeem.Service) http.HandlerFunc {
 			return
 		}
 
-		// 4) 解析请求体（可选：admin_id；未提供则默认 1）。
-		var body struct {
-			AdminID       uint64 `json:"adminId"`
-			AdminIDLegacy uint64 `json:"admin_id"`
-		}
-		_ = json.NewDecoder(r.Body).Decode(&body)
-
 		// 5) 条件更新：只有 CREATED 能变更为 USED。
-		adminID := body.AdminID
-		if adminID == 0 {
-			adminID = body.AdminIDLegacy
-		}
 		out, err := svc.UseOrder(r.Context(), id, adminID)
 		if err != nil {
 			SendJBizFail(w, err.Error())
@@ -191,3 +187,68 @@ func AdminRedeemOrderCancel(svc redeem.Service) http.HandlerFunc {
 		SendJSuccess(w, out)
 	}
 }
+
+// AdminRedeemScan 扫描玩家出示的取货二维码核销兑换订单（CREATED -> USED），核销人为当前管理员。
+// POST /admin/redeem/scan
+func AdminRedeemScan(svc redeem.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1) 方法校验。
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		// 2) 依赖校验。
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+		adminID := adminIDFromRequest(r)
+		if adminID == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		// 3) 解析请求体。
+		var body struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			SendJBizFail(w, "参数格式错误")
+			return
+		}
+
+		// 4) 校验二维码并核销订单。
+		out, err := svc.UseOrderByQR(r.Context(), body.Token, adminID)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
+
+// AdminRedeemExpiredSummary 按天汇总超时未取货被自动关闭的订单（订单数/用户数/退回积分/各商品回补数量）。
+// GET /admin/redeem/expired-summary?startDate=2026-01-01&endDate=2026-01-07
+func AdminRedeemExpiredSummary(svc redeem.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1) 方法校验。
+		if r.Method != http.MethodGet {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		// 2) 依赖校验。
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		// 3) 查询汇总（日期为空默认最近 7 天）。
+		q := r.URL.Query()
+		out, err := svc.ExpiredSummary(r.Context(), q.Get("startDate"), q.Get("endDate"))
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
@@ -43,7 +43,7 @@ func AdminUserGet(svc user.Service) http.HandlerFunc {
 }
 
 // AdminUserList 用户列表。
-// GET /admin/users?offset=0&limit=20&status=1
+// GET /admin/users?offset=0&limit=20&status=1&phone=13800138000
 func AdminUserList(svc user.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 1) 方法校验。
@@ -63,11 +63,12 @@ func AdminUserList(svc user.Service) http.HandlerFunc {
 		limit, _ := strconv.Atoi(q.Get("limit"))
 		status, _ := strconv.Atoi(q.Get("status"))
 
-		// 4) 调用业务层查询列表。
+		// 4) 调用业务层查询列表（phone 为完整号码精确查找）。
 		out, err := svc.List(r.Context(), user.ListUserRequest{
 			Offset: offset,
 			Limit:  limit,
 			Status: status,
+			Phone:  q.Get("phone"),
 		})
 		if err != nil {
 			SendJBizFail(w, err.Error())
@@ -26,3 +26,10 @@ func userIDFromRequest(r *http.Request) uint64 {
 	}
 	return 0
 }
+
+func adminIDFromRequest(r *http.Request) uint64 {
+	if r == nil {
+		return 0
+	}
+	return parseUint64(r.Header.Get("X-Admin-Id"))
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"gamesocial/modules/notify"
+)
+
+// AppNotifyTemplates 返回可订阅的消息模板及当前用户剩余可接收次数。
+// GET /api/notify/templates
+func AppNotifyTemplates(svc notify.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		uid := userIDFromRequest(r)
+		if uid == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		out, err := svc.Templates(r.Context(), uid)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
+
+// AppNotifySubscriptionsReport 上报 wx.requestSubscribeMessage 的授权结果；每个 accept 增加一次可接收次数。
+// POST /api/notify/subscriptions
+func AppNotifySubscriptionsReport(svc notify.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		uid := userIDFromRequest(r)
+		if uid == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		// results 与小程序 success 回调的 res 一致：{"<templateId>": "accept" | "reject" | "ban" | "filter"}。
+		var req struct {
+			Results map[string]string `json:"results"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			SendJBizFail(w, "参数格式错误")
+			return
+		}
+		if len(req.Results) == 0 {
+			SendJBizFail(w, "results 不能为空")
+			return
+		}
+
+		out, err := svc.Grant(r.Context(), uid, req.Results)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
@@ -1,34 +1,25 @@
 package handlers
 
 import (
-	"database/sql"
+	"encoding/json"
 	"net/http"
 	"strconv"
-	"time"
-)
+	"strings"
 
-// PointsLedgerItem 表示积分流水列表项。
-type PointsLedgerItem struct {
-	ID           uint64    `json:"id"`
-	ChangeAmount int64     `json:"changeAmount"`
-	BalanceAfter int64     `json:"balanceAfter"`
-	BizType      string    `json:"bizType"`
-	BizID        string    `json:"bizId"`
-	Remark       string    `json:"remark"`
-	CreatedAt    time.Time `json:"createdAt"`
-}
+	"gamesocial/modules/points"
+)
 
 // AppPointsBalance 查询用户积分余额。
 // GET /api/points/balance
-func AppPointsBalance(db *sql.DB) http.HandlerFunc {
+func AppPointsBalance(svc points.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
 			return
 		}
 
-		if db == nil {
-			SendJBizFail(w, "database disabled")
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
 			return
 		}
 
@@ -38,35 +29,34 @@ func AppPointsBalance(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		var balance int64
-		err := db.QueryRowContext(r.Context(), `
-			SELECT balance
-			FROM points_account
-			WHERE user_id = ?
-			LIMIT 1
-		`, uid).Scan(&balance)
-		if err != nil && err != sql.ErrNoRows {
+		account, err := svc.Get(r.Context(), uid)
+		if err != nil {
 			SendJBizFail(w, err.Error())
 			return
 		}
 
-		SendJSuccess(w, map[string]any{
-			"balance": balance,
-		})
+		out := map[string]any{
+			"balance":        account.Balance,
+			"expiringPoints": account.ExpiringPoints,
+		}
+		if account.ExpiringDate != "" {
+			out["expiringDate"] = account.ExpiringDate
+		}
+		SendJSuccess(w, out)
 	}
 }
 
 // AppPointsLedgers 查询用户积分流水。
-// GET /api/points/ledgers
-func AppPointsLedgers(db *sql.DB) http.HandlerFunc {
+// GET /api/points/ledgers?bizType=CHECKIN,REDEEM&startDate=2026-03-01&endDate=2026-03-31&direction=spend&cursor=&limit=20
+func AppPointsLedgers(svc points.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
 			return
 		}
 
-		if db == nil {
-			SendJBizFail(w, "database disabled")
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
 			return
 		}
 
@@ -76,46 +66,96 @@ func AppPointsLedgers(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// bizType 支持逗号分隔多个值。
 		q := r.URL.Query()
 		offset, _ := strconv.Atoi(q.Get("offset"))
 		limit, _ := strconv.Atoi(q.Get("limit"))
-		if limit <= 0 {
-			limit = 20
+		var bizTypes []string
+		for _, bt := range strings.Split(q.Get("bizType"), ",") {
+			if bt = strings.ToUpper(strings.TrimSpace(bt)); bt != "" {
+				bizTypes = append(bizTypes, bt)
+			}
+		}
+
+		out, err := svc.ListLedgers(r.Context(), uid, points.LedgerQuery{
+			BizTypes:  bizTypes,
+			StartDate: strings.TrimSpace(q.Get("startDate")),
+			EndDate:   strings.TrimSpace(q.Get("endDate")),
+			Direction: strings.ToLower(strings.TrimSpace(q.Get("direction"))),
+			Cursor:    strings.TrimSpace(q.Get("cursor")),
+			Offset:    offset,
+			Limit:     limit,
+		})
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
 		}
-		if limit > 200 {
-			limit = 200
+		SendJSuccess(w, out)
+	}
+}
+
+// AppPointsSummary 查询用户某月积分汇总（按业务类型统计获得/消耗）。
+// GET /api/points/summary?month=2026-03
+func AppPointsSummary(svc points.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
 		}
-		if offset < 0 {
-			offset = 0
+
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
 		}
 
-		rows, err := db.QueryContext(r.Context(), `
-			SELECT id, change_amount, balance_after, biz_type, biz_id, IFNULL(remark, ''), created_at
-			FROM points_ledger
-			WHERE user_id = ?
-			ORDER BY id DESC
-			LIMIT ? OFFSET ?
-		`, uid, limit, offset)
+		uid := userIDFromRequest(r)
+		if uid == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		out, err := svc.MonthlySummary(r.Context(), uid, strings.TrimSpace(r.URL.Query().Get("month")))
 		if err != nil {
 			SendJBizFail(w, err.Error())
 			return
 		}
-		defer rows.Close()
+		SendJSuccess(w, out)
+	}
+}
 
-		out := make([]PointsLedgerItem, 0, limit)
-		for rows.Next() {
-			var it PointsLedgerItem
-			if err := rows.Scan(&it.ID, &it.ChangeAmount, &it.BalanceAfter, &it.BizType, &it.BizID, &it.Remark, &it.CreatedAt); err != nil {
-				SendJBizFail(w, err.Error())
-				return
-			}
-			out = append(out, it)
+// AppPointsTransfer 赠送积分给其他用户。
+// POST /api/points/transfers
+func AppPointsTransfer(svc points.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1) 方法校验。
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
 		}
-		if err := rows.Err(); err != nil {
-			SendJBizFail(w, err.Error())
+		// 2) 依赖与登录校验。
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+		uid := userIDFromRequest(r)
+		if uid == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		// 3) 解析请求体：toUserId/amount/message。
+		var req points.TransferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			SendJBizFail(w, "参数格式错误")
 			return
 		}
 
+		// 4) 调用业务层：校验限制后在一个事务内完成扣减与入账。
+		out, err := svc.Transfer(r.Context(), uid, req)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
 		SendJSuccess(w, out)
 	}
 }
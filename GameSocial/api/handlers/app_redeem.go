@@ -155,3 +155,36 @@ func AppRedeemOrderCancel(svc redeem.Service) http.HandlerFunc {
 		SendJSuccess(w, out)
 	}
 }
+
+// AppRedeemOrderPickupQR 为自己的待取货订单生成一次性取货二维码（到店出示给店员扫码核销）。
+// POST /api/redeem/orders/{id}/pickup-qr
+func AppRedeemOrderPickupQR(svc redeem.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		uid := userIDFromRequest(r)
+		if uid == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		id := parseUint64(r.PathValue("id"))
+		if id == 0 {
+			SendJBizFail(w, "id 不合法")
+			return
+		}
+		out, err := svc.CreatePickupQR(r.Context(), id, uid)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, out)
+	}
+}
@@ -37,19 +37,28 @@ func AppUserMeGet(svc user.Service) http.HandlerFunc {
 			SendJBizFail(w, err.Error())
 			return
 		}
-		SendJSuccess(w, struct {
-			Nickname  string `json:"nickname"`
-			AvatarURL string `json:"avatarUrl"`
-			Level     int    `json:"level"`
-			Exp       int64  `json:"exp"`
-			CreatedAt string `json:"createdAt"`
-		}{
-			Nickname:  out.Nickname,
-			AvatarURL: out.AvatarURL,
-			Level:     out.Level,
-			Exp:       out.Exp,
-			CreatedAt: out.CreatedAt.Format(time.RFC3339),
-		})
+		SendJSuccess(w, appUserMe(out))
+	}
+}
+
+// appUserMe 是 /api/users/me 系列接口的响应结构（手机号只返回脱敏值）。
+func appUserMe(u user.User) any {
+	return struct {
+		Nickname   string `json:"nickname"`
+		AvatarURL  string `json:"avatarUrl"`
+		Level      int    `json:"level"`
+		Exp        int64  `json:"exp"`
+		Phone      string `json:"phone"`
+		PhoneBound bool   `json:"phoneBound"`
+		CreatedAt  string `json:"createdAt"`
+	}{
+		Nickname:   u.Nickname,
+		AvatarURL:  u.AvatarURL,
+		Level:      u.Level,
+		Exp:        u.Exp,
+		Phone:      u.Phone,
+		PhoneBound: u.PhoneBoundAt != nil,
+		CreatedAt:  u.CreatedAt.Format(time.RFC3339),
 	}
 }
 
@@ -130,19 +139,43 @@ func AppUserMeUpdate(svc user.Service, store media.ServerStore, maxUploadBytes i
 			SendJBizFail(w, err.Error())
 			return
 		}
-		SendJSuccess(w, struct {
-			Nickname  string `json:"nickname"`
-			AvatarURL string `json:"avatarUrl"`
-			Level     int    `json:"level"`
-			Exp       int64  `json:"exp"`
-			CreatedAt string `json:"createdAt"`
-		}{
-			Nickname:  out.Nickname,
-			AvatarURL: out.AvatarURL,
-			Level:     out.Level,
-			Exp:       out.Exp,
-			CreatedAt: out.CreatedAt.Format(time.RFC3339),
-		})
+		SendJSuccess(w, appUserMe(out))
+	}
+}
+
+// AppUserMePhoneBind 绑定/更换当前用户手机号（小程序 getPhoneNumber 按钮返回的 code）。
+// POST /api/users/me/phone
+func AppUserMePhoneBind(svc user.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		uid := userIDFromRequest(r)
+		if uid == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			SendJBizFail(w, "参数格式错误")
+			return
+		}
+
+		out, err := svc.BindPhone(r.Context(), uid, req.Code)
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, appUserMe(out))
 	}
 }
 
@@ -15,6 +15,10 @@ const (
 	CodeForbidden BizCode = 403
 	// CodeNotFound 表示资源不存在。
 	CodeNotFound BizCode = 404
+	// CodeConflict 表示请求冲突（如同一幂等键的请求仍在处理中）。
+	CodeConflict BizCode = 409
+	// CodeTooManyRequests 表示请求过于频繁（被限流或临时锁定）。
+	CodeTooManyRequests BizCode = 429
 	// CodeInternal 表示服务端内部错误。
 	CodeInternal BizCode = 500
 )
@@ -32,6 +36,10 @@ func (c BizCode) DefaultMessage() string {
 		return "无权限"
 	case CodeNotFound:
 		return "资源不存在"
+	case CodeConflict:
+		return "请求冲突"
+	case CodeTooManyRequests:
+		return "请求过于频繁，请稍后再试"
 	case CodeInternal:
 		return "服务器异常"
 	default:
@@ -0,0 +1,178 @@
+// 登录接口限流与失败锁定（小程序登录、管理员登录共用）。
+package handlers
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gamesocial/internal/ratelimit"
+	"gamesocial/modules/admin"
+)
+
+// auditRecorder 是写审计日志的最小接口（由 admin.Service 实现）。
+type auditRecorder interface {
+	RecordAudit(ctx context.Context, e admin.AuditEntry) error
+}
+
+// LoginThrottle 描述登录接口的限流规则；为 nil 或 Limiter 为 nil 时不限流。
+type LoginThrottle struct {
+	Limiter *ratelimit.Limiter
+	// PerIP: 同一客户端 IP 的请求频率上限。
+	PerIP ratelimit.Rule
+	// PerIdentity: 同一账号（openid/用户名）的请求频率上限。
+	PerIdentity ratelimit.Rule
+	// Lockout: 管理员密码在 Window 内错误达到 Limit 次后锁定该账号（Window 为失败计数窗口）。
+	Lockout ratelimit.Rule
+	// LockoutDuration: 锁定时长（从进入锁定起算，与失败计数窗口无关）。
+	LockoutDuration time.Duration
+	// TrustProxy: 是否信任 X-Forwarded-For/X-Real-IP（仅部署在反向代理之后时开启）。
+	TrustProxy bool
+	// Audit: 记录进入限流/锁定状态的事件（同一拒绝期内只记一次）；为 nil 时只打日志。
+	Audit auditRecorder
+}
+
+type throttleCheck struct {
+	dim  string
+	key  string
+	rule ratelimit.Rule
+}
+
+// allow 按 IP 与身份两个维度判定是否放行；拒绝时已写出 429 响应（仅进入限流期的首次拒绝记录审计）。
+// scope 用于区分接口（如 WECHAT_LOGIN/ADMIN_LOGIN），identity 为空时只按 IP 限流。
+func (t *LoginThrottle) allow(w http.ResponseWriter, r *http.Request, scope, identity string) bool {
+	if t == nil || t.Limiter == nil {
+		return true
+	}
+	ip := t.clientIP(r)
+	checks := []throttleCheck{{dim: "ip", key: scope + ":ip:" + ip, rule: t.PerIP}}
+	if identity != "" {
+		checks = append(checks, throttleCheck{dim: "identity", key: scope + ":id:" + identity, rule: t.PerIdentity})
+	}
+	for _, c := range checks {
+		d, err := t.Limiter.Allow(r.Context(), c.key, c.rule)
+		if err != nil {
+			// 限流存储故障时放行，避免登录整体不可用。
+			log.Printf("ratelimit %s: %v", scope, err)
+			continue
+		}
+		if !d.Allowed {
+			if d.Entered {
+				t.record(r, scope+"_RATE_LIMITED", ip, identity, map[string]any{
+					"path":              r.URL.Path,
+					"ip":                ip,
+					"dimension":         c.dim,
+					"retryAfterSeconds": retrySeconds(d.RetryAfter),
+				})
+			}
+			deny(w, d.RetryAfter, "")
+			return false
+		}
+	}
+	return true
+}
+
+// locked 判定账号是否处于失败锁定期；锁定时已写出 429 响应（进入锁定已由 fail 记录审计，这里不再记录）。
+func (t *LoginThrottle) locked(w http.ResponseWriter, r *http.Request, scope, identity string) bool {
+	if t == nil || t.Limiter == nil || identity == "" {
+		return false
+	}
+	d, err := t.Limiter.Locked(r.Context(), scope+":fail:"+identity)
+	if err != nil {
+		log.Printf("ratelimit %s: %v", scope, err)
+		return false
+	}
+	if d.Allowed {
+		return false
+	}
+	deny(w, d.RetryAfter, lockedMessage(d.RetryAfter))
+	return true
+}
+
+// fail 记录一次登录失败；本次失败触发锁定时写入锁定审计（本次请求仍按原错误返回）。
+func (t *LoginThrottle) fail(r *http.Request, scope, identity string) {
+	if t == nil || t.Limiter == nil || identity == "" {
+		return
+	}
+	d, err := t.Limiter.Fail(r.Context(), scope+":fail:"+identity, t.Lockout, t.LockoutDuration)
+	if err != nil {
+		log.Printf("ratelimit %s: %v", scope, err)
+		return
+	}
+	if d.Entered {
+		ip := t.clientIP(r)
+		t.record(r, scope+"_LOCKOUT", ip, identity, map[string]any{
+			"ip":             ip,
+			"failures":       t.Lockout.Limit,
+			"lockoutSeconds": retrySeconds(d.RetryAfter),
+		})
+	}
+}
+
+// succeed 登录成功后清除失败计数。
+func (t *LoginThrottle) succeed(r *http.Request, scope, identity string) {
+	if t == nil || t.Limiter == nil || identity == "" {
+		return
+	}
+	if err := t.Limiter.Reset(r.Context(), scope+":fail:"+identity); err != nil {
+		log.Printf("ratelimit %s: %v", scope, err)
+	}
+}
+
+// deny 写出 429 响应（带 Retry-After）。
+func deny(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	w.Header().Set("Retry-After", strconv.FormatInt(retrySeconds(retryAfter), 10))
+	SendJError(w, http.StatusTooManyRequests, CodeTooManyRequests, message)
+}
+
+// record 写入审计日志（admin_id 为空，biz_type=AUTH，biz_id 为账号标识）。
+func (t *LoginThrottle) record(r *http.Request, action, ip, identity string, detail map[string]any) {
+	if identity == "" {
+		identity = ip
+	}
+	if t.Audit == nil {
+		log.Printf("ratelimit %s %s", action, identity)
+		return
+	}
+	e := admin.AuditEntry{Action: action, BizType: "AUTH", BizID: identity, Detail: detail}
+	if err := t.Audit.RecordAudit(context.WithoutCancel(r.Context()), e); err != nil {
+		log.Printf("ratelimit audit %s: %v", action, err)
+	}
+}
+
+// clientIP 返回客户端 IP；仅在 TrustProxy 时读取代理头，避免客户端伪造绕过限流。
+func (t *LoginThrottle) clientIP(r *http.Request) string {
+	if t.TrustProxy {
+		if v := strings.TrimSpace(r.Header.Get("X-Real-IP")); v != "" {
+			return v
+		}
+		if v := r.Header.Get("X-Forwarded-For"); v != "" {
+			first, _, _ := strings.Cut(v, ",")
+			if first = strings.TrimSpace(first); first != "" {
+				return first
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func retrySeconds(d time.Duration) int64 {
+	s := int64((d + time.Second - 1) / time.Second)
+	if s < 1 {
+		s = 1
+	}
+	return s
+}
+
+func lockedMessage(d time.Duration) string {
+	minutes := (retrySeconds(d) + 59) / 60
+	return "密码错误次数过多，账号已临时锁定，请 " + strconv.FormatInt(minutes, 10) + " 分钟后重试"
+}
@@ -3,7 +3,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 
 	"gamesocial/modules/auth"
 )
@@ -15,8 +17,10 @@ type wechatLoginRequest struct {
 }
 
 // WechatLogin 处理小程序登录请求：
-// - 临时：仅支持直接传 openId/openid 返回用户数据（可选签发 token）
-func WechatLogin(svc auth.Service) http.HandlerFunc {
+// - 正式：传 wx.login() 的 code，经 code2session 换取 openid 后签发 token
+// - 开发登录模式（DEV_LOGIN_ENABLED）：可直接传白名单内的 openId/openid；未开启时返回 403
+// - 按 IP/openid 限流，超限返回 HTTP 429
+func WechatLogin(svc auth.Service, throttle *LoginThrottle) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 1) 方法校验：小程序登录预期是 POST。
 		if r.Method != http.MethodPost {
@@ -30,22 +34,48 @@ func WechatLogin(svc auth.Service) http.HandlerFunc {
 			return
 		}
 
-		// 3) 解析请求体：读取 JSON 并取出 wx.login() 的 code。
+		// 3) 解析请求体：优先取 wx.login() 的 code，其次是开发模式的 openId。
 		var req wechatLoginRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			SendJBizFail(w, "参数格式错误")
 			return
 		}
+		code := strings.TrimSpace(req.Code)
 		openID := req.OpenID
 		if openID == "" {
 			openID = req.OpenIDLegacy
 		}
-		if openID == "" {
-			SendJBizFail(w, "openId 不能为空")
+		if code == "" && openID == "" {
+			SendJBizFail(w, "code 不能为空")
+			return
+		}
+
+		// 4) 限流：超限直接拒绝，不再访问下游（code 一次性有效，只按 IP 限流）。
+		identity := openID
+		if code != "" {
+			identity = ""
+		}
+		if !throttle.allow(w, r, "WECHAT_LOGIN", identity) {
 			return
 		}
 
-		result, err := svc.OpenIDLogin(r.Context(), openID)
+		var (
+			result auth.LoginResult
+			err    error
+		)
+		if code != "" {
+			result, err = svc.WechatLogin(r.Context(), code)
+		} else {
+			result, err = svc.OpenIDLogin(r.Context(), openID)
+		}
+		if errors.Is(err, auth.ErrDevLoginDisabled) || errors.Is(err, auth.ErrDevOpenIDNotAllowed) {
+			SendJError(w, http.StatusForbidden, CodeForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, auth.ErrUserBanned) {
+			SendJError(w, http.StatusForbidden, CodeForbidden, "user banned")
+			return
+		}
 		if err != nil {
 			SendJBizFail(w, err.Error())
 			return
@@ -55,3 +85,104 @@ func WechatLogin(svc auth.Service) http.HandlerFunc {
 		SendJSuccess(w, result)
 	}
 }
+
+// AuthRefresh 使用 refresh token 换取新的 access/refresh token（旧 refresh token 立即失效）。
+// POST /api/auth/refresh
+func AuthRefresh(svc auth.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		var req struct {
+			RefreshToken string `json:"refreshToken"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			SendJBizFail(w, "参数格式错误")
+			return
+		}
+
+		result, err := svc.Refresh(r.Context(), req.RefreshToken)
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, result)
+	}
+}
+
+// AuthLogout 退出当前设备：吊销当前 access token 所属会话。
+// POST /api/auth/logout
+func AuthLogout(svc auth.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		uid := userIDFromRequest(r)
+		sessionID := r.Header.Get("X-User-Session")
+		if uid == 0 || sessionID == "" {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+		if err := svc.Logout(r.Context(), uid, sessionID); err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, map[string]any{"logout": true})
+	}
+}
+
+// AuthLogoutAll 退出所有设备：吊销该用户的全部会话。
+// POST /api/auth/logout-all
+func AuthLogoutAll(svc auth.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		if svc == nil {
+			SendJError(w, http.StatusInternalServerError, CodeInternal, "")
+			return
+		}
+
+		uid := userIDFromRequest(r)
+		if uid == 0 {
+			SendJError(w, http.StatusUnauthorized, CodeUnauthorized, "")
+			return
+		}
+		if err := svc.LogoutAll(r.Context(), uid); err != nil {
+			SendJBizFail(w, err.Error())
+			return
+		}
+		SendJSuccess(w, map[string]any{"logout": true})
+	}
+}
+
+// AuthJWKS 以标准 JWKS 格式返回用户 token 的公钥（不使用统一响应包装，便于通用 JWT 库直接消费）。
+// GET /.well-known/jwks.json
+func AuthJWKS(keys *auth.Keyring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			SendJError(w, http.StatusMethodNotAllowed, CodeBizNotDone, "method not allowed")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys.JWKS()})
+	}
+}
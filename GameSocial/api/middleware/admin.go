@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gamesocial/api/handlers"
+	"gamesocial/modules/admin"
+)
+
+// AdminAuth 为 /admin/* 路由做管理员鉴权：
+// - 校验 Authorization: Bearer <admin token>，并确认服务端会话有效
+// - 通过后注入 X-Admin-Id / X-Admin-Session / X-Admin-Role 请求头，供 handler 与 RequirePermission 读取
+// - /admin/auth/login 与 OPTIONS 预检请求放行
+func AdminAuth(svc admin.Service) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 防止客户端伪造管理员身份头。
+			r.Header.Del("X-Admin-Id")
+			r.Header.Del("X-Admin-Session")
+			r.Header.Del("X-Admin-Role")
+
+			if !strings.HasPrefix(r.URL.Path, "/admin/") || r.Method == http.MethodOptions || r.URL.Path == "/admin/auth/login" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if svc == nil {
+				handlers.SendJError(w, http.StatusInternalServerError, handlers.CodeInternal, "")
+				return
+			}
+
+			token := bearerToken(r)
+			if token == "" {
+				handlers.SendJError(w, http.StatusUnauthorized, handlers.CodeUnauthorized, "")
+				return
+			}
+			sess, err := svc.Authenticate(r.Context(), token)
+			if err != nil {
+				handlers.SendJError(w, http.StatusUnauthorized, handlers.CodeUnauthorized, "")
+				return
+			}
+
+			r.Header.Set("X-Admin-Id", strconv.FormatUint(sess.Admin.ID, 10))
+			r.Header.Set("X-Admin-Session", sess.ID)
+			r.Header.Set("X-Admin-Role", string(sess.Admin.Role))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission 包裹单个管理端路由，要求当前管理员角色拥有 perm 权限，否则返回 403。
+// 需配合 AdminAuth 使用（依赖其注入的 X-Admin-Role）。
+func RequirePermission(perm admin.Permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Admin-Id") == "" {
+			handlers.SendJError(w, http.StatusUnauthorized, handlers.CodeUnauthorized, "")
+			return
+		}
+		role := admin.Role(r.Header.Get("X-Admin-Role"))
+		if !role.Can(perm) {
+			handlers.SendJError(w, http.StatusForbidden, handlers.CodeForbidden, "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken 从 Authorization 头中取出 token（兼容不带 Bearer 前缀的写法）。
+func bearerToken(r *http.Request) string {
+	authz := strings.TrimSpace(r.Header.Get("Authorization"))
+	if authz == "" {
+		return ""
+	}
+	parts := strings.Fields(authz)
+	if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+		return parts[1]
+	}
+	return authz
+}
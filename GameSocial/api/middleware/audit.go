@@ -0,0 +1,249 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gamesocial/modules/admin"
+)
+
+// auditMaxBody 是审计时读取/记录的请求体与响应体上限（字节），超出部分不记录。
+const auditMaxBody = 64 << 10
+
+// auditRedactKeys 中的字段（按小写包含匹配）在写入 detail_json 前会被替换为 "***"。
+var auditRedactKeys = []string{"password", "passwd", "token", "secret", "phone", "idcard"}
+
+// auditResource 描述一个管理端资源路径与其审计动作前缀、biz_type 的对应关系。
+// verb 非空时作为固定子动作（不再按 HTTP 方法补 CREATE/UPDATE/DELETE）。
+type auditResource struct {
+	path    string
+	action  string
+	bizType string
+	verb    string
+}
+
+// auditResources 按路径前缀匹配；未列出的路径按分段大写拼接生成 action。
+var auditResources = []auditResource{
+	{path: "goods", action: "GOODS", bizType: "GOODS"},
+	{path: "tournaments", action: "TOURNAMENT", bizType: "TOURNAMENT"},
+	{path: "task-defs", action: "TASK_DEF", bizType: "TASK_DEF"},
+	{path: "users", action: "USER", bizType: "USER"},
+	{path: "redeem/orders", action: "REDEEM", bizType: "REDEEM_ORDER"},
+	// 扫码核销与 PUT /admin/redeem/orders/{id}/use 同为 REDEEM_USE，biz_id 取响应中的订单 id。
+	{path: "redeem/scan", action: "REDEEM", bizType: "REDEEM_ORDER", verb: "USE"},
+	{path: "qrcodes", action: "QRCODE", bizType: "QRCODE"},
+}
+
+// AdminAudit 为 /admin/* 下的 POST/PUT/DELETE 请求自动写入 admin_audit_log：
+// - action 由路由推导（如 PUT /admin/goods/1 -> GOODS_UPDATE，PUT /admin/redeem/orders/1/use -> REDEEM_USE）
+// - biz_id 取路径中的数字 id；创建类接口取响应 data.id
+// - detail_json 记录脱敏后的请求体与处理结果；响应 data 带订单号时一并记录 orderNo，便于按订单号检索
+// skipPrefixes 用于跳过业务层自行记录审计的路由（例如登录、账号管理）。
+// 需放在 AdminAuth 内层，依赖其注入的 X-Admin-Id。
+func AdminAudit(svc admin.Service, skipPrefixes ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if svc == nil || !isAuditedRequest(r, skipPrefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			adminID, _ := strconv.ParseUint(r.Header.Get("X-Admin-Id"), 10, 64)
+			if adminID == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// 1) 读取请求体副本（仅 JSON），并还原 r.Body 供 handler 正常读取。
+			var reqBody []byte
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") && r.Body != nil {
+				buf, err := io.ReadAll(io.LimitReader(r.Body, auditMaxBody+1))
+				if err == nil {
+					reqBody = buf
+				}
+				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(buf), r.Body))
+			}
+
+			// 2) 执行 handler，同时记录响应状态码与响应体前缀。
+			rec := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			// 3) 推导 action/biz 并写入审计日志；写入失败只打日志，不影响本次响应。
+			action, bizType, bizID := deriveAuditAction(r.Method, r.URL.Path)
+			var resp struct {
+				Code int `json:"code"`
+				Data struct {
+					ID      json.Number `json:"id"`
+					OrderNo string      `json:"orderNo"`
+				} `json:"data"`
+			}
+			_ = json.Unmarshal(rec.body.Bytes(), &resp)
+			if bizID == "" && resp.Data.ID != "" {
+				bizID = resp.Data.ID.String()
+			}
+
+			detail := map[string]any{
+				"method": r.Method,
+				"path":   r.URL.Path,
+				"status": rec.status,
+				"code":   resp.Code,
+			}
+			if resp.Data.OrderNo != "" {
+				detail["orderNo"] = resp.Data.OrderNo
+			}
+			if len(reqBody) > auditMaxBody {
+				detail["request"] = "(truncated)"
+			} else if len(reqBody) != 0 {
+				var v any
+				if err := json.Unmarshal(reqBody, &v); err == nil {
+					detail["request"] = redactAudit(v)
+				}
+			} else if ct := r.Header.Get("Content-Type"); ct != "" {
+				detail["contentType"] = strings.TrimSpace(strings.Split(ct, ";")[0])
+			}
+
+			err := svc.RecordAudit(context.WithoutCancel(r.Context()), admin.AuditEntry{
+				AdminID: adminID,
+				Action:  action,
+				BizType: bizType,
+				BizID:   bizID,
+				Detail:  detail,
+			})
+			if err != nil {
+				log.Printf("admin audit: %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		})
+	}
+}
+
+func isAuditedRequest(r *http.Request, skipPrefixes []string) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	if !strings.HasPrefix(r.URL.Path, "/admin/") {
+		return false
+	}
+	for _, p := range skipPrefixes {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// deriveAuditAction 根据方法与路径推导审计 action、biz_type 与 biz_id。
+// 规则：资源前缀 + id 之后的子动作（如 USE/CANCEL）；无子动作时按方法取 CREATE/UPDATE/DELETE。
+func deriveAuditAction(method, path string) (action, bizType, bizID string) {
+	rest := strings.Trim(strings.TrimPrefix(path, "/admin/"), "/")
+
+	prefix, verb := "", ""
+	for _, res := range auditResources {
+		if rest == res.path || strings.HasPrefix(rest, res.path+"/") {
+			prefix, bizType, verb = res.action, res.bizType, res.verb
+			rest = strings.TrimPrefix(strings.TrimPrefix(rest, res.path), "/")
+			break
+		}
+	}
+
+	// 剩余分段：第一个纯数字段作为 biz_id，其余作为动作名。
+	words := make([]string, 0, 4)
+	if prefix != "" {
+		words = append(words, prefix)
+	}
+	sub := 0
+	for _, seg := range strings.Split(rest, "/") {
+		if seg == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(seg, 10, 64); err == nil {
+			if bizID == "" {
+				bizID = seg
+			}
+			continue
+		}
+		words = append(words, strings.ToUpper(strings.ReplaceAll(seg, "-", "_")))
+		sub++
+	}
+	if bizType == "" && len(words) > 0 {
+		bizType = words[0]
+	}
+
+	// 已知资源且没有子动作：按资源的固定动作或 HTTP 方法补动词。
+	if prefix != "" && sub == 0 {
+		switch {
+		case verb != "":
+			words = append(words, verb)
+		case method == http.MethodPost:
+			words = append(words, "CREATE")
+		case method == http.MethodPut:
+			words = append(words, "UPDATE")
+		case method == http.MethodDelete:
+			words = append(words, "DELETE")
+		}
+	}
+	return strings.Join(words, "_"), bizType, bizID
+}
+
+// redactAudit 递归替换敏感字段的值。
+func redactAudit(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			lk := strings.ToLower(k)
+			sensitive := false
+			for _, key := range auditRedactKeys {
+				if strings.Contains(lk, key) {
+					sensitive = true
+					break
+				}
+			}
+			if sensitive {
+				t[k] = "***"
+			} else {
+				t[k] = redactAudit(val)
+			}
+		}
+		return t
+	case []any:
+		for i := range t {
+			t[i] = redactAudit(t[i])
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// auditResponseWriter 记录响应状态码，并缓存响应体前缀用于解析业务码与新建 id。
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if remain := auditMaxBody - w.body.Len(); remain > 0 {
+		if len(b) < remain {
+			remain = len(b)
+		}
+		w.body.Write(b[:remain])
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gamesocial/api/handlers"
+	"gamesocial/internal/idempotency"
+)
+
+const (
+	// idempotencyHeader 是客户端携带幂等键的请求头。
+	idempotencyHeader = "Idempotency-Key"
+	// idempotencyReplayedHeader 标记本次响应是回放的首次响应。
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	// idempotencyMaxKeyLen 是幂等键最大长度。
+	idempotencyMaxKeyLen = 128
+	// idempotencyMaxBody 是参与指纹计算的请求体上限，以及可保存回放的响应体上限（字节）；超出的请求不做幂等处理。
+	idempotencyMaxBody = 256 << 10
+	// idempotencyLockTTL 是处理中占位的有效期（应大于 HTTP WriteTimeout），进程崩溃后占位到期自动释放。
+	idempotencyLockTTL = time.Minute
+)
+
+// Idempotency 为携带 Idempotency-Key 的小程序写请求（/api/* 的 POST/PUT/PATCH/DELETE）去重：
+// - 幂等范围为“用户 + method + path + key”，首个请求执行并保存响应，ttl 内的重复请求直接回放（响应头 Idempotent-Replayed: true）
+// - 首个请求仍在处理中时，重复请求返回 409，不会再次执行
+// - 同一 key 用于不同请求体时返回 409
+// - 只保存业务成功（HTTP 2xx 且 code=200）的响应；业务失败（可能是死锁、断连等瞬时错误）、4xx/5xx 或 panic 时释放占位，客户端可用同一 key 重试
+// 需放在 InjectUserIDFromToken 内层，依赖其注入的 X-User-Id；未登录请求不处理（由 handler 返回 401）。
+func Idempotency(store idempotency.Store, ttl time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := strings.TrimSpace(r.Header.Get(idempotencyHeader))
+			userID := r.Header.Get("X-User-Id")
+			if store == nil || key == "" || userID == "" || !isIdempotentCandidate(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !validIdempotencyKey(key) {
+				handlers.SendJError(w, http.StatusBadRequest, handlers.CodeBizNotDone, "Idempotency-Key 格式错误")
+				return
+			}
+
+			// 1) 读取请求体计算指纹，并还原 r.Body 供 handler 正常读取；过大的请求不做幂等处理。
+			var body []byte
+			if r.Body != nil {
+				buf, err := io.ReadAll(io.LimitReader(r.Body, idempotencyMaxBody+1))
+				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(buf), r.Body))
+				if err != nil || len(buf) > idempotencyMaxBody {
+					next.ServeHTTP(w, r)
+					return
+				}
+				body = buf
+			}
+			sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+			fingerprint := hex.EncodeToString(sum[:])
+			scope := userID + " " + r.Method + " " + r.URL.Path + " " + key
+
+			// 2) 占用 key；已存在时按状态回放或拒绝。
+			rec, claimed, err := store.Claim(r.Context(), scope, fingerprint, idempotencyLockTTL)
+			if err != nil {
+				log.Printf("idempotency claim: %v", err)
+				handlers.SendJError(w, http.StatusInternalServerError, handlers.CodeInternal, "")
+				return
+			}
+			if !claimed {
+				switch {
+				case rec.Fingerprint != fingerprint:
+					handlers.SendJError(w, http.StatusConflict, handlers.CodeConflict, "Idempotency-Key 已用于其他请求")
+				case !rec.Done:
+					handlers.SendJError(w, http.StatusConflict, handlers.CodeConflict, "请求正在处理中，请勿重复提交")
+				default:
+					if rec.Response.ContentType != "" {
+						w.Header().Set("Content-Type", rec.Response.ContentType)
+					}
+					w.Header().Set(idempotencyReplayedHeader, "true")
+					w.WriteHeader(rec.Response.Status)
+					_, _ = w.Write(rec.Response.Body)
+				}
+				return
+			}
+
+			// 3) 执行 handler 并保存响应；非成功响应、panic 或响应过大时释放占位。
+			// 保存使用独立于请求的 context，客户端断开也要落库。
+			saveCtx := context.WithoutCancel(r.Context())
+			rw := &idempotencyResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := store.Release(saveCtx, scope); err != nil {
+					log.Printf("idempotency release: %v", err)
+				}
+			}()
+			next.ServeHTTP(rw, r)
+
+			if rw.overflow || !succeeded(rw.status, rw.body.Bytes()) {
+				return
+			}
+			if err := store.Complete(saveCtx, scope, idempotency.Response{
+				Status:      rw.status,
+				ContentType: w.Header().Get("Content-Type"),
+				Body:        rw.body.Bytes(),
+			}, ttl); err != nil {
+				log.Printf("idempotency complete: %v", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// isIdempotentCandidate 判断请求是否为需要去重的小程序写请求。
+func isIdempotentCandidate(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		return false
+	}
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// succeeded 判断响应是否为业务成功（HTTP 2xx 且 JSON code=200）。
+// handler 把所有 service 错误都以 HTTP 200 + code=201 返回，其中包含瞬时错误，不能当作最终结果保存回放。
+func succeeded(status int, body []byte) bool {
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		return false
+	}
+	var resp struct {
+		Code handlers.BizCode `json:"code"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return false
+	}
+	return resp.Code == handlers.CodeOK
+}
+
+// validIdempotencyKey 只接受长度不超过 128 的可见 ASCII 字符（如 UUID）。
+func validIdempotencyKey(key string) bool {
+	if len(key) > idempotencyMaxKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// idempotencyResponseWriter 记录响应状态码并缓存完整响应体用于回放（超过上限时标记 overflow）。
+type idempotencyResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool
+}
+
+func (w *idempotencyResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *idempotencyResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if !w.overflow {
+		if w.body.Len()+len(b) > idempotencyMaxBody {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
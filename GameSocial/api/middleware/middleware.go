@@ -2,12 +2,14 @@
 package middleware
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"gamesocial/api/handlers"
 	"gamesocial/modules/auth"
 )
 
@@ -49,22 +51,25 @@ func Logging() Middleware {
 	}
 }
 
-func InjectUserIDFromToken(tokenSecret string) Middleware {
-	secret := []byte(strings.TrimSpace(tokenSecret))
+// InjectUserIDFromToken 解析用户 access token，并向服务端确认会话未被吊销；
+// 通过后注入 X-User-Id / X-User-Session 请求头。token 无效时不注入，由 handler 决定是否返回 401；
+// 用户已封禁时直接返回 403 "user banned"（需放在 CORS 内层，该 403 才带跨域头）。
+func InjectUserIDFromToken(svc auth.Service) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if len(secret) != 0 && r != nil {
-				r.Header.Del("X-User-Id")
-				authz := strings.TrimSpace(r.Header.Get("Authorization"))
-				if authz != "" {
-					token := authz
-					parts := strings.Fields(authz)
-					if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
-						token = parts[1]
+			// 防止客户端伪造用户身份头。
+			r.Header.Del("X-User-Id")
+			r.Header.Del("X-User-Session")
+			if svc != nil && !strings.HasPrefix(r.URL.Path, "/admin/") {
+				if token := bearerToken(r); token != "" {
+					sess, err := svc.Authenticate(r.Context(), token)
+					if errors.Is(err, auth.ErrUserBanned) {
+						handlers.SendJError(w, http.StatusForbidden, handlers.CodeForbidden, "user banned")
+						return
 					}
-					uid, err := auth.ParseTokenV1(token, secret, time.Now())
-					if err == nil && uid != 0 {
-						r.Header.Set("X-User-Id", strconv.FormatUint(uid, 10))
+					if err == nil && sess.UserID != 0 {
+						r.Header.Set("X-User-Id", strconv.FormatUint(sess.UserID, 10))
+						r.Header.Set("X-User-Session", sess.ID)
 					}
 				}
 			}
@@ -106,11 +111,12 @@ func CORS(allowOrigin string) Middleware {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
 			}
 			w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
+			w.Header().Set("Access-Control-Expose-Headers", "Idempotent-Replayed")
 			if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
 				w.Header().Set("Access-Control-Allow-Headers", reqHeaders)
 				w.Header().Add("Vary", "Access-Control-Request-Headers")
 			} else {
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
 			}
 			if origin != "" && origin != "*" {
 				w.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -0,0 +1,100 @@
+// reconcile 是积分对账命令：比对 points_account.balance 与 points_ledger，输出不一致报告。
+//
+// 用法：
+//
+//	go run ./cmd/reconcile                # 全量对账，只报告
+//	go run ./cmd/reconcile -user 1001     # 只对账一个用户
+//	go run ./cmd/reconcile -fix           # 对余额不一致的用户写 RECONCILE 修正流水（写审计日志）
+//	go run ./cmd/reconcile -json          # 以 JSON 输出完整报告
+//
+// 存在未修正的问题时退出码为 1，便于接入定时脚本告警。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gamesocial/internal/config"
+	"gamesocial/internal/database"
+	"gamesocial/modules/points"
+)
+
+func main() {
+	userID := flag.Uint64("user", 0, "只对账指定用户 ID（默认全部用户）")
+	fix := flag.Bool("fix", false, "以余额快照为准写修正流水，并补建缺失的积分账户")
+	asJSON := flag.Bool("json", false, "以 JSON 输出完整报告")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	if !cfg.DBEnabled {
+		log.Fatalf("reconcile requires DB_ENABLED=true")
+	}
+	db, err := database.InitMySQL(database.DBConfig{
+		DSN:      cfg.DBDSN,
+		Host:     cfg.DBHost,
+		Port:     cfg.DBPort,
+		User:     cfg.DBUser,
+		Password: cfg.DBPassword,
+		DBName:   cfg.DBName,
+	})
+	if err != nil {
+		log.Fatalf("init database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	svc := points.NewService(db, points.Config{
+		AdjustApprovalThreshold: cfg.PointsAdjustApprovalThreshold,
+		ExpireMonths:            cfg.PointsExpireMonths,
+	})
+	report, err := svc.Reconcile(ctx, points.ReconcileOptions{UserID: *userID, Fix: *fix})
+	if err != nil {
+		log.Fatalf("reconcile: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		printReport(report)
+	}
+
+	for _, m := range report.Mismatches {
+		if !m.Corrected {
+			os.Exit(1)
+		}
+	}
+}
+
+func printReport(report points.ReconcileReport) {
+	fmt.Printf("checked %d users, %d mismatches, %d corrected (%s)\n",
+		report.CheckedUsers, len(report.Mismatches), report.Corrected, report.FinishedAt.Sub(report.StartedAt).Round(time.Millisecond))
+	for _, m := range report.Mismatches {
+		fmt.Printf("user %d: issues=%v balance=%d expected=%d last_balance_after=%d ledgers=%d",
+			m.UserID, m.Issues, m.Balance, m.ExpectedBalance, m.LastBalanceAfter, m.LedgerCount)
+		if m.Corrected {
+			fmt.Printf(" corrected")
+			if m.CorrectionLedgerID != 0 {
+				fmt.Printf(" ledger_id=%d", m.CorrectionLedgerID)
+			}
+		}
+		fmt.Println()
+		for _, b := range m.ChainBreaks {
+			fmt.Printf("  chain break at ledger %d: prev_balance_after=%d change=%d balance_after=%d\n",
+				b.LedgerID, b.PrevBalanceAfter, b.ChangeAmount, b.BalanceAfter)
+		}
+	}
+}
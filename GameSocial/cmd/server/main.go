@@ -14,12 +14,20 @@ import (
 
 	"gamesocial/api/handlers"
 	"gamesocial/api/middleware"
+	"gamesocial/internal/cache"
 	"gamesocial/internal/config"
 	"gamesocial/internal/database"
+	"gamesocial/internal/idempotency"
+	"gamesocial/internal/job"
 	"gamesocial/internal/media"
+	"gamesocial/internal/pii"
+	"gamesocial/internal/ratelimit"
 	"gamesocial/internal/wechat"
+	"gamesocial/modules/admin"
 	"gamesocial/modules/auth"
 	"gamesocial/modules/item"
+	"gamesocial/modules/notify"
+	"gamesocial/modules/points"
 	"gamesocial/modules/qrcode"
 	"gamesocial/modules/redeem"
 	"gamesocial/modules/task"
@@ -33,8 +41,14 @@ type App struct {
 	Config config.Config
 	// DB: 数据库连接；当 DBEnabled=false 时为 nil。
 	DB *sql.DB
+	// AuthKeys: 用户 token 签名/校验密钥环（支持 kid 轮换）。
+	AuthKeys *auth.Keyring
+	// WechatTokens: 小程序全局 access_token（缓存 + 临近过期刷新）。
+	WechatTokens *wechat.TokenManager
 	// AuthSvc: 登录与 token 签发服务。
 	AuthSvc auth.Service
+	// AdminSvc: 管理员登录与会话校验服务。
+	AdminSvc admin.Service
 	// ItemSvc: 商品/饮品业务服务。
 	ItemSvc item.Service
 	// TournamentSvc: 赛事业务服务。
@@ -43,10 +57,17 @@ type App struct {
 	TaskSvc task.Service
 	// UserSvc: 用户管理业务服务。
 	UserSvc user.Service
+	// PointsSvc: 积分账户与流水账本服务（所有积分变动统一入口）。
+	PointsSvc points.Service
 	// RedeemSvc: 兑换订单业务服务。
 	RedeemSvc redeem.Service
 	// QRCodeSvc: 二维码生成/校验/核销服务。
 	QRCodeSvc qrcode.Service
+	// NotifySvc: 订阅消息（授权次数/入队/发送/投递日志）服务。
+	NotifySvc notify.Service
+
+	// LoginThrottle: 登录接口限流与管理员失败锁定。
+	LoginThrottle *handlers.LoginThrottle
 
 	// MediaStore: 媒体上传存储（如腾讯云 COS）。
 	MediaServerStore media.ServerStore
@@ -79,24 +100,114 @@ func main() {
 		defer db.Close()
 	}
 
+	// 用户 token 密钥环：未配置任何密钥时为 nil（登录接口会返回未配置错误）。
+	authKeys, err := buildAuthKeyring(cfg)
+	if err != nil {
+		log.Fatalf("load auth token keys: %v", err)
+	}
+
+	// 小程序 code2session：开发模式下可替换为假实现，本地无需微信凭证即可登录。
+	wechatClient := wechat.NewClient(cfg.WechatAppID, cfg.WechatAppSecret, wechat.WithBaseURL(cfg.WechatAPIBaseURL))
+	var wechatSessions wechat.SessionProvider = wechatClient
+	if cfg.WechatFakeCode2Session {
+		wechatSessions = wechat.NewFakeSessionProvider(cfg.DevLoginOpenIDs)
+	}
+	if cfg.DevLoginEnabled {
+		log.Printf("WARNING: DEV_LOGIN_ENABLED=true, openid login without WeChat verification is allowed for %d test openid(s); never enable this in production", len(cfg.DevLoginOpenIDs))
+	}
+	if cfg.WechatFakeCode2Session {
+		log.Printf("WARNING: WECHAT_FAKE_CODE2SESSION=true, wechat login codes are not verified")
+	}
+
+	// 订阅消息/手机号等服务端接口共用同一个 access_token 管理器。
+	wechatTokens := wechat.NewTokenManager(wechatClient)
+	wechatAPI := wechat.NewMessenger(wechatClient, wechatTokens)
+
+	// 手机号等敏感字段加密：未配置密钥时手机号绑定/按手机号查找不可用。
+	var piiCipher *pii.Cipher
+	if cfg.PIIEncryptionKey != "" {
+		key, err := pii.ParseKey(cfg.PIIEncryptionKey)
+		if err != nil {
+			log.Fatalf("load PII_ENCRYPTION_KEY: %v", err)
+		}
+		piiCipher, err = pii.New(key)
+		if err != nil {
+			log.Fatalf("init pii cipher: %v", err)
+		}
+	}
+
+	// 积分：兑换等业务在各自事务内通过 points 模块扣减/增加积分。
+	pointsSvc := points.NewService(db, points.Config{
+		AdjustApprovalThreshold: cfg.PointsAdjustApprovalThreshold,
+		ExpireMonths:            cfg.PointsExpireMonths,
+
+		TransferDailyLimit:        cfg.PointsTransferDailyLimit,
+		TransferDailyCount:        cfg.PointsTransferDailyCount,
+		TransferDailyReceiveLimit: cfg.PointsTransferDailyReceiveLimit,
+		TransferMinAccountDays:    cfg.PointsTransferMinAccountDays,
+	})
+
 	app := App{
-		Config: cfg,
-		DB:     db,
+		Config:       cfg,
+		DB:           db,
+		AuthKeys:     authKeys,
+		WechatTokens: wechatTokens,
 		AuthSvc: auth.NewService(
 			db,
-			wechat.NewClient(cfg.WechatAppID, cfg.WechatAppSecret),
-			cfg.AuthTokenSecret,
+			wechatSessions,
+			authKeys,
 			cfg.AuthTokenTTLSeconds,
+			cfg.AuthRefreshTokenTTLSeconds,
+			cache.NewMemory(),
+			cfg.AuthStatusCacheTTLSeconds,
+			auth.DevLoginConfig{Enabled: cfg.DevLoginEnabled, OpenIDs: cfg.DevLoginOpenIDs},
 		),
+		AdminSvc:      admin.NewService(db, cfg.AdminTokenSecret, cfg.AdminTokenTTLSeconds),
 		ItemSvc:       item.NewService(db),
 		TournamentSvc: tournament.NewService(db),
 		TaskSvc:       task.NewService(db),
-		UserSvc:       user.NewService(db),
-		RedeemSvc:     redeem.NewService(db),
+		UserSvc:       user.NewService(db, wechatAPI, piiCipher),
+		PointsSvc:     pointsSvc,
 	}
 
 	app.MediaMaxUploadBytes = cfg.MediaMaxUploadMB * 1024 * 1024
 
+	// 订阅消息：发送走 access_token 管理器（token 失效自动刷新重试）。
+	app.NotifySvc = notify.NewService(db, wechatAPI, notify.Config{
+		Templates: map[string]string{
+			notify.EventTournamentStart:  cfg.NotifyTplTournamentStart,
+			notify.EventTournamentResult: cfg.NotifyTplTournamentResult,
+			notify.EventRedeemUsed:       cfg.NotifyTplRedeemUsed,
+			notify.EventVipExpiring:      cfg.NotifyTplVipExpiring,
+		},
+		MiniprogramState: cfg.NotifyMiniprogramState,
+		MaxAttempts:      cfg.NotifyMaxAttempts,
+		TournamentLead:   time.Duration(cfg.NotifyTournamentLeadMinutes) * time.Minute,
+		VipExpireAhead:   time.Duration(cfg.NotifyVipExpireDays) * 24 * time.Hour,
+	})
+
+	// 登录限流：多实例部署时使用 MySQL 共享计数，否则各实例独立计数。
+	var limitStore ratelimit.Store = ratelimit.NewMemory()
+	if cfg.RateLimitBackend == "mysql" {
+		limitStore = ratelimit.NewMySQL(db)
+	}
+	loginWindow := time.Duration(cfg.LoginRateLimitWindowSeconds) * time.Second
+	app.LoginThrottle = &handlers.LoginThrottle{
+		Limiter:         ratelimit.New(limitStore),
+		PerIP:           ratelimit.Rule{Limit: cfg.LoginRateLimitPerIP, Window: loginWindow},
+		PerIdentity:     ratelimit.Rule{Limit: cfg.LoginRateLimitPerIdentity, Window: loginWindow},
+		Lockout:         ratelimit.Rule{Limit: cfg.AdminLoginMaxFailures, Window: time.Duration(cfg.AdminLoginFailureWindowSeconds) * time.Second},
+		LockoutDuration: time.Duration(cfg.AdminLoginLockoutSeconds) * time.Second,
+		TrustProxy:      cfg.RateLimitTrustProxy,
+		Audit:           app.AdminSvc,
+	}
+
+	// 写请求幂等：多实例部署时使用 MySQL 共享记录，否则重复请求落到不同实例无法去重。
+	var idemStore idempotency.Store = idempotency.NewMemory()
+	if cfg.IdempotencyBackend == "mysql" {
+		idemStore = idempotency.NewMySQL(db)
+	}
+
 	// 媒体能力只分两类：
 	// 1) MediaServerStore：服务端接收文件并用 COS SDK 上传（管理员后台/部分接口会用到）
 	// 2) MediaDirectStore：后端只下发“直传凭证”，前端自己 PUT 上传（小程序多图上传会用到）
@@ -153,17 +264,29 @@ func main() {
 		app.QRCodeSvc = qrcode.NewService(db, qrCodeStore, pubKey, privKey, cfg.QRCodeDefaultTTLSeconds, cfg.QRCodePNGSize)
 	}
 
+	// 兑换：取货二维码依赖 QRCodeSvc（未配置密钥时无法生成/扫码，仍可按订单 ID 核销）。
+	app.RedeemSvc = redeem.NewService(db, pointsSvc, app.QRCodeSvc, redeem.Config{
+		PickupQRTTLSeconds: cfg.RedeemPickupQRTTLSeconds,
+		PickupWindowHours:  cfg.RedeemPickupWindowHours,
+	})
+
 	// 使用 net/http 的 ServeMux 进行路由分发（Go 1.22+ 支持 "METHOD /path" 形式的模式）。
 	mux := http.NewServeMux()
 	registerRoutes(mux, app)
 
-	// 将中间件包裹在路由处理器外层：Recover(防崩溃) -> CORS -> Logging。
+	// 将中间件包裹在路由处理器外层：Recover(防崩溃) -> CORS -> Logging -> 用户身份注入 -> 管理员鉴权 -> 管理员审计 -> 写请求幂等。
+	// 用户身份注入与 AdminAuth 放在 CORS/Logging 内层，保证被拒绝的请求（如封禁用户的 403）也带跨域头并留有访问日志。
+	// AdminAudit 依赖 AdminAuth 注入的管理员身份；登录、账号管理与积分调整由业务层自行写审计，这里跳过。
+	// Idempotency 依赖注入的用户身份，放在最内层：被回放/拒绝的重复请求同样有跨域头与访问日志。
 	handler := middleware.Chain(
 		mux,
 		middleware.Recover(),
-		middleware.InjectUserIDFromToken(cfg.AuthTokenSecret),
 		middleware.CORS("*"),
 		middleware.Logging(),
+		middleware.InjectUserIDFromToken(app.AuthSvc),
+		middleware.AdminAuth(app.AdminSvc),
+		middleware.AdminAudit(app.AdminSvc, "/admin/auth/", "/admin/admins", "/admin/points/"),
+		middleware.Idempotency(idemStore, time.Duration(cfg.IdempotencyTTLSeconds)*time.Second),
 	)
 
 	// 配置 HTTP Server 的超时，避免慢请求占用连接资源。
@@ -187,20 +310,83 @@ func main() {
 		}
 	}()
 
+	// 定时任务：扫描业务事件入队订阅消息，并发送到期消息（依赖 DB）。
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	runner := job.NewRunner()
+	if cfg.JobsEnabled && db != nil {
+		registerJobs(runner, app)
+		runner.Start(jobCtx)
+	}
+
 	<-stop
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = server.Shutdown(ctx)
+
+	// 停止定时任务并等待正在执行的任务退出（之后才会关闭 DB）。
+	stopJobs()
+	runner.Wait()
+}
+
+// registerJobs 注册进程内定时任务；任务均可重复执行（入队唯一键去重，发送前条件认领）。
+func registerJobs(runner *job.Runner, app App) {
+	runner.Every("notify.dispatch", 30*time.Second, func(ctx context.Context) error {
+		_, err := app.NotifySvc.Dispatch(ctx, 100)
+		return err
+	})
+	runner.Every("notify.scan_tournament_start", time.Minute, func(ctx context.Context) error {
+		_, err := app.NotifySvc.ScanTournamentStarts(ctx)
+		return err
+	})
+	runner.Every("notify.scan_tournament_result", 5*time.Minute, func(ctx context.Context) error {
+		_, err := app.NotifySvc.ScanTournamentResults(ctx)
+		return err
+	})
+	runner.Every("notify.scan_redeem_used", time.Minute, func(ctx context.Context) error {
+		_, err := app.NotifySvc.ScanRedeemUsed(ctx)
+		return err
+	})
+	runner.Every("notify.scan_vip_expiring", time.Hour, func(ctx context.Context) error {
+		_, err := app.NotifySvc.ScanVipExpiring(ctx)
+		return err
+	})
+	runner.Every("points.expire", 10*time.Minute, func(ctx context.Context) error {
+		_, err := app.PointsSvc.ExpireLots(ctx, 500)
+		return err
+	})
+	runner.Every("redeem.expire", 10*time.Minute, func(ctx context.Context) error {
+		_, err := app.RedeemSvc.ExpireOrders(ctx, 200)
+		return err
+	})
+	// 对账只报告不修正：发现不一致后人工核实，再用 cmd/reconcile -fix 修正。
+	runner.Every("points.reconcile", time.Duration(app.Config.PointsReconcileIntervalHours)*time.Hour, func(ctx context.Context) error {
+		report, err := app.PointsSvc.Reconcile(ctx, points.ReconcileOptions{})
+		if err != nil {
+			return err
+		}
+		for _, m := range report.Mismatches {
+			log.Printf("points reconcile: user %d issues=%v balance=%d expected=%d last_balance_after=%d chain_breaks=%d",
+				m.UserID, m.Issues, m.Balance, m.ExpectedBalance, m.LastBalanceAfter, len(m.ChainBreaks))
+		}
+		log.Printf("points reconcile: checked %d users, %d mismatches", report.CheckedUsers, len(report.Mismatches))
+		return nil
+	})
 }
 
 func registerRoutes(mux *http.ServeMux, app App) {
 	// 路由只负责 HTTP 语义（方法/路径/参数），具体业务逻辑由 handlers 层实现。
 	mux.HandleFunc("GET /health", handlers.Health())
-	mux.HandleFunc("POST /api/auth/wechat/login", handlers.WechatLogin(app.AuthSvc))
+	// 用户 token 公钥（仅 EdDSA/RS256 时非空），供其他服务自行校验 token。
+	mux.HandleFunc("GET /.well-known/jwks.json", handlers.AuthJWKS(app.AuthKeys))
+	mux.HandleFunc("POST /api/auth/wechat/login", handlers.WechatLogin(app.AuthSvc, app.LoginThrottle))
+	mux.HandleFunc("POST /api/auth/refresh", handlers.AuthRefresh(app.AuthSvc))
+	mux.HandleFunc("POST /api/auth/logout", handlers.AuthLogout(app.AuthSvc))
+	mux.HandleFunc("POST /api/auth/logout-all", handlers.AuthLogoutAll(app.AuthSvc))
 
 	mux.HandleFunc("GET /api/users/me", handlers.AppUserMeGet(app.UserSvc))
 	mux.HandleFunc("PUT /api/users/me", handlers.AppUserMeUpdate(app.UserSvc, app.MediaServerStore, app.MediaMaxUploadBytes))
+	mux.HandleFunc("POST /api/users/me/phone", handlers.AppUserMePhoneBind(app.UserSvc))
 	// 小程序端：申请“临时目录 temp/”的直传凭证，用于多图上传（用户取消不污染正式目录）。
 	mux.HandleFunc("POST /api/media/temp-upload-infos", handlers.AppMediaTempUploadInfos(app.MediaDirectStore))
 	mux.HandleFunc("GET /api/goods", handlers.AppGoodsList(app.ItemSvc))
@@ -215,8 +401,11 @@ func registerRoutes(mux *http.ServeMux, app App) {
 	mux.HandleFunc("POST /api/redeem/orders", handlers.AppRedeemOrderCreate(app.RedeemSvc))
 	mux.HandleFunc("GET /api/redeem/orders/{id}", handlers.AppRedeemOrderGet(app.RedeemSvc))
 	mux.HandleFunc("PUT /api/redeem/orders/{id}/cancel", handlers.AppRedeemOrderCancel(app.RedeemSvc))
-	mux.HandleFunc("GET /api/points/balance", handlers.AppPointsBalance(app.DB))
-	mux.HandleFunc("GET /api/points/ledgers", handlers.AppPointsLedgers(app.DB))
+	mux.HandleFunc("POST /api/redeem/orders/{id}/pickup-qr", handlers.AppRedeemOrderPickupQR(app.RedeemSvc))
+	mux.HandleFunc("GET /api/points/balance", handlers.AppPointsBalance(app.PointsSvc))
+	mux.HandleFunc("GET /api/points/ledgers", handlers.AppPointsLedgers(app.PointsSvc))
+	mux.HandleFunc("GET /api/points/summary", handlers.AppPointsSummary(app.PointsSvc))
+	mux.HandleFunc("POST /api/points/transfers", handlers.AppPointsTransfer(app.PointsSvc))
 	mux.HandleFunc("GET /api/vip/status", handlers.AppVipStatus(app.DB))
 	mux.HandleFunc("GET /api/tasks", handlers.AppTasksList(app.TaskSvc))
 	mux.HandleFunc("POST /api/tasks/checkin", handlers.AppTasksCheckin())
@@ -224,49 +413,113 @@ func registerRoutes(mux *http.ServeMux, app App) {
 	// 小程序端：扫码核销二维码（例如 CHECKIN）。
 	mux.HandleFunc("POST /api/qrcodes/verify", handlers.AppQRCodesVerify(app.QRCodeSvc))
 	mux.HandleFunc("POST /api/qrcodes/use", handlers.AppQRCodesUse(app.QRCodeSvc))
+	// 小程序端：订阅消息模板与授权结果上报。
+	mux.HandleFunc("GET /api/notify/templates", handlers.AppNotifyTemplates(app.NotifySvc))
+	mux.HandleFunc("POST /api/notify/subscriptions", handlers.AppNotifySubscriptionsReport(app.NotifySvc))
 
-	// 管理员侧：商品管理 CRUD（暂未接入管理员鉴权中间件）。
-	mux.HandleFunc("POST /admin/goods", handlers.AdminGoodsCreate(app.ItemSvc, app.MediaServerStore, app.MediaMaxUploadBytes))
-	mux.HandleFunc("GET /admin/goods", handlers.AdminGoodsList(app.ItemSvc))
-	mux.HandleFunc("GET /admin/goods/{id}", handlers.AdminGoodsGet(app.ItemSvc))
-	mux.HandleFunc("PUT /admin/goods/{id}", handlers.AdminGoodsUpdate(app.ItemSvc, app.MediaServerStore, app.MediaMaxUploadBytes))
-	mux.HandleFunc("DELETE /admin/goods/{id}", handlers.AdminGoodsDelete(app.ItemSvc))
+	// 管理员侧：商品管理 CRUD（/admin/* 统一经过 AdminAuth 鉴权，adminRoute 额外声明所需权限）。
+	adminRoute(mux, "POST /admin/goods", admin.PermGoodsWrite, handlers.AdminGoodsCreate(app.ItemSvc, app.MediaServerStore, app.MediaMaxUploadBytes))
+	adminRoute(mux, "GET /admin/goods", admin.PermGoodsRead, handlers.AdminGoodsList(app.ItemSvc))
+	adminRoute(mux, "GET /admin/goods/{id}", admin.PermGoodsRead, handlers.AdminGoodsGet(app.ItemSvc))
+	adminRoute(mux, "PUT /admin/goods/{id}", admin.PermGoodsWrite, handlers.AdminGoodsUpdate(app.ItemSvc, app.MediaServerStore, app.MediaMaxUploadBytes))
+	adminRoute(mux, "DELETE /admin/goods/{id}", admin.PermGoodsWrite, handlers.AdminGoodsDelete(app.ItemSvc))
 
 	// 管理员侧：赛事管理 CRUD。
-	mux.HandleFunc("POST /admin/tournaments", handlers.AdminTournamentCreate(app.TournamentSvc, app.MediaServerStore, app.MediaMaxUploadBytes))
-	mux.HandleFunc("GET /admin/tournaments", handlers.AdminTournamentList(app.TournamentSvc))
-	mux.HandleFunc("GET /admin/tournaments/{id}", handlers.AdminTournamentGet(app.TournamentSvc))
-	mux.HandleFunc("PUT /admin/tournaments/{id}", handlers.AdminTournamentUpdate(app.TournamentSvc, app.MediaServerStore, app.MediaMaxUploadBytes))
-	mux.HandleFunc("DELETE /admin/tournaments/{id}", handlers.AdminTournamentDelete(app.TournamentSvc))
+	adminRoute(mux, "POST /admin/tournaments", admin.PermTournamentWrite, handlers.AdminTournamentCreate(app.TournamentSvc, app.MediaServerStore, app.MediaMaxUploadBytes))
+	adminRoute(mux, "GET /admin/tournaments", admin.PermTournamentRead, handlers.AdminTournamentList(app.TournamentSvc))
+	adminRoute(mux, "GET /admin/tournaments/{id}", admin.PermTournamentRead, handlers.AdminTournamentGet(app.TournamentSvc))
+	adminRoute(mux, "PUT /admin/tournaments/{id}", admin.PermTournamentWrite, handlers.AdminTournamentUpdate(app.TournamentSvc, app.MediaServerStore, app.MediaMaxUploadBytes))
+	adminRoute(mux, "DELETE /admin/tournaments/{id}", admin.PermTournamentWrite, handlers.AdminTournamentDelete(app.TournamentSvc))
 
 	// 管理员侧：任务定义管理 CRUD。
-	mux.HandleFunc("POST /admin/task-defs", handlers.AdminTaskDefCreate(app.TaskSvc))
-	mux.HandleFunc("GET /admin/task-defs", handlers.AdminTaskDefList(app.TaskSvc))
-	mux.HandleFunc("GET /admin/task-defs/{id}", handlers.AdminTaskDefGet(app.TaskSvc))
-	mux.HandleFunc("PUT /admin/task-defs/{id}", handlers.AdminTaskDefUpdate(app.TaskSvc))
-	mux.HandleFunc("DELETE /admin/task-defs/{id}", handlers.AdminTaskDefDelete(app.TaskSvc))
+	adminRoute(mux, "POST /admin/task-defs", admin.PermTaskWrite, handlers.AdminTaskDefCreate(app.TaskSvc))
+	adminRoute(mux, "GET /admin/task-defs", admin.PermTaskRead, handlers.AdminTaskDefList(app.TaskSvc))
+	adminRoute(mux, "GET /admin/task-defs/{id}", admin.PermTaskRead, handlers.AdminTaskDefGet(app.TaskSvc))
+	adminRoute(mux, "PUT /admin/task-defs/{id}", admin.PermTaskWrite, handlers.AdminTaskDefUpdate(app.TaskSvc))
+	adminRoute(mux, "DELETE /admin/task-defs/{id}", admin.PermTaskWrite, handlers.AdminTaskDefDelete(app.TaskSvc))
 
 	// 管理员侧：用户查询/更新/封禁。
-	mux.HandleFunc("GET /admin/users", handlers.AdminUserList(app.UserSvc))
-	mux.HandleFunc("GET /admin/users/{id}", handlers.AdminUserGet(app.UserSvc))
-	mux.HandleFunc("PUT /admin/users/{id}", handlers.AdminUserUpdate(app.UserSvc))
+	adminRoute(mux, "GET /admin/users", admin.PermUserRead, handlers.AdminUserList(app.UserSvc))
+	adminRoute(mux, "GET /admin/users/{id}", admin.PermUserRead, handlers.AdminUserGet(app.UserSvc))
+	adminRoute(mux, "PUT /admin/users/{id}", admin.PermUserWrite, handlers.AdminUserUpdate(app.UserSvc))
 
 	// 管理员侧：兑换订单 CRUD + 核销。
-	mux.HandleFunc("POST /admin/redeem/orders", handlers.AdminRedeemOrderCreate(app.RedeemSvc))
-	mux.HandleFunc("GET /admin/redeem/orders", handlers.AdminRedeemOrderList(app.RedeemSvc))
-	mux.HandleFunc("GET /admin/redeem/orders/{id}", handlers.AdminRedeemOrderGet(app.RedeemSvc))
-	mux.HandleFunc("PUT /admin/redeem/orders/{id}/use", handlers.AdminRedeemOrderUse(app.RedeemSvc))
-	mux.HandleFunc("PUT /admin/redeem/orders/{id}/cancel", handlers.AdminRedeemOrderCancel(app.RedeemSvc))
-
-	mux.HandleFunc("POST /admin/auth/login", handlers.AdminAuthLogin())
-	mux.HandleFunc("GET /admin/auth/me", handlers.AdminAuthMe())
-	mux.HandleFunc("POST /admin/auth/logout", handlers.AdminAuthLogout())
-	mux.HandleFunc("GET /admin/audit/logs", handlers.AdminAuditLogs(app.DB))
-	mux.HandleFunc("POST /admin/points/adjust", handlers.AdminPointsAdjust())
-	mux.HandleFunc("PUT /admin/users/{id}/drinks/use", handlers.AdminUsersDrinksUse())
-	mux.HandleFunc("POST /admin/tournaments/{id}/results/publish", handlers.AdminTournamentResultsPublish())
-	mux.HandleFunc("POST /admin/tournaments/{id}/awards/grant", handlers.AdminTournamentAwardsGrant())
+	adminRoute(mux, "POST /admin/redeem/orders", admin.PermRedeemWrite, handlers.AdminRedeemOrderCreate(app.RedeemSvc))
+	adminRoute(mux, "GET /admin/redeem/orders", admin.PermRedeemRead, handlers.AdminRedeemOrderList(app.RedeemSvc))
+	adminRoute(mux, "GET /admin/redeem/orders/{id}", admin.PermRedeemRead, handlers.AdminRedeemOrderGet(app.RedeemSvc))
+	adminRoute(mux, "PUT /admin/redeem/orders/{id}/use", admin.PermRedeemUse, handlers.AdminRedeemOrderUse(app.RedeemSvc))
+	adminRoute(mux, "POST /admin/redeem/scan", admin.PermRedeemUse, handlers.AdminRedeemScan(app.RedeemSvc))
+	adminRoute(mux, "GET /admin/redeem/expired-summary", admin.PermRedeemRead, handlers.AdminRedeemExpiredSummary(app.RedeemSvc))
+	adminRoute(mux, "PUT /admin/redeem/orders/{id}/cancel", admin.PermRedeemWrite, handlers.AdminRedeemOrderCancel(app.RedeemSvc))
+
+	// 管理员登录/登出/当前信息：任意已登录管理员可用，不声明权限点。
+	mux.HandleFunc("POST /admin/auth/login", handlers.AdminAuthLogin(app.AdminSvc, app.LoginThrottle))
+	mux.HandleFunc("GET /admin/auth/me", handlers.AdminAuthMe(app.AdminSvc))
+	mux.HandleFunc("POST /admin/auth/logout", handlers.AdminAuthLogout(app.AdminSvc))
+	mux.HandleFunc("PUT /admin/admins/me/password", handlers.AdminAdminChangeOwnPassword(app.AdminSvc))
+
+	// 管理员侧：员工账号管理（仅 OWNER）。
+	adminRoute(mux, "POST /admin/admins", admin.PermAdminManage, handlers.AdminAdminCreate(app.AdminSvc))
+	adminRoute(mux, "GET /admin/admins", admin.PermAdminManage, handlers.AdminAdminList(app.AdminSvc))
+	adminRoute(mux, "GET /admin/admins/{id}", admin.PermAdminManage, handlers.AdminAdminGet(app.AdminSvc))
+	adminRoute(mux, "PUT /admin/admins/{id}", admin.PermAdminManage, handlers.AdminAdminUpdate(app.AdminSvc))
+	adminRoute(mux, "DELETE /admin/admins/{id}", admin.PermAdminManage, handlers.AdminAdminDisable(app.AdminSvc))
+	adminRoute(mux, "PUT /admin/admins/{id}/password", admin.PermAdminManage, handlers.AdminAdminResetPassword(app.AdminSvc))
+
+	adminRoute(mux, "GET /admin/audit/logs", admin.PermAuditRead, handlers.AdminAuditLogs(app.DB))
+	adminRoute(mux, "GET /admin/audit/logs/export", admin.PermAuditRead, handlers.AdminAuditLogsExport(app.DB))
+	adminRoute(mux, "GET /admin/notify/deliveries", admin.PermAuditRead, handlers.AdminNotifyDeliveries(app.NotifySvc))
+	adminRoute(mux, "POST /admin/points/adjust", admin.PermPointsAdjust, handlers.AdminPointsAdjust(app.PointsSvc))
+	adminRoute(mux, "GET /admin/points/adjustments", admin.PermPointsAdjust, handlers.AdminPointsAdjustmentList(app.PointsSvc))
+	adminRoute(mux, "POST /admin/points/adjustments/{id}/approve", admin.PermPointsApprove, handlers.AdminPointsAdjustmentApprove(app.PointsSvc))
+	adminRoute(mux, "POST /admin/points/adjustments/{id}/reject", admin.PermPointsApprove, handlers.AdminPointsAdjustmentReject(app.PointsSvc))
+	adminRoute(mux, "PUT /admin/users/{id}/drinks/use", admin.PermDrinkUse, handlers.AdminUsersDrinksUse())
+	adminRoute(mux, "POST /admin/tournaments/{id}/results/publish", admin.PermTournamentWrite, handlers.AdminTournamentResultsPublish())
+	adminRoute(mux, "POST /admin/tournaments/{id}/awards/grant", admin.PermTournamentWrite, handlers.AdminTournamentAwardsGrant())
 
 	// 管理端：生成二维码（用于展示给用户扫码）。
-	mux.HandleFunc("POST /admin/qrcodes", handlers.AdminQRCodesCreate(app.QRCodeSvc))
+	adminRoute(mux, "POST /admin/qrcodes", admin.PermQRCodeCreate, handlers.AdminQRCodesCreate(app.QRCodeSvc))
+}
+
+// buildAuthKeyring 根据配置组装用户 token 密钥环；没有任何密钥配置时返回 nil。
+func buildAuthKeyring(cfg config.Config) (*auth.Keyring, error) {
+	if cfg.AuthTokenSecret == "" && cfg.AuthTokenKeys == "" && cfg.AuthTokenPrivateKeyPEM == "" {
+		return nil, nil
+	}
+	hmacKeys, err := auth.ParseKeyList(cfg.AuthTokenKeys)
+	if err != nil {
+		return nil, fmt.Errorf("AUTH_TOKEN_KEYS: %w", err)
+	}
+	pubList, err := auth.ParseKeyList(cfg.AuthTokenPublicKeys)
+	if err != nil {
+		return nil, fmt.Errorf("AUTH_TOKEN_PUBLIC_KEYS: %w", err)
+	}
+	pubKeys := make(map[string]string, len(pubList))
+	for kid, v := range pubList {
+		pemText, err := media.DecodePEMFromEnv(v)
+		if err != nil {
+			return nil, fmt.Errorf("AUTH_TOKEN_PUBLIC_KEYS %s: %w", kid, err)
+		}
+		pubKeys[kid] = pemText
+	}
+	privPEM := ""
+	if cfg.AuthTokenPrivateKeyPEM != "" {
+		privPEM, err = media.DecodePEMFromEnv(cfg.AuthTokenPrivateKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("AUTH_TOKEN_PRIVATE_KEY_PEM: %w", err)
+		}
+	}
+	return auth.NewKeyring(auth.KeyringConfig{
+		Alg:           cfg.AuthTokenAlg,
+		ActiveKID:     cfg.AuthTokenActiveKID,
+		LegacySecret:  cfg.AuthTokenSecret,
+		HMACKeys:      hmacKeys,
+		PrivateKeyPEM: privPEM,
+		PublicKeysPEM: pubKeys,
+	})
+}
+
+// adminRoute 注册管理端路由，并声明该路由所需的权限点；角色无此权限时返回 403。
+func adminRoute(mux *http.ServeMux, pattern string, perm admin.Permission, h http.HandlerFunc) {
+	mux.Handle(pattern, middleware.RequirePermission(perm, h))
 }
@@ -1,7 +1,7 @@
 -- GameSocial 初始化脚本：建库建表 + 预置演示数据（本地开发/调试用）。
 -- 说明：
 -- 1) 脚本可重复执行：建表前会 DROP TABLE IF EXISTS；预置数据使用 ON DUPLICATE KEY UPDATE。
--- 2) admin_user.password_hash 的 'CHANGE_ME' 只是占位，正式使用前需要替换为真实密码哈希。
+-- 2) 预置管理员 admin 的初始密码为 CHANGE_ME（password_hash 为其 bcrypt 哈希），正式使用前务必修改。
 
 -- 创建数据库（utf8mb4 便于存中文昵称/表情等）。
 CREATE DATABASE IF NOT EXISTS gamesocial
@@ -25,11 +25,63 @@ USE `gamesocial`;
 -- ALTER TABLE goods
 --   ADD COLUMN updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间' AFTER created_at;
 --
+-- 管理员登录会话：另需执行下方 admin_session 的 CREATE TABLE。
+--
+-- ALTER TABLE admin_user
+--   ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'CASHIER' COMMENT '角色：OWNER=店主；MANAGER=店长；CASHIER=收银员' AFTER password_hash;
+-- UPDATE admin_user SET role = 'OWNER' WHERE username = 'admin';
+--
+-- ALTER TABLE admin_audit_log
+--   ADD KEY idx_admin_audit_log_created (created_at);
+--
+-- ALTER TABLE admin_audit_log
+--   MODIFY COLUMN admin_id BIGINT UNSIGNED NULL COMMENT '管理员 ID（对应 admin_user.id；登录限流等未登录事件为空）';
+-- 另需执行下方 rate_limit_hit、rate_limit_bucket 的 CREATE TABLE（仅 RATE_LIMIT_BACKEND=mysql 时使用）。
+-- 写请求幂等：另需执行下方 idempotency_record 的 CREATE TABLE（仅 IDEMPOTENCY_BACKEND=mysql 时使用）。
+--
+-- 用户登录会话（refresh token）：另需执行下方 user_session 的 CREATE TABLE（已包含 rotated_at 列）。
+-- 若 user_session 已按不含 rotated_at 的旧结构建好，再补充 refresh token 重试宽限期字段：
+-- ALTER TABLE user_session
+--   ADD COLUMN rotated_at DATETIME NULL COMMENT '最近一次轮换时间（旧 refresh token 在此后 30 秒内重试不视为重放）' AFTER prev_refresh_hash;
+--
+-- 订阅消息通知：另需执行下方 notify_subscription、notify_delivery 的 CREATE TABLE。
+--
+-- ALTER TABLE `user`
+--   ADD COLUMN phone_enc VARCHAR(255) NULL COMMENT '手机号密文（AES-GCM，可为空）' AFTER exp,
+--   ADD COLUMN phone_hash CHAR(64) NULL COMMENT '手机号盲索引（HMAC-SHA256，用于按手机号查找，可为空）' AFTER phone_enc,
+--   ADD COLUMN phone_bound_at DATETIME NULL COMMENT '手机号绑定时间（可为空）' AFTER phone_hash,
+--   ADD KEY idx_user_phone_hash (phone_hash);
+--
+-- 管理员积分调整审批：另需执行下方 points_adjustment 的 CREATE TABLE。
+--
+-- 积分有效期：另需执行下方 points_lot 的 CREATE TABLE。已有余额会在用户下次积分变动时自动补建批次（从当时起算有效期），
+-- 也可在建表后一次性补建：
+-- INSERT INTO points_lot (user_id, ledger_id, amount, remaining, earned_at, expires_at, created_at, updated_at)
+-- SELECT user_id, NULL, balance, balance, NOW(), NOW() + INTERVAL 12 MONTH, NOW(), NOW() FROM points_account WHERE balance > 0;
+--
+-- 用户间积分赠送：另需执行下方 points_transfer 的 CREATE TABLE。
+--
+-- 积分批次消耗明细（赠送转入、兑换退回沿用原到期时间）：另需执行下方 points_lot_usage 的 CREATE TABLE。
+-- 建表前的扣减没有明细，对应的转入/退回仍按当时起算有效期。
+--
+-- 兑换订单取货期限（超时未取货自动关闭并退款；必须执行，商品与下单逻辑不再兼容缺列的旧库）：
+-- ALTER TABLE goods
+--   ADD COLUMN pickup_window_hours INT NULL COMMENT '取货期限（小时）；NULL=使用全局配置 REDEEM_PICKUP_WINDOW_HOURS，0=不限期' AFTER status;
+-- ALTER TABLE redeem_order
+--   ADD COLUMN expires_at DATETIME NULL COMMENT '取货截止时间（为空表示不限期）' AFTER used_at,
+--   ADD COLUMN expired_at DATETIME NULL COMMENT '超时关闭时间（status=EXPIRED 时有值）' AFTER expires_at,
+--   ADD KEY idx_redeem_order_status_expires (status, expires_at),
+--   ADD KEY idx_redeem_order_expired_at (expired_at);
+-- 已有的待取货订单默认不限期；如需按全局期限处理，可补写截止时间：
+-- UPDATE redeem_order SET expires_at = created_at + INTERVAL 168 HOUR WHERE status = 'CREATED' AND expires_at IS NULL;
+--
 -- 重置表结构：如果表已存在则先删除再创建（开发/调试用）。
 
 
 -- 规范格式的多表删除语句（分行+清晰缩进，避免语法解析错误）
 DROP TABLE IF EXISTS
+  notify_delivery,
+  notify_subscription,
   qr_code,
   checkin_log,
   user_task_progress,
@@ -42,9 +94,18 @@ DROP TABLE IF EXISTS
   redeem_order,
   user_drink_balance,
   goods,
+  points_transfer,
+  points_adjustment,
+  points_lot_usage,
+  points_lot,
   points_ledger,
   points_account,
+  idempotency_record,
+  rate_limit_bucket,
+  rate_limit_hit,
   admin_audit_log,
+  admin_session,
+  user_session,
   vip_subscription,
   user_level,
   admin_user,
@@ -60,10 +121,14 @@ CREATE TABLE `user` (
   status TINYINT NOT NULL DEFAULT 1 COMMENT '状态：1=正常；0=禁用',
   level INT NOT NULL DEFAULT 1 COMMENT '用户等级（由经验值映射，默认 1）',
   exp BIGINT NOT NULL DEFAULT 0 COMMENT '经验值（累积）',
+  phone_enc VARCHAR(255) NULL COMMENT '手机号密文（AES-GCM，可为空）',
+  phone_hash CHAR(64) NULL COMMENT '手机号盲索引（HMAC-SHA256，用于按手机号查找，可为空）',
+  phone_bound_at DATETIME NULL COMMENT '手机号绑定时间（可为空）',
   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
   updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
   PRIMARY KEY (id),
   UNIQUE KEY uk_user_openid (openid),
+  KEY idx_user_phone_hash (phone_hash),
   KEY idx_user_unionid (unionid)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='小程序用户主表（openid 唯一）';
 
@@ -94,11 +159,30 @@ CREATE TABLE vip_subscription (
   CONSTRAINT fk_vip_subscription_user FOREIGN KEY (user_id) REFERENCES `user`(id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='VIP 订阅记录（用于月卡/会员等）';
 
--- admin_user：后台管理员账号表（当前只预置一个管理员）。
+-- user_session：用户登录会话（access token 携带 sid；refresh token 只存哈希，每次刷新轮换）。
+CREATE TABLE user_session (
+  id CHAR(32) NOT NULL COMMENT '会话 ID（随机 hex，写入 access token 的 sid）',
+  user_id BIGINT UNSIGNED NOT NULL COMMENT '用户 ID（对应 user.id）',
+  refresh_hash CHAR(64) NOT NULL COMMENT '当前 refresh token 的 SHA-256（hex）',
+  prev_refresh_hash CHAR(64) NULL COMMENT '上一个 refresh token 的 SHA-256（用于识别重放）',
+  rotated_at DATETIME NULL COMMENT '最近一次轮换时间（旧 refresh token 在此后 30 秒内重试不视为重放）',
+  expires_at DATETIME NOT NULL COMMENT '会话（refresh token）过期时间',
+  revoked_at DATETIME NULL COMMENT '吊销时间（登出/封禁/重放，可为空）',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (id),
+  UNIQUE KEY uk_user_session_refresh (refresh_hash),
+  KEY idx_user_session_prev_refresh (prev_refresh_hash),
+  KEY idx_user_session_user (user_id),
+  CONSTRAINT fk_user_session_user FOREIGN KEY (user_id) REFERENCES `user`(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='用户登录会话';
+
+-- admin_user：后台管理员账号表（预置一个 OWNER 管理员；角色决定可访问的 /admin 路由）。
 CREATE TABLE admin_user (
   id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键 ID',
   username VARCHAR(64) NOT NULL COMMENT '登录用户名（唯一）',
   password_hash VARCHAR(255) NOT NULL COMMENT '密码哈希（禁止存明文）',
+  role VARCHAR(16) NOT NULL DEFAULT 'CASHIER' COMMENT '角色：OWNER=店主；MANAGER=店长；CASHIER=收银员',
   status TINYINT NOT NULL DEFAULT 1 COMMENT '状态：1=启用；0=禁用',
   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
   updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
@@ -106,10 +190,22 @@ CREATE TABLE admin_user (
   UNIQUE KEY uk_admin_user_username (username)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='后台管理员账号';
 
+-- admin_session：管理员登录会话（token 携带 sid，登出/禁用时吊销）。
+CREATE TABLE admin_session (
+  id CHAR(32) NOT NULL COMMENT '会话 ID（随机 hex，写入 token 的 sid）',
+  admin_id BIGINT UNSIGNED NOT NULL COMMENT '管理员 ID（对应 admin_user.id）',
+  expires_at DATETIME NOT NULL COMMENT '过期时间',
+  revoked_at DATETIME NULL COMMENT '吊销时间（登出/禁用，可为空）',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (id),
+  KEY idx_admin_session_admin (admin_id),
+  CONSTRAINT fk_admin_session_admin FOREIGN KEY (admin_id) REFERENCES admin_user(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='管理员登录会话';
+
 -- admin_audit_log：管理员关键操作审计日志。
 CREATE TABLE admin_audit_log (
   id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键 ID',
-  admin_id BIGINT UNSIGNED NOT NULL COMMENT '管理员 ID（对应 admin_user.id）',
+  admin_id BIGINT UNSIGNED NULL COMMENT '管理员 ID（对应 admin_user.id；登录限流等未登录事件为空）',
   action VARCHAR(64) NOT NULL COMMENT '操作动作（如 POINTS_ADJUST/DRINK_USE）',
   biz_type VARCHAR(32) NULL COMMENT '业务类型（如 USER/REDEEM_ORDER，可为空）',
   biz_id VARCHAR(64) NULL COMMENT '业务标识（如 userId/orderNo，可为空）',
@@ -119,9 +215,44 @@ CREATE TABLE admin_audit_log (
   KEY idx_admin_audit_log_admin_id (admin_id),
   KEY idx_admin_audit_log_action (action),
   KEY idx_admin_audit_log_biz (biz_type, biz_id),
+  KEY idx_admin_audit_log_created (created_at),
   CONSTRAINT fk_admin_audit_log_admin FOREIGN KEY (admin_id) REFERENCES admin_user(id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='管理员关键操作审计日志';
 
+-- rate_limit_hit：登录限流/失败锁定的滑动窗口命中记录（多实例共享计数时使用）。
+CREATE TABLE rate_limit_hit (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键 ID',
+  bucket CHAR(64) NOT NULL COMMENT '限流 key 的 SHA-256（如 IP/用户名维度，不存原文）',
+  hit_at DATETIME(3) NOT NULL COMMENT '命中时间（毫秒精度）',
+  PRIMARY KEY (id),
+  KEY idx_rate_limit_hit_bucket (bucket, hit_at),
+  KEY idx_rate_limit_hit_at (hit_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='限流滑动窗口命中记录';
+
+-- rate_limit_bucket：限流 key 的锁行与失败锁定状态（多实例共享计数时使用；同一 key 的判定与记录在事务内按此行串行）。
+CREATE TABLE rate_limit_bucket (
+  bucket CHAR(64) NOT NULL COMMENT '限流 key 的 SHA-256（与 rate_limit_hit.bucket 一致）',
+  locked_until DATETIME(3) NULL COMMENT '失败锁定的解锁时间（为空表示未锁定）',
+  denied_until DATETIME(3) NULL COMMENT '当前限流拒绝期的结束时间（只审计进入拒绝期的首次拒绝）',
+  updated_at DATETIME(3) NOT NULL COMMENT '最近一次判定时间（用于清理长期不活跃的 key）',
+  PRIMARY KEY (bucket),
+  KEY idx_rate_limit_bucket_updated (updated_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='限流 key 锁行';
+
+-- idempotency_record：写请求幂等记录（Idempotency-Key；多实例共享时使用）。
+CREATE TABLE idempotency_record (
+  key_hash CHAR(64) NOT NULL COMMENT '幂等范围（用户 ID + method + path + Idempotency-Key）的 SHA-256',
+  fingerprint CHAR(64) NOT NULL COMMENT '首个请求的指纹（method + path + 请求体的 SHA-256）',
+  state VARCHAR(16) NOT NULL COMMENT '状态：PROCESSING=处理中；DONE=已完成（可回放）',
+  status_code INT NULL COMMENT '首次响应的 HTTP 状态码',
+  content_type VARCHAR(128) NULL COMMENT '首次响应的 Content-Type',
+  body MEDIUMBLOB NULL COMMENT '首次响应体',
+  expires_at DATETIME(3) NOT NULL COMMENT '过期时间（处理中占位 1 分钟；完成后为 IDEMPOTENCY_TTL_SECONDS）',
+  created_at DATETIME(3) NOT NULL COMMENT '创建时间',
+  PRIMARY KEY (key_hash),
+  KEY idx_idempotency_record_expires (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='写请求幂等记录';
+
 -- points_account：积分余额快照（用于快速展示）。
 CREATE TABLE points_account (
   user_id BIGINT UNSIGNED NOT NULL COMMENT '用户 ID（对应 user.id，一对一）',
@@ -137,7 +268,7 @@ CREATE TABLE points_ledger (
   user_id BIGINT UNSIGNED NOT NULL COMMENT '用户 ID（对应 user.id）',
   change_amount BIGINT NOT NULL COMMENT '本次积分变动（正=增加；负=扣减）',
   balance_after BIGINT NOT NULL COMMENT '变动后的余额（用于展示/校验）',
-  biz_type VARCHAR(32) NOT NULL COMMENT '业务类型（用于幂等/追踪，例如 INIT/CHECKIN/REDEEM）',
+  biz_type VARCHAR(32) NOT NULL COMMENT '业务类型（用于幂等/追踪，例如 INIT/CHECKIN/REDEEM/REDEEM_REFUND/ADMIN_ADJUST/EXPIRE/RECONCILE/TRANSFER_OUT/TRANSFER_IN）',
   biz_id VARCHAR(64) NOT NULL COMMENT '业务唯一标识（同 user_id+biz_type 唯一）',
   remark VARCHAR(255) NULL COMMENT '备注说明（可为空）',
   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
@@ -147,6 +278,79 @@ CREATE TABLE points_ledger (
   CONSTRAINT fk_points_ledger_user FOREIGN KEY (user_id) REFERENCES `user`(id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='积分流水账本（含幂等键）';
 
+-- points_lot：积分批次（每笔增加的积分一个批次，按到期时间先进先出消耗，到期后剩余部分写 EXPIRE 流水扣除）。
+CREATE TABLE points_lot (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键 ID（EXPIRE 流水的 biz_id）',
+  user_id BIGINT UNSIGNED NOT NULL COMMENT '用户 ID（对应 user.id）',
+  ledger_id BIGINT UNSIGNED NULL COMMENT '产生该批次的流水 ID（对应 points_ledger.id；历史余额补建的批次为空）',
+  amount BIGINT NOT NULL COMMENT '批次初始积分',
+  remaining BIGINT NOT NULL COMMENT '批次剩余积分（同一用户所有批次之和 = points_account.balance）',
+  earned_at DATETIME NOT NULL COMMENT '获得时间',
+  expires_at DATETIME NOT NULL COMMENT '到期时间',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (id),
+  KEY idx_points_lot_user_expires (user_id, expires_at),
+  KEY idx_points_lot_expires (expires_at),
+  CONSTRAINT fk_points_lot_user FOREIGN KEY (user_id) REFERENCES `user`(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='积分批次（有效期/先进先出）';
+
+-- points_lot_usage：扣减流水消耗了哪些批次（赠送转入、兑换退回按此沿用原积分的到期时间）。
+CREATE TABLE points_lot_usage (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键 ID',
+  ledger_id BIGINT UNSIGNED NOT NULL COMMENT '扣减流水 ID（对应 points_ledger.id）',
+  lot_id BIGINT UNSIGNED NOT NULL COMMENT '被消耗的批次 ID（对应 points_lot.id）',
+  amount BIGINT NOT NULL COMMENT '从该批次消耗的积分',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (id),
+  KEY idx_points_lot_usage_ledger (ledger_id),
+  KEY idx_points_lot_usage_lot (lot_id),
+  CONSTRAINT fk_points_lot_usage_lot FOREIGN KEY (lot_id) REFERENCES points_lot(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='积分批次消耗明细';
+
+-- points_adjustment：管理员积分调整单（超过审批阈值需另一名管理员审批后入账）。
+CREATE TABLE points_adjustment (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键 ID（入账流水 biz_type=ADMIN_ADJUST，biz_id=本 ID）',
+  user_id BIGINT UNSIGNED NOT NULL COMMENT '被调整用户 ID（对应 user.id）',
+  amount BIGINT NOT NULL COMMENT '调整积分（正=增加；负=扣减）',
+  reason_code VARCHAR(32) NOT NULL COMMENT '调整原因：COMPENSATION/ACTIVITY/CORRECTION/OTHER',
+  reason_note VARCHAR(255) NULL COMMENT '原因说明（OTHER 必填）',
+  status VARCHAR(16) NOT NULL COMMENT '状态：PENDING=待审批；APPLIED=已入账；REJECTED=已驳回',
+  idempotency_key VARCHAR(64) NOT NULL COMMENT '前端生成的幂等键（同一发起人唯一）',
+  created_by BIGINT UNSIGNED NOT NULL COMMENT '发起管理员 ID（对应 admin_user.id）',
+  reviewed_by BIGINT UNSIGNED NULL COMMENT '审批管理员 ID（不能与发起人相同）',
+  reviewed_at DATETIME NULL COMMENT '审批时间',
+  review_note VARCHAR(255) NULL COMMENT '审批意见（可为空）',
+  ledger_id BIGINT UNSIGNED NULL COMMENT '入账流水 ID（对应 points_ledger.id）',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (id),
+  UNIQUE KEY uk_points_adjustment_key (created_by, idempotency_key),
+  KEY idx_points_adjustment_status (status, created_at),
+  KEY idx_points_adjustment_user (user_id, created_at),
+  CONSTRAINT fk_points_adjustment_user FOREIGN KEY (user_id) REFERENCES `user`(id),
+  CONSTRAINT fk_points_adjustment_created_by FOREIGN KEY (created_by) REFERENCES admin_user(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='管理员积分调整单（含审批）';
+
+-- points_transfer：用户间积分赠送单（转出/转入两条流水的 biz_id 均为 transfer_no）。
+CREATE TABLE points_transfer (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键 ID',
+  transfer_no VARCHAR(32) NOT NULL COMMENT '赠送单号（流水 biz_type=TRANSFER_OUT/TRANSFER_IN 的 biz_id）',
+  from_user_id BIGINT UNSIGNED NOT NULL COMMENT '赠送方用户 ID（对应 user.id）',
+  to_user_id BIGINT UNSIGNED NOT NULL COMMENT '受赠方用户 ID（对应 user.id）',
+  amount BIGINT NOT NULL COMMENT '赠送积分（正数）',
+  message VARCHAR(64) NULL COMMENT '赠言（受赠方可见，可为空）',
+  out_ledger_id BIGINT UNSIGNED NULL COMMENT '转出流水 ID（对应 points_ledger.id）',
+  in_ledger_id BIGINT UNSIGNED NULL COMMENT '转入流水 ID（对应 points_ledger.id）',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (id),
+  UNIQUE KEY uk_points_transfer_no (transfer_no),
+  KEY idx_points_transfer_from (from_user_id, created_at),
+  KEY idx_points_transfer_to (to_user_id, created_at),
+  CONSTRAINT fk_points_transfer_from FOREIGN KEY (from_user_id) REFERENCES `user`(id),
+  CONSTRAINT fk_points_transfer_to FOREIGN KEY (to_user_id) REFERENCES `user`(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='用户间积分赠送单';
+
 -- goods：积分商品（饮品/毛巾等）。
 CREATE TABLE goods (
   id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键 ID',
@@ -154,8 +358,9 @@ CREATE TABLE goods (
   cover_url VARCHAR(512) NULL COMMENT '封面图 URL（可为空）',
   image_urls_json JSON NULL COMMENT '商品图片 URL 列表 JSON（可为空）',
   points_price BIGINT NOT NULL COMMENT '兑换所需积分（>=0）',
-  stock INT NOT NULL DEFAULT 0 COMMENT '库存（>=0；可用于实物）',
+  stock INT NOT NULL DEFAULT 0 COMMENT '库存（>=0；兑换下单时扣减，为 0 时不可兑换）',
   status TINYINT NOT NULL DEFAULT 1 COMMENT '状态：1=上架；0=下架/删除',
+  pickup_window_hours INT NULL COMMENT '取货期限（小时）；NULL=使用全局配置 REDEEM_PICKUP_WINDOW_HOURS，0=不限期',
   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
   updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
   PRIMARY KEY (id),
@@ -178,15 +383,19 @@ CREATE TABLE redeem_order (
   id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键 ID',
   order_no VARCHAR(64) NOT NULL COMMENT '订单号（业务唯一，用于展示/幂等）',
   user_id BIGINT UNSIGNED NOT NULL COMMENT '下单用户 ID（对应 user.id）',
-  status VARCHAR(16) NOT NULL COMMENT '订单状态（例如 CREATED/USED/CANCELED）',
+  status VARCHAR(16) NOT NULL COMMENT '订单状态：CREATED=待取货；USED=已核销；CANCELED=已取消；EXPIRED=超时未取货已关闭',
   total_points BIGINT NOT NULL DEFAULT 0 COMMENT '订单总积分（汇总值）',
   used_by_admin_id BIGINT UNSIGNED NULL COMMENT '核销管理员 ID（对应 admin_user.id，可为空）',
   used_at DATETIME NULL COMMENT '核销时间（可为空）',
+  expires_at DATETIME NULL COMMENT '取货截止时间（为空表示不限期）',
+  expired_at DATETIME NULL COMMENT '超时关闭时间（status=EXPIRED 时有值）',
   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
   PRIMARY KEY (id),
   UNIQUE KEY uk_redeem_order_order_no (order_no),
   KEY idx_redeem_order_user (user_id),
   KEY idx_redeem_order_status (status),
+  KEY idx_redeem_order_status_expires (status, expires_at),
+  KEY idx_redeem_order_expired_at (expired_at),
   KEY idx_redeem_order_created_at (created_at),
   CONSTRAINT fk_redeem_order_user FOREIGN KEY (user_id) REFERENCES `user`(id),
   CONSTRAINT fk_redeem_order_used_by_admin FOREIGN KEY (used_by_admin_id) REFERENCES admin_user(id)
@@ -346,12 +555,50 @@ CREATE TABLE qr_code (
   CONSTRAINT fk_qr_code_user FOREIGN KEY (user_id) REFERENCES `user`(id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='二维码记录（生成/核销审计）';
 
--- 预置管理员账号（开发用）。
-INSERT INTO admin_user (id, username, password_hash, status, created_at, updated_at)
-VALUES (1, 'admin', 'CHANGE_ME', 1, NOW(), NOW())
+-- notify_subscription：用户订阅消息授权次数（小程序每次 accept 只能接收一条，发送时扣减）。
+CREATE TABLE notify_subscription (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键 ID',
+  user_id BIGINT UNSIGNED NOT NULL COMMENT '用户 ID（对应 user.id）',
+  event VARCHAR(32) NOT NULL COMMENT '通知事件（TOURNAMENT_START/TOURNAMENT_RESULT/REDEEM_USED/VIP_EXPIRING）',
+  template_id VARCHAR(64) NOT NULL COMMENT '最近一次授权的模板 ID',
+  remaining INT NOT NULL DEFAULT 0 COMMENT '剩余可接收次数（>=0）',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (id),
+  UNIQUE KEY uk_notify_subscription_user_event (user_id, event),
+  CONSTRAINT fk_notify_subscription_user FOREIGN KEY (user_id) REFERENCES `user`(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='订阅消息授权次数';
+
+-- notify_delivery：订阅消息投递日志/发送队列（user_id + event + biz_id 唯一，防重复发送）。
+CREATE TABLE notify_delivery (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键 ID',
+  user_id BIGINT UNSIGNED NOT NULL COMMENT '接收用户 ID（对应 user.id）',
+  event VARCHAR(32) NOT NULL COMMENT '通知事件',
+  biz_id VARCHAR(64) NOT NULL COMMENT '业务标识（如 tournamentId/orderNo/vip_subscription.id）',
+  template_id VARCHAR(64) NOT NULL COMMENT '模板 ID（入队时快照）',
+  page VARCHAR(255) NULL COMMENT '点击消息跳转的小程序页面（可为空）',
+  data_json JSON NOT NULL COMMENT '模板数据 JSON（关键词 -> 值，入队时固化）',
+  status VARCHAR(16) NOT NULL DEFAULT 'PENDING' COMMENT '状态：PENDING/SENDING/SENT/FAILED/SKIPPED',
+  attempts INT NOT NULL DEFAULT 0 COMMENT '已发送次数（含失败）',
+  next_attempt_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '下次发送时间（失败后指数退避）',
+  last_error VARCHAR(512) NULL COMMENT '最近一次失败原因（可为空）',
+  sent_at DATETIME NULL COMMENT '发送成功时间（可为空）',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (id),
+  UNIQUE KEY uk_notify_delivery_biz (user_id, event, biz_id),
+  KEY idx_notify_delivery_status_next (status, next_attempt_at),
+  KEY idx_notify_delivery_created (created_at),
+  CONSTRAINT fk_notify_delivery_user FOREIGN KEY (user_id) REFERENCES `user`(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='订阅消息投递日志（发送队列 + 重试）';
+
+-- 预置管理员账号（开发用）：用户名 admin，密码 CHANGE_ME（bcrypt 哈希）。
+INSERT INTO admin_user (id, username, password_hash, role, status, created_at, updated_at)
+VALUES (1, 'admin', '$2a$10$PeZ6haDGPPXuGMHktPoag.u9COQDSuwcUX895uWjZcKcF6xPARVl.', 'OWNER', 1, NOW(), NOW())
 ON DUPLICATE KEY UPDATE
   username = VALUES(username),
   password_hash = VALUES(password_hash),
+  role = VALUES(role),
   status = VALUES(status),
   updated_at = VALUES(updated_at);
 
@@ -425,10 +672,10 @@ ON DUPLICATE KEY UPDATE
 -- 预置商品（开发用）。
 INSERT INTO goods (id, name, cover_url, points_price, stock, status, created_at, updated_at)
 VALUES
-  (2001, '饮料（兑换 +1 杯）', NULL, 50, 0, 1, NOW(), NOW()),
-  (2002, '拳馆毛巾', NULL, 200, 0, 1, NOW(), NOW()),
-  (2003, '手套消耗品', NULL, 120, 0, 1, NOW(), NOW()),
-  (2004, '能量饮料（实物）', NULL, 100, 0, 1, NOW(), NOW())
+  (2001, '饮料（兑换 +1 杯）', NULL, 50, 100, 1, NOW(), NOW()),
+  (2002, '拳馆毛巾', NULL, 200, 20, 1, NOW(), NOW()),
+  (2003, '手套消耗品', NULL, 120, 50, 1, NOW(), NOW()),
+  (2004, '能量饮料（实物）', NULL, 100, 30, 1, NOW(), NOW())
 ON DUPLICATE KEY UPDATE
   name = VALUES(name),
   cover_url = VALUES(cover_url),
@@ -457,15 +704,16 @@ VALUES
   (1, 'GOODS_REDEEM_USE', 'REDEEM_ORDER', 'R202601280001', JSON_OBJECT('order_no', 'R202601280001'), NOW());
 
 -- 预置兑换订单与明细（开发用）。
-INSERT INTO redeem_order (id, order_no, user_id, status, total_points, used_by_admin_id, used_at, created_at)
+INSERT INTO redeem_order (id, order_no, user_id, status, total_points, used_by_admin_id, used_at, expires_at, created_at)
 VALUES
-  (3001, 'R202601280001', 1002, 'USED', 200, 1, NOW(), NOW()),
-  (3002, 'R202601280002', 1005, 'CREATED', 120, NULL, NULL, NOW())
+  (3001, 'R202601280001', 1002, 'USED', 200, 1, NOW(), NOW() + INTERVAL 168 HOUR, NOW()),
+  (3002, 'R202601280002', 1005, 'CREATED', 120, NULL, NULL, NOW() + INTERVAL 168 HOUR, NOW())
 ON DUPLICATE KEY UPDATE
   status = VALUES(status),
   total_points = VALUES(total_points),
   used_by_admin_id = VALUES(used_by_admin_id),
-  used_at = VALUES(used_at);
+  used_at = VALUES(used_at),
+  expires_at = VALUES(expires_at);
 
 INSERT INTO redeem_order_item (redeem_order_id, goods_id, quantity, points_price)
 VALUES
@@ -1,5 +1,78 @@
 // cache 放置缓存/分布式锁等基础设施的抽象（可选：Redis）。
 package cache
 
-// Cache 定义缓存能力的抽象接口（后续补充具体方法）。
-type Cache interface{}
+import (
+	"sync"
+	"time"
+)
+
+// Cache 定义缓存能力的抽象接口：带过期时间的 key/value。
+type Cache interface {
+	// Get 读取未过期的值；不存在或已过期时返回 false。
+	Get(key string) (any, bool)
+	// Set 写入值，ttl<=0 时不写入。
+	Set(key string, value any, ttl time.Duration)
+	// Delete 删除指定 key（用于主动失效）。
+	Delete(key string)
+}
+
+// sweepEvery 表示每写入多少次触发一次过期清理，避免 map 无限增长。
+const sweepEvery = 1024
+
+type memoryItem struct {
+	value     any
+	expiresAt time.Time
+}
+
+// Memory 是进程内的 TTL 缓存实现（多实例部署时各实例独立，数据最多陈旧一个 ttl）。
+type Memory struct {
+	mu     sync.Mutex
+	items  map[string]memoryItem
+	writes int
+}
+
+// NewMemory 创建进程内缓存。
+func NewMemory() *Memory {
+	return &Memory{items: make(map[string]memoryItem)}
+}
+
+// Get 读取未过期的值。
+func (m *Memory) Get(key string) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	if !time.Now().Before(it.expiresAt) {
+		delete(m.items, key)
+		return nil, false
+	}
+	return it.value, true
+}
+
+// Set 写入值并设置过期时间。
+func (m *Memory) Set(key string, value any, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[key] = memoryItem{value: value, expiresAt: now.Add(ttl)}
+	m.writes++
+	if m.writes%sweepEvery == 0 {
+		for k, it := range m.items {
+			if !now.Before(it.expiresAt) {
+				delete(m.items, k)
+			}
+		}
+	}
+}
+
+// Delete 删除指定 key。
+func (m *Memory) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, key)
+}
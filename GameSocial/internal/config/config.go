@@ -41,11 +41,68 @@ type Config struct {
 	// WechatAppID/WechatAppSecret: 微信小程序的 appid/secret，用于 code2session 换取 openid。
 	WechatAppID     string
 	WechatAppSecret string
+	// WechatAPIBaseURL: 微信开放接口地址（默认 https://api.weixin.qq.com；测试可指向 wechatfake 本地服务）。
+	WechatAPIBaseURL string
+
+	// PIIEncryptionKey: 手机号等敏感字段的加密主密钥（base64 的 32 字节）；为空时手机号绑定不可用。
+	// 一旦有数据落库不可随意更换，否则旧密文无法解密、按手机号查找失效。
+	PIIEncryptionKey string
+
+	// 开发登录模式（仅本地/测试环境开启，生产环境必须关闭）：
+	// - DevLoginEnabled: 允许 POST /api/auth/wechat/login 直接传 openId 登录（无身份证明）
+	// - DevLoginOpenIDs: 允许直登的测试 openid 白名单（逗号分隔，开启时必填）
+	// - WechatFakeCode2Session: 使用假 code2session（不访问微信，只返回白名单内 openid；需先开启 DevLoginEnabled）
+	DevLoginEnabled        bool
+	DevLoginOpenIDs        []string
+	WechatFakeCode2Session bool
 
 	// AuthTokenSecret: 用户 token 签名密钥（自定义 HMAC token）；需要自行设置为随机长字符串。
 	AuthTokenSecret string
-	// AuthTokenTTLSeconds: token 有效期（秒）。
+	// AuthTokenTTLSeconds: access token 有效期（秒），建议较短，过期后用 refresh token 续期。
 	AuthTokenTTLSeconds int64
+	// AuthRefreshTokenTTLSeconds: refresh token（服务端会话）有效期（秒），每次刷新顺延。
+	AuthRefreshTokenTTLSeconds int64
+	// AuthStatusCacheTTLSeconds: 每次请求校验会话/封禁状态的缓存时间（秒）；0 表示不缓存。
+	AuthStatusCacheTTLSeconds int64
+
+	// 用户 token 密钥轮换（JWT header 带 kid）：
+	// - AuthTokenAlg: 新 token 的签名算法 HS256/EdDSA/RS256（默认 HS256）
+	// - AuthTokenActiveKID: 当前签名密钥的 kid；HS256 默认 "default"（即 AUTH_TOKEN_SECRET）
+	// - AuthTokenKeys: 其他 HS256 密钥 "kid:secret,kid:secret"（旧密钥保留到其 token 全部过期）
+	// - AuthTokenPrivateKeyPEM: EdDSA/RS256 的签名私钥（建议 base64 PEM）
+	// - AuthTokenPublicKeys: 轮换前的旧公钥 "kid:base64pem,kid:base64pem"
+	AuthTokenAlg           string
+	AuthTokenActiveKID     string
+	AuthTokenKeys          string
+	AuthTokenPrivateKeyPEM string
+	AuthTokenPublicKeys    string
+
+	// AdminTokenSecret: 管理员 token 签名密钥；必须与 AuthTokenSecret 不同。
+	AdminTokenSecret string
+	// AdminTokenTTLSeconds: 管理员会话有效期（秒）。
+	AdminTokenTTLSeconds int64
+
+	// 登录限流（小程序登录 /api/auth/wechat/login 与管理员登录 /admin/auth/login）：
+	// - RateLimitBackend: 计数存储 memory（单实例）/ mysql（多实例共享，使用 rate_limit_hit 表）
+	// - RateLimitTrustProxy: 是否信任 X-Forwarded-For/X-Real-IP 取客户端 IP（仅在反向代理之后开启）
+	// - LoginRateLimitWindowSeconds: 限流滑动窗口（秒）
+	// - LoginRateLimitPerIP / LoginRateLimitPerIdentity: 窗口内同一 IP / 同一账号的最大请求数（0=不限制）
+	// - AdminLoginMaxFailures / AdminLoginFailureWindowSeconds: 管理员密码在该窗口内错误次数阈值（0=不锁定）
+	// - AdminLoginLockoutSeconds: 达到阈值后的锁定时长（秒）
+	RateLimitBackend               string
+	RateLimitTrustProxy            bool
+	LoginRateLimitWindowSeconds    int64
+	LoginRateLimitPerIP            int
+	LoginRateLimitPerIdentity      int
+	AdminLoginMaxFailures          int
+	AdminLoginFailureWindowSeconds int64
+	AdminLoginLockoutSeconds       int64
+
+	// 写请求幂等（小程序请求头 Idempotency-Key）：
+	// - IdempotencyBackend: 记录存储 memory（单实例）/ mysql（多实例共享，使用 idempotency_record 表）
+	// - IdempotencyTTLSeconds: 首次响应保存多久（秒），期间同一 key 的重复请求直接回放
+	IdempotencyBackend    string
+	IdempotencyTTLSeconds int64
 
 	// QRCodePublicKeyPEMBase64 / QRCodePrivateKeyPEMBase64：
 	// - 二维码 token 使用“非对称加密（RSA）”
@@ -57,6 +114,45 @@ type Config struct {
 	QRCodeDefaultTTLSeconds int64
 	// QRCodePNGSize: 生成二维码 PNG 默认边长（像素）。
 	QRCodePNGSize int
+
+	// 订阅消息通知（模板 ID 在微信公众平台“订阅消息”中选用；为空表示该事件不发送）：
+	// - NotifyTplTournamentStart/TournamentResult/RedeemUsed/VipExpiring: 各事件的模板 ID
+	// - NotifyMiniprogramState: 点击消息跳转的小程序版本 developer/trial/formal
+	// - NotifyMaxAttempts: 单条消息最多发送次数（含首次）
+	// - NotifyTournamentLeadMinutes: 赛事开始前多少分钟提醒
+	// - NotifyVipExpireDays: 会员到期前多少天提醒
+	NotifyTplTournamentStart    string
+	NotifyTplTournamentResult   string
+	NotifyTplRedeemUsed         string
+	NotifyTplVipExpiring        string
+	NotifyMiniprogramState      string
+	NotifyMaxAttempts           int
+	NotifyTournamentLeadMinutes int64
+	NotifyVipExpireDays         int64
+
+	// PointsAdjustApprovalThreshold: 管理员积分调整的审批阈值；单笔调整绝对值超过该值需另一名管理员审批后才入账。
+	PointsAdjustApprovalThreshold int64
+	// PointsExpireMonths: 积分获得后多少个月过期（按批次先进先出消耗，到期由定时任务扣除）。
+	PointsExpireMonths int
+	// PointsReconcileIntervalHours: 积分对账定时任务间隔（小时，只报告不修正）；0 表示不运行。
+	PointsReconcileIntervalHours int64
+
+	// 用户间积分赠送限制（0 表示不限制）：
+	// - PointsTransferDailyLimit/PointsTransferDailyCount: 每人每天最多赠出的积分/次数
+	// - PointsTransferDailyReceiveLimit: 每人每天最多受赠的积分
+	// - PointsTransferMinAccountDays: 注册满多少天才能赠出
+	PointsTransferDailyLimit        int64
+	PointsTransferDailyCount        int
+	PointsTransferDailyReceiveLimit int64
+	PointsTransferMinAccountDays    int
+
+	// RedeemPickupQRTTLSeconds: 兑换订单取货二维码有效期（秒），过期后用户需重新生成。
+	RedeemPickupQRTTLSeconds int64
+	// RedeemPickupWindowHours: 兑换订单全局取货期限（小时），商品可单独配置；超时未取货的订单由定时任务关闭并退款（0=不限期）。
+	RedeemPickupWindowHours int
+
+	// JobsEnabled: 是否在本进程运行定时任务（订阅消息扫描/发送等）；多实例部署可只在部分实例开启。
+	JobsEnabled bool
 }
 
 // LoadConfig 加载应用配置。
@@ -93,13 +189,66 @@ func LoadConfig() (Config, error) {
 		MediaCOSSecretKey:   os.Getenv("MEDIA_COS_SECRET_KEY"),
 		WechatAppID:         os.Getenv("WECHAT_APP_ID"),
 		WechatAppSecret:     os.Getenv("WECHAT_APP_SECRET"),
+		WechatAPIBaseURL:    getenv("WECHAT_API_BASE_URL", "https://api.weixin.qq.com"),
 		AuthTokenSecret:     os.Getenv("AUTH_TOKEN_SECRET"),
-		AuthTokenTTLSeconds: mustInt64(getenv("AUTH_TOKEN_TTL_SECONDS", "604800")),
+		AuthTokenTTLSeconds: mustInt64(getenv("AUTH_TOKEN_TTL_SECONDS", "1800")),
+
+		DevLoginEnabled:        mustBool(getenv("DEV_LOGIN_ENABLED", "false")),
+		DevLoginOpenIDs:        splitList(os.Getenv("DEV_LOGIN_OPENIDS")),
+		WechatFakeCode2Session: mustBool(getenv("WECHAT_FAKE_CODE2SESSION", "false")),
+
+		PIIEncryptionKey: os.Getenv("PII_ENCRYPTION_KEY"),
+
+		AuthRefreshTokenTTLSeconds: mustInt64(getenv("AUTH_REFRESH_TOKEN_TTL_SECONDS", "2592000")),
+		AuthStatusCacheTTLSeconds:  mustInt64(getenv("AUTH_STATUS_CACHE_TTL_SECONDS", "30")),
+		AuthTokenAlg:               getenv("AUTH_TOKEN_ALG", "HS256"),
+		AuthTokenActiveKID:         os.Getenv("AUTH_TOKEN_ACTIVE_KID"),
+		AuthTokenKeys:              os.Getenv("AUTH_TOKEN_KEYS"),
+		AuthTokenPrivateKeyPEM:     os.Getenv("AUTH_TOKEN_PRIVATE_KEY_PEM"),
+		AuthTokenPublicKeys:        os.Getenv("AUTH_TOKEN_PUBLIC_KEYS"),
+
+		AdminTokenSecret:     os.Getenv("ADMIN_TOKEN_SECRET"),
+		AdminTokenTTLSeconds: mustInt64(getenv("ADMIN_TOKEN_TTL_SECONDS", "43200")),
+
+		RateLimitBackend:               getenv("RATE_LIMIT_BACKEND", "memory"),
+		RateLimitTrustProxy:            mustBool(getenv("RATE_LIMIT_TRUST_PROXY", "false")),
+		LoginRateLimitWindowSeconds:    mustInt64(getenv("LOGIN_RATE_LIMIT_WINDOW_SECONDS", "60")),
+		LoginRateLimitPerIP:            mustInt(getenv("LOGIN_RATE_LIMIT_PER_IP", "20")),
+		LoginRateLimitPerIdentity:      mustInt(getenv("LOGIN_RATE_LIMIT_PER_IDENTITY", "5")),
+		AdminLoginMaxFailures:          mustInt(getenv("ADMIN_LOGIN_MAX_FAILURES", "5")),
+		AdminLoginFailureWindowSeconds: mustInt64(getenv("ADMIN_LOGIN_FAILURE_WINDOW_SECONDS", "900")),
+		AdminLoginLockoutSeconds:       mustInt64(getenv("ADMIN_LOGIN_LOCKOUT_SECONDS", "900")),
+
+		IdempotencyBackend:    getenv("IDEMPOTENCY_BACKEND", "memory"),
+		IdempotencyTTLSeconds: mustInt64(getenv("IDEMPOTENCY_TTL_SECONDS", "86400")),
 
 		QRCodePublicKeyPEMBase64:  os.Getenv("QRCODE_PUBLIC_KEY_PEM"),
 		QRCodePrivateKeyPEMBase64: os.Getenv("QRCODE_PRIVATE_KEY_PEM"),
 		QRCodeDefaultTTLSeconds:   mustInt64(getenv("QRCODE_DEFAULT_TTL_SECONDS", "300")),
 		QRCodePNGSize:             mustInt(getenv("QRCODE_PNG_SIZE", "320")),
+
+		NotifyTplTournamentStart:    os.Getenv("NOTIFY_TPL_TOURNAMENT_START"),
+		NotifyTplTournamentResult:   os.Getenv("NOTIFY_TPL_TOURNAMENT_RESULT"),
+		NotifyTplRedeemUsed:         os.Getenv("NOTIFY_TPL_REDEEM_USED"),
+		NotifyTplVipExpiring:        os.Getenv("NOTIFY_TPL_VIP_EXPIRING"),
+		NotifyMiniprogramState:      getenv("NOTIFY_MINIPROGRAM_STATE", "formal"),
+		NotifyMaxAttempts:           mustInt(getenv("NOTIFY_MAX_ATTEMPTS", "5")),
+		NotifyTournamentLeadMinutes: mustInt64(getenv("NOTIFY_TOURNAMENT_LEAD_MINUTES", "60")),
+		NotifyVipExpireDays:         mustInt64(getenv("NOTIFY_VIP_EXPIRE_DAYS", "3")),
+
+		PointsAdjustApprovalThreshold: mustInt64(getenv("POINTS_ADJUST_APPROVAL_THRESHOLD", "1000")),
+		PointsExpireMonths:            mustInt(getenv("POINTS_EXPIRE_MONTHS", "12")),
+		PointsReconcileIntervalHours:  mustInt64(getenv("POINTS_RECONCILE_INTERVAL_HOURS", "24")),
+
+		PointsTransferDailyLimit:        mustInt64(getenv("POINTS_TRANSFER_DAILY_LIMIT", "1000")),
+		PointsTransferDailyCount:        mustInt(getenv("POINTS_TRANSFER_DAILY_COUNT", "5")),
+		PointsTransferDailyReceiveLimit: mustInt64(getenv("POINTS_TRANSFER_DAILY_RECEIVE_LIMIT", "2000")),
+		PointsTransferMinAccountDays:    mustInt(getenv("POINTS_TRANSFER_MIN_ACCOUNT_DAYS", "7")),
+
+		RedeemPickupQRTTLSeconds: mustInt64(getenv("REDEEM_PICKUP_QR_TTL_SECONDS", "120")),
+		RedeemPickupWindowHours:  mustInt(getenv("REDEEM_PICKUP_WINDOW_HOURS", "168")),
+
+		JobsEnabled: mustBool(getenv("JOBS_ENABLED", "true")),
 	}
 
 	if cfg.ServerPort <= 0 {
@@ -108,9 +257,66 @@ func LoadConfig() (Config, error) {
 	if cfg.DBPort <= 0 {
 		return Config{}, fmt.Errorf("invalid DB_PORT")
 	}
+	if cfg.DevLoginEnabled && len(cfg.DevLoginOpenIDs) == 0 {
+		return Config{}, fmt.Errorf("DEV_LOGIN_ENABLED=true requires DEV_LOGIN_OPENIDS")
+	}
+	if cfg.WechatFakeCode2Session && !cfg.DevLoginEnabled {
+		return Config{}, fmt.Errorf("WECHAT_FAKE_CODE2SESSION=true requires DEV_LOGIN_ENABLED=true")
+	}
 	if cfg.AuthTokenTTLSeconds <= 0 {
 		return Config{}, fmt.Errorf("invalid AUTH_TOKEN_TTL_SECONDS")
 	}
+	if cfg.AuthRefreshTokenTTLSeconds < cfg.AuthTokenTTLSeconds {
+		return Config{}, fmt.Errorf("AUTH_REFRESH_TOKEN_TTL_SECONDS must be >= AUTH_TOKEN_TTL_SECONDS")
+	}
+	if cfg.AuthStatusCacheTTLSeconds < 0 {
+		return Config{}, fmt.Errorf("invalid AUTH_STATUS_CACHE_TTL_SECONDS")
+	}
+	switch cfg.AuthTokenAlg {
+	case "HS256", "EdDSA", "RS256":
+	default:
+		return Config{}, fmt.Errorf("invalid AUTH_TOKEN_ALG (want HS256/EdDSA/RS256)")
+	}
+	if cfg.AdminTokenTTLSeconds <= 0 {
+		return Config{}, fmt.Errorf("invalid ADMIN_TOKEN_TTL_SECONDS")
+	}
+	if cfg.AdminTokenSecret != "" && cfg.AdminTokenSecret == cfg.AuthTokenSecret {
+		return Config{}, fmt.Errorf("ADMIN_TOKEN_SECRET must differ from AUTH_TOKEN_SECRET")
+	}
+	switch cfg.RateLimitBackend {
+	case "memory":
+	case "mysql":
+		if !cfg.DBEnabled {
+			return Config{}, fmt.Errorf("RATE_LIMIT_BACKEND=mysql requires DB_ENABLED=true")
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid RATE_LIMIT_BACKEND (want memory/mysql)")
+	}
+	// 窗口上限 1 天：mysql 存储会定期清理 1 天前的命中记录。
+	if cfg.LoginRateLimitWindowSeconds <= 0 || cfg.LoginRateLimitWindowSeconds > 86400 {
+		return Config{}, fmt.Errorf("invalid LOGIN_RATE_LIMIT_WINDOW_SECONDS")
+	}
+	if cfg.AdminLoginFailureWindowSeconds <= 0 || cfg.AdminLoginFailureWindowSeconds > 86400 {
+		return Config{}, fmt.Errorf("invalid ADMIN_LOGIN_FAILURE_WINDOW_SECONDS")
+	}
+	if cfg.AdminLoginLockoutSeconds <= 0 || cfg.AdminLoginLockoutSeconds > 86400 {
+		return Config{}, fmt.Errorf("invalid ADMIN_LOGIN_LOCKOUT_SECONDS")
+	}
+	if cfg.LoginRateLimitPerIP < 0 || cfg.LoginRateLimitPerIdentity < 0 || cfg.AdminLoginMaxFailures < 0 {
+		return Config{}, fmt.Errorf("login rate limits must be >= 0")
+	}
+	switch cfg.IdempotencyBackend {
+	case "memory":
+	case "mysql":
+		if !cfg.DBEnabled {
+			return Config{}, fmt.Errorf("IDEMPOTENCY_BACKEND=mysql requires DB_ENABLED=true")
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid IDEMPOTENCY_BACKEND (want memory/mysql)")
+	}
+	if cfg.IdempotencyTTLSeconds <= 0 || cfg.IdempotencyTTLSeconds > 7*86400 {
+		return Config{}, fmt.Errorf("invalid IDEMPOTENCY_TTL_SECONDS")
+	}
 	if cfg.MediaMaxUploadMB <= 0 {
 		return Config{}, fmt.Errorf("invalid MEDIA_MAX_UPLOAD_MB")
 	}
@@ -120,6 +326,47 @@ func LoadConfig() (Config, error) {
 	if cfg.QRCodePNGSize <= 0 {
 		return Config{}, fmt.Errorf("invalid QRCODE_PNG_SIZE")
 	}
+	switch cfg.NotifyMiniprogramState {
+	case "developer", "trial", "formal":
+	default:
+		return Config{}, fmt.Errorf("invalid NOTIFY_MINIPROGRAM_STATE (want developer/trial/formal)")
+	}
+	if cfg.NotifyMaxAttempts <= 0 {
+		return Config{}, fmt.Errorf("invalid NOTIFY_MAX_ATTEMPTS")
+	}
+	if cfg.NotifyTournamentLeadMinutes <= 0 {
+		return Config{}, fmt.Errorf("invalid NOTIFY_TOURNAMENT_LEAD_MINUTES")
+	}
+	if cfg.NotifyVipExpireDays <= 0 {
+		return Config{}, fmt.Errorf("invalid NOTIFY_VIP_EXPIRE_DAYS")
+	}
+	if cfg.PointsAdjustApprovalThreshold <= 0 {
+		return Config{}, fmt.Errorf("invalid POINTS_ADJUST_APPROVAL_THRESHOLD")
+	}
+	if cfg.PointsExpireMonths <= 0 {
+		return Config{}, fmt.Errorf("invalid POINTS_EXPIRE_MONTHS")
+	}
+	if cfg.PointsReconcileIntervalHours < 0 {
+		return Config{}, fmt.Errorf("invalid POINTS_RECONCILE_INTERVAL_HOURS")
+	}
+	if cfg.PointsTransferDailyLimit < 0 {
+		return Config{}, fmt.Errorf("invalid POINTS_TRANSFER_DAILY_LIMIT")
+	}
+	if cfg.PointsTransferDailyCount < 0 {
+		return Config{}, fmt.Errorf("invalid POINTS_TRANSFER_DAILY_COUNT")
+	}
+	if cfg.PointsTransferDailyReceiveLimit < 0 {
+		return Config{}, fmt.Errorf("invalid POINTS_TRANSFER_DAILY_RECEIVE_LIMIT")
+	}
+	if cfg.PointsTransferMinAccountDays < 0 {
+		return Config{}, fmt.Errorf("invalid POINTS_TRANSFER_MIN_ACCOUNT_DAYS")
+	}
+	if cfg.RedeemPickupQRTTLSeconds <= 0 {
+		return Config{}, fmt.Errorf("invalid REDEEM_PICKUP_QR_TTL_SECONDS")
+	}
+	if cfg.RedeemPickupWindowHours < 0 {
+		return Config{}, fmt.Errorf("invalid REDEEM_PICKUP_WINDOW_HOURS")
+	}
 
 	return cfg, nil
 }
@@ -171,6 +418,17 @@ func mustInt64(v string) int64 {
 	return n
 }
 
+// splitList 按逗号拆分配置项并去除空白与空项。
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 // mustBool 将字符串转为 bool；解析失败时返回 false。
 func mustBool(v string) bool {
 	b, err := strconv.ParseBool(strings.TrimSpace(v))
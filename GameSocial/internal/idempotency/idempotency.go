@@ -0,0 +1,36 @@
+// idempotency 提供基于 Idempotency-Key 的请求去重存储：首个请求占位执行，完成后保存响应供重复请求回放。
+// 存储可插拔（进程内 / MySQL 共享），与 ratelimit 一致。
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+// Response 是保存下来用于回放的响应。
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Record 是某个 key 的当前状态。
+type Record struct {
+	// Fingerprint: 首个请求的请求指纹（method+path+body 的哈希），用于识别 key 被复用到不同请求。
+	Fingerprint string
+	// Done: 首个请求已完成（Response 可回放）；为 false 表示仍在处理中。
+	Done     bool
+	Response Response
+}
+
+// Store 定义幂等记录的存储抽象。
+// 单实例可用 Memory；多实例部署需使用共享存储（如 MySQL），否则重复请求落到不同实例时无法去重。
+type Store interface {
+	// Claim 尝试占用 key：成功返回 claimed=true（占位在 lockTTL 后过期，防止进程崩溃后 key 永久卡住）；
+	// key 已存在且未过期时返回已有记录。
+	Claim(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (rec Record, claimed bool, err error)
+	// Complete 保存响应，并把过期时间延长为 ttl。
+	Complete(ctx context.Context, key string, resp Response, ttl time.Duration) error
+	// Release 删除占位（请求失败时调用，允许客户端用同一 key 重试）。
+	Release(ctx context.Context, key string) error
+}
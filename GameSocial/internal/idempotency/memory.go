@@ -0,0 +1,72 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery 表示每占用多少次触发一次全量过期清理，避免 map 无限增长。
+const sweepEvery = 1024
+
+type memoryEntry struct {
+	rec       Record
+	expiresAt time.Time
+}
+
+// Memory 是进程内的幂等记录存储（仅适用于单实例部署）。
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	claims  int
+}
+
+// NewMemory 创建进程内存储。
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]*memoryEntry)}
+}
+
+// Claim 尝试占用 key。
+func (m *Memory) Claim(_ context.Context, key, fingerprint string, lockTTL time.Duration) (Record, bool, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e := m.entries[key]; e != nil && now.Before(e.expiresAt) {
+		return e.rec, false, nil
+	}
+	m.entries[key] = &memoryEntry{rec: Record{Fingerprint: fingerprint}, expiresAt: now.Add(lockTTL)}
+
+	m.claims++
+	if m.claims%sweepEvery == 0 {
+		for k, e := range m.entries {
+			if !now.Before(e.expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+	}
+	return Record{}, true, nil
+}
+
+// Complete 保存响应。
+func (m *Memory) Complete(_ context.Context, key string, resp Response, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.entries[key]
+	if e == nil {
+		return nil
+	}
+	e.rec.Done = true
+	e.rec.Response = resp
+	e.expiresAt = time.Now().Add(ttl)
+	return nil
+}
+
+// Release 删除占位。
+func (m *Memory) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
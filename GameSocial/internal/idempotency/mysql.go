@@ -0,0 +1,106 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// MySQL 使用 idempotency_record 表保存幂等记录，供多实例共享。
+// 时间统一取数据库 NOW(3)，避免各实例时钟偏差。
+type MySQL struct {
+	db     *sql.DB
+	claims atomic.Int64
+}
+
+// NewMySQL 创建 MySQL 共享存储。
+func NewMySQL(db *sql.DB) *MySQL {
+	return &MySQL{db: db}
+}
+
+// Claim 尝试占用 key：先清理该 key 已过期的记录，再用主键冲突保证只有一个请求占位成功。
+func (m *MySQL) Claim(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (Record, bool, error) {
+	if m.db == nil {
+		return Record{}, false, errors.New("database disabled")
+	}
+	hash := keyHash(key)
+	if m.claims.Add(1)%sweepEvery == 0 {
+		_, _ = m.db.ExecContext(ctx, `
+			DELETE FROM idempotency_record WHERE expires_at <= NOW(3) LIMIT 1000
+		`)
+	}
+
+	// 已过期的记录被删除后可能与并发请求交错，最多重试一次。
+	for attempt := 0; attempt < 2; attempt++ {
+		if _, err := m.db.ExecContext(ctx, `
+			DELETE FROM idempotency_record WHERE key_hash = ? AND expires_at <= NOW(3)
+		`, hash); err != nil {
+			return Record{}, false, err
+		}
+		res, err := m.db.ExecContext(ctx, `
+			INSERT IGNORE INTO idempotency_record (key_hash, fingerprint, state, expires_at, created_at)
+			VALUES (?, ?, 'PROCESSING', NOW(3) + INTERVAL ? MICROSECOND, NOW(3))
+		`, hash, fingerprint, lockTTL.Microseconds())
+		if err != nil {
+			return Record{}, false, err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			return Record{}, true, nil
+		}
+
+		var (
+			rec         Record
+			state       string
+			status      sql.NullInt64
+			contentType sql.NullString
+		)
+		err = m.db.QueryRowContext(ctx, `
+			SELECT fingerprint, state, status_code, content_type, body
+			FROM idempotency_record
+			WHERE key_hash = ? AND expires_at > NOW(3)
+		`, hash).Scan(&rec.Fingerprint, &state, &status, &contentType, &rec.Response.Body)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, err
+		}
+		rec.Done = state == "DONE"
+		rec.Response.Status = int(status.Int64)
+		rec.Response.ContentType = contentType.String
+		return rec, false, nil
+	}
+	return Record{}, false, errors.New("idempotency key contended")
+}
+
+// Complete 保存响应。
+func (m *MySQL) Complete(ctx context.Context, key string, resp Response, ttl time.Duration) error {
+	if m.db == nil {
+		return errors.New("database disabled")
+	}
+	_, err := m.db.ExecContext(ctx, `
+		UPDATE idempotency_record
+		SET state = 'DONE', status_code = ?, content_type = NULLIF(?, ''), body = ?, expires_at = NOW(3) + INTERVAL ? MICROSECOND
+		WHERE key_hash = ?
+	`, resp.Status, resp.ContentType, resp.Body, ttl.Microseconds(), keyHash(key))
+	return err
+}
+
+// Release 删除占位。
+func (m *MySQL) Release(ctx context.Context, key string) error {
+	if m.db == nil {
+		return errors.New("database disabled")
+	}
+	_, err := m.db.ExecContext(ctx, `DELETE FROM idempotency_record WHERE key_hash = ?`, keyHash(key))
+	return err
+}
+
+// keyHash 将 key（含用户 ID 与路由）映射为定长哈希，便于建主键且不落原文。
+func keyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
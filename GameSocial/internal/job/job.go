@@ -0,0 +1,77 @@
+// job 提供进程内的定时任务调度（按固定间隔执行，带 panic 恢复与错误日志）。
+package job
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Func 是一次任务执行；返回的 error 只记录日志，不影响下次调度。
+type Func func(ctx context.Context) error
+
+type entry struct {
+	name     string
+	interval time.Duration
+	fn       Func
+}
+
+// Runner 管理一组定时任务。
+// 多实例部署时每个实例都会执行；任务本身需保证幂等（如条件更新/唯一键去重）。
+type Runner struct {
+	jobs []entry
+	wg   sync.WaitGroup
+}
+
+// NewRunner 创建任务调度器。
+func NewRunner() *Runner {
+	return &Runner{}
+}
+
+// Every 注册一个每隔 interval 执行一次的任务（Start 后立即执行第一次）。
+func (r *Runner) Every(name string, interval time.Duration, fn Func) {
+	if interval <= 0 || fn == nil {
+		return
+	}
+	r.jobs = append(r.jobs, entry{name: name, interval: interval, fn: fn})
+}
+
+// Start 为每个任务启动一个 goroutine；ctx 取消后停止调度（正在执行的任务会收到取消信号）。
+func (r *Runner) Start(ctx context.Context) {
+	for _, e := range r.jobs {
+		r.wg.Add(1)
+		go func(e entry) {
+			defer r.wg.Done()
+			ticker := time.NewTicker(e.interval)
+			defer ticker.Stop()
+			for {
+				r.runOnce(ctx, e)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(e)
+	}
+}
+
+// Wait 等待所有任务 goroutine 退出（需先取消 Start 的 ctx）。
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+func (r *Runner) runOnce(ctx context.Context, e entry) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("job %s panic: %v", e.name, rec)
+		}
+	}()
+	if ctx.Err() != nil {
+		return
+	}
+	if err := e.fn(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("job %s: %v", e.name, err)
+	}
+}
@@ -0,0 +1,53 @@
+package pii
+
+import "strings"
+
+// NormalizePhone 规范化手机号用于盲索引：去掉空白/横杠，+86/0086 前缀的大陆号码只保留 11 位本地号码，
+// 其他国家/地区保留 "+区号" 前缀。无法识别时返回空串。
+func NormalizePhone(countryCode, phone string) string {
+	digits := func(s string) string {
+		var b strings.Builder
+		for _, r := range s {
+			if r >= '0' && r <= '9' {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	}
+	cc := digits(countryCode)
+	p := strings.TrimSpace(phone)
+	if strings.HasPrefix(p, "+") || strings.HasPrefix(p, "00") {
+		// 带国际前缀的完整号码：拆出区号（目前只识别 86，其余原样保留）。
+		full := strings.TrimPrefix(digits(p), "00")
+		if strings.HasPrefix(full, "86") && len(full) == 13 {
+			return full[2:]
+		}
+		if full == "" {
+			return ""
+		}
+		return "+" + full
+	}
+	p = digits(p)
+	if p == "" {
+		return ""
+	}
+	if cc == "" || cc == "86" {
+		if len(p) != 11 || p[0] != '1' {
+			return ""
+		}
+		return p
+	}
+	return "+" + cc + p
+}
+
+// MaskPhone 脱敏展示手机号：保留前 3 位与后 4 位，例如 138****1234。
+func MaskPhone(phone string) string {
+	r := []rune(phone)
+	if len(r) <= 7 {
+		if len(r) <= 2 {
+			return strings.Repeat("*", len(r))
+		}
+		return string(r[:1]) + strings.Repeat("*", len(r)-2) + string(r[len(r)-1:])
+	}
+	return string(r[:3]) + strings.Repeat("*", len(r)-7) + string(r[len(r)-4:])
+}
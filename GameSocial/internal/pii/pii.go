@@ -0,0 +1,100 @@
+// pii 提供个人敏感信息（如手机号）的落库加密、可检索的盲索引与展示脱敏。
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize 是主密钥长度（字节）。
+const KeySize = 32
+
+// ciphertextPrefix 标识密文格式版本，便于以后更换算法/密钥时区分旧数据。
+const ciphertextPrefix = "v1:"
+
+// ErrInvalidCiphertext 表示密文格式错误或被篡改。
+var ErrInvalidCiphertext = errors.New("pii: invalid ciphertext")
+
+// Cipher 使用 AES-256-GCM 加密敏感字段，并用 HMAC-SHA256 生成盲索引（等值检索用，不可逆）。
+// 加密密钥与索引密钥由同一主密钥按用途派生，互不相同。
+type Cipher struct {
+	aead     cipher.AEAD
+	indexKey []byte
+}
+
+// ParseKey 解析 base64 编码的 32 字节主密钥（可用 `openssl rand -base64 32` 生成）。
+func ParseKey(v string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+	if err != nil {
+		return nil, fmt.Errorf("pii key: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("pii key: want %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// New 根据主密钥创建 Cipher。
+func New(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("pii key: want %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(derive(key, "encrypt"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead, indexKey: derive(key, "blind-index")}, nil
+}
+
+// Encrypt 加密明文，返回 "v1:" + base64(nonce || ciphertext)；同一明文每次结果不同。
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return ciphertextPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密 Encrypt 的结果。
+func (c *Cipher) Decrypt(ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, ciphertextPrefix) {
+		return "", ErrInvalidCiphertext
+	}
+	raw, err := base64.StdEncoding.DecodeString(ciphertext[len(ciphertextPrefix):])
+	if err != nil || len(raw) < c.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+	nonce, sealed := raw[:c.aead.NonceSize()], raw[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plain), nil
+}
+
+// BlindIndex 返回明文的 HMAC-SHA256（hex），用于按等值查询；调用方需先规范化明文。
+func (c *Cipher) BlindIndex(plaintext string) string {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(plaintext))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// derive 按用途从主密钥派生子密钥。
+func derive(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("gamesocial/pii/" + purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery 表示每记录多少次命中触发一次全量过期清理，避免 map 无限增长。
+const sweepEvery = 1024
+
+type memoryBucket struct {
+	hits   []time.Time
+	window time.Duration
+
+	// lockedUntil: 失败锁定的解锁时间（零值表示未锁定）。
+	lockedUntil time.Time
+	// deniedUntil: 当前限流拒绝期的结束时间，用于识别进入拒绝期后的首次拒绝。
+	deniedUntil time.Time
+}
+
+// Memory 是进程内的滑动日志存储（仅适用于单实例部署）。
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	hits    int
+}
+
+// NewMemory 创建进程内存储。
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*memoryBucket)}
+}
+
+// Take 在互斥锁内完成判定与记录。
+func (m *Memory) Take(_ context.Context, key string, rule Rule) (Decision, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := m.bucket(key, rule.Window, now)
+	if w := b.stat(now); w.Count >= rule.Limit {
+		entered := !now.Before(b.deniedUntil)
+		if entered {
+			b.deniedUntil = now.Add(w.ResetIn)
+		}
+		return Decision{Allowed: false, RetryAfter: w.ResetIn, Entered: entered}, nil
+	}
+	b.hits = append(b.hits, now)
+	m.sweep(now)
+	return Decision{Allowed: true}, nil
+}
+
+// Hit 记录一次命中。
+func (m *Memory) Hit(_ context.Context, key string, window time.Duration) (Window, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := m.bucket(key, window, now)
+	b.hits = append(b.hits, now)
+	m.sweep(now)
+	return b.stat(now), nil
+}
+
+// Lock 锁定 key 并清空命中记录。
+func (m *Memory) Lock(_ context.Context, key string, d time.Duration) (bool, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := m.buckets[key]
+	if b == nil {
+		b = &memoryBucket{}
+		m.buckets[key] = b
+	}
+	if now.Before(b.lockedUntil) {
+		return false, nil
+	}
+	b.lockedUntil = now.Add(d)
+	b.hits = b.hits[:0]
+	return true, nil
+}
+
+// LockedFor 返回剩余锁定时间。
+func (m *Memory) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := m.buckets[key]
+	if b == nil || !now.Before(b.lockedUntil) {
+		return 0, nil
+	}
+	return b.lockedUntil.Sub(now), nil
+}
+
+// Reset 清空 key 的命中记录与锁定状态。
+func (m *Memory) Reset(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.buckets, key)
+	return nil
+}
+
+// bucket 取出（必要时创建）key 的桶并移除已滑出窗口的命中；调用方需持有 m.mu。
+func (m *Memory) bucket(key string, window time.Duration, now time.Time) *memoryBucket {
+	b := m.buckets[key]
+	if b == nil {
+		b = &memoryBucket{}
+		m.buckets[key] = b
+	}
+	b.window = window
+	b.prune(now)
+	return b
+}
+
+// sweep 每 sweepEvery 次命中做一次全量过期清理；调用方需持有 m.mu。
+func (m *Memory) sweep(now time.Time) {
+	m.hits++
+	if m.hits%sweepEvery != 0 {
+		return
+	}
+	for k, it := range m.buckets {
+		if it.prune(now); len(it.hits) == 0 && !now.Before(it.lockedUntil) {
+			delete(m.buckets, k)
+		}
+	}
+}
+
+// prune 移除已滑出窗口的命中（hits 按时间递增）。
+func (b *memoryBucket) prune(now time.Time) {
+	cutoff := now.Add(-b.window)
+	i := 0
+	for i < len(b.hits) && !b.hits[i].After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		b.hits = append(b.hits[:0], b.hits[i:]...)
+	}
+}
+
+func (b *memoryBucket) stat(now time.Time) Window {
+	if len(b.hits) == 0 {
+		return Window{}
+	}
+	return Window{Count: len(b.hits), ResetIn: b.hits[0].Add(b.window).Sub(now)}
+}
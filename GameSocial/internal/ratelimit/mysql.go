@@ -0,0 +1,234 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// mysqlRetention 是全量清理时保留的最长时间（规则窗口不应超过该值）。
+const mysqlRetention = 24 * time.Hour
+
+// MySQL 使用 rate_limit_hit 表保存命中记录，供多实例共享计数；rate_limit_bucket 表提供按 key 串行的锁行并保存失败锁定的解锁时间。
+// 时间统一取数据库 NOW(3)，避免各实例时钟偏差。
+type MySQL struct {
+	db   *sql.DB
+	hits atomic.Int64
+}
+
+// NewMySQL 创建 MySQL 共享存储。
+func NewMySQL(db *sql.DB) *MySQL {
+	return &MySQL{db: db}
+}
+
+// queryer 是 *sql.DB 与 *sql.Tx 共有的单行查询方法。
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Take 在一个事务内完成判定与记录：先锁定 rate_limit_bucket 中该 key 的行（不存在则创建），
+// 同一 key 的并发请求在此排队，锁内统计窗口命中数后再决定是否写入本次命中。
+func (m *MySQL) Take(ctx context.Context, key string, rule Rule) (Decision, error) {
+	if m.db == nil {
+		return Decision{}, errors.New("database disabled")
+	}
+	bucket := bucketKey(key)
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Decision{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 1) 锁定 bucket 行（ON DUPLICATE KEY UPDATE 直接加排他锁，避免先加共享锁再升级导致死锁）。
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO rate_limit_bucket (bucket, updated_at) VALUES (?, NOW(3))
+		ON DUPLICATE KEY UPDATE updated_at = NOW(3)
+	`, bucket); err != nil {
+		return Decision{}, err
+	}
+
+	// 2) 锁内统计窗口命中数（快照在拿到锁之后建立，能看到前一个持锁者已提交的命中）。
+	w, err := m.peek(ctx, tx, bucket, rule.Window)
+	if err != nil {
+		return Decision{}, err
+	}
+	if w.Count >= rule.Limit {
+		// 拒绝：仅在未处于拒绝期时写入拒绝期结束时间，命中即为进入拒绝期后的首次拒绝。
+		res, err := tx.ExecContext(ctx, `
+			UPDATE rate_limit_bucket
+			SET denied_until = NOW(3) + INTERVAL ? MICROSECOND
+			WHERE bucket = ? AND (denied_until IS NULL OR denied_until <= NOW(3))
+		`, w.ResetIn.Microseconds(), bucket)
+		if err != nil {
+			return Decision{}, err
+		}
+		n, _ := res.RowsAffected()
+		if err := tx.Commit(); err != nil {
+			return Decision{}, err
+		}
+		return Decision{Allowed: false, RetryAfter: w.ResetIn, Entered: n > 0}, nil
+	}
+
+	// 3) 未达上限：记录本次命中并清理已滑出窗口的记录。
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO rate_limit_hit (bucket, hit_at) VALUES (?, NOW(3))
+	`, bucket); err != nil {
+		return Decision{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM rate_limit_hit
+		WHERE bucket = ? AND hit_at <= NOW(3) - INTERVAL ? MICROSECOND
+	`, bucket, rule.Window.Microseconds()); err != nil {
+		return Decision{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Decision{}, err
+	}
+	m.sweep(ctx)
+	return Decision{Allowed: true}, nil
+}
+
+// Hit 记录一次命中，并顺带清理该 key 已滑出窗口的记录。
+func (m *MySQL) Hit(ctx context.Context, key string, window time.Duration) (Window, error) {
+	if m.db == nil {
+		return Window{}, errors.New("database disabled")
+	}
+	bucket := bucketKey(key)
+	if _, err := m.db.ExecContext(ctx, `
+		INSERT INTO rate_limit_hit (bucket, hit_at) VALUES (?, NOW(3))
+	`, bucket); err != nil {
+		return Window{}, err
+	}
+	if _, err := m.db.ExecContext(ctx, `
+		DELETE FROM rate_limit_hit
+		WHERE bucket = ? AND hit_at <= NOW(3) - INTERVAL ? MICROSECOND
+	`, bucket, window.Microseconds()); err != nil {
+		return Window{}, err
+	}
+	m.sweep(ctx)
+	return m.peek(ctx, m.db, bucket, window)
+}
+
+// Lock 在事务内锁定 bucket 行后写入解锁时间（NOW(3)+d），并清空该 key 的命中记录。
+func (m *MySQL) Lock(ctx context.Context, key string, d time.Duration) (bool, error) {
+	if m.db == nil {
+		return false, errors.New("database disabled")
+	}
+	bucket := bucketKey(key)
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 1) 锁定 bucket 行（不存在则创建）。
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO rate_limit_bucket (bucket, updated_at) VALUES (?, NOW(3))
+		ON DUPLICATE KEY UPDATE updated_at = NOW(3)
+	`, bucket); err != nil {
+		return false, err
+	}
+
+	// 2) 仅在未处于锁定期时写入解锁时间，已锁定的不延长。
+	res, err := tx.ExecContext(ctx, `
+		UPDATE rate_limit_bucket
+		SET locked_until = NOW(3) + INTERVAL ? MICROSECOND
+		WHERE bucket = ? AND (locked_until IS NULL OR locked_until <= NOW(3))
+	`, d.Microseconds(), bucket)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	// 3) 清空失败计数：解锁后重新累计。
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rate_limit_hit WHERE bucket = ?`, bucket); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// LockedFor 返回剩余锁定时间。
+func (m *MySQL) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	if m.db == nil {
+		return 0, errors.New("database disabled")
+	}
+	var remainUS sql.NullInt64
+	err := m.db.QueryRowContext(ctx, `
+		SELECT TIMESTAMPDIFF(MICROSECOND, NOW(3), locked_until)
+		FROM rate_limit_bucket
+		WHERE bucket = ?
+	`, bucketKey(key)).Scan(&remainUS)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !remainUS.Valid || remainUS.Int64 <= 0 {
+		return 0, nil
+	}
+	return time.Duration(remainUS.Int64) * time.Microsecond, nil
+}
+
+func (m *MySQL) peek(ctx context.Context, q queryer, bucket string, window time.Duration) (Window, error) {
+	var (
+		count   int
+		resetUS sql.NullInt64
+	)
+	err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*), TIMESTAMPDIFF(MICROSECOND, NOW(3), MIN(hit_at) + INTERVAL ? MICROSECOND)
+		FROM rate_limit_hit
+		WHERE bucket = ? AND hit_at > NOW(3) - INTERVAL ? MICROSECOND
+	`, window.Microseconds(), bucket, window.Microseconds()).Scan(&count, &resetUS)
+	if err != nil {
+		return Window{}, err
+	}
+	w := Window{Count: count}
+	if resetUS.Valid && resetUS.Int64 > 0 {
+		w.ResetIn = time.Duration(resetUS.Int64) * time.Microsecond
+	}
+	return w, nil
+}
+
+// Reset 清空 key 的命中记录与锁定状态。
+func (m *MySQL) Reset(ctx context.Context, key string) error {
+	if m.db == nil {
+		return errors.New("database disabled")
+	}
+	bucket := bucketKey(key)
+	if _, err := m.db.ExecContext(ctx, `DELETE FROM rate_limit_hit WHERE bucket = ?`, bucket); err != nil {
+		return err
+	}
+	_, err := m.db.ExecContext(ctx, `DELETE FROM rate_limit_bucket WHERE bucket = ?`, bucket)
+	return err
+}
+
+// sweep 每 sweepEvery 次命中做一次全量过期清理（尽力而为，失败忽略）。
+func (m *MySQL) sweep(ctx context.Context) {
+	if m.hits.Add(1)%sweepEvery != 0 {
+		return
+	}
+	_, _ = m.db.ExecContext(ctx, `
+		DELETE FROM rate_limit_hit WHERE hit_at < NOW(3) - INTERVAL ? MICROSECOND LIMIT 1000
+	`, mysqlRetention.Microseconds())
+	_, _ = m.db.ExecContext(ctx, `
+		DELETE FROM rate_limit_bucket
+		WHERE updated_at < NOW(3) - INTERVAL ? MICROSECOND AND (locked_until IS NULL OR locked_until <= NOW(3))
+		LIMIT 1000
+	`, mysqlRetention.Microseconds())
+}
+
+// bucketKey 将任意 key 映射为定长哈希，避免 openid/用户名等原文落库且便于建索引。
+func bucketKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
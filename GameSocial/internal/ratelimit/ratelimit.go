@@ -0,0 +1,114 @@
+// ratelimit 提供滑动窗口限流与失败锁定能力；计数存储可插拔（进程内 / MySQL 共享）。
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Window 表示某个 key 在滑动窗口内的命中情况。
+type Window struct {
+	// Count: 窗口内命中次数。
+	Count int
+	// ResetIn: 最早一次命中移出窗口还需的时间（Count=0 时为 0）。
+	ResetIn time.Duration
+}
+
+// Store 定义限流计数的存储抽象（滑动日志：记录每次命中时间）。
+// 单实例可用 Memory；多实例部署需使用共享存储（如 MySQL），否则各实例分别计数。
+type Store interface {
+	// Take 原子地完成“判定 + 记录”：窗口内命中数未达 rule.Limit 时记一次命中并放行，否则拒绝且不计数
+	// （进入拒绝期后的首次拒绝置 Entered，拒绝期持续到窗口内最早的命中滑出）。
+	// 判定与记录在同一临界区内（进程内互斥锁 / 数据库事务），并发请求不会同时越过上限。
+	Take(ctx context.Context, key string, rule Rule) (Decision, error)
+	// Hit 记录一次命中，返回包含本次在内的窗口统计。
+	Hit(ctx context.Context, key string, window time.Duration) (Window, error)
+	// Lock 将 key 锁定 d（记录明确的解锁时间）并清空其命中记录；已处于锁定期时不延长，返回 false。
+	Lock(ctx context.Context, key string, d time.Duration) (bool, error)
+	// LockedFor 返回 key 的剩余锁定时间（未锁定为 0）。
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// Reset 清空 key 的全部命中记录与锁定状态。
+	Reset(ctx context.Context, key string) error
+}
+
+// Rule 表示一条限流规则：Window 内最多 Limit 次；Limit<=0 表示不限制。
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Enabled 返回规则是否生效。
+func (r Rule) Enabled() bool {
+	return r.Limit > 0 && r.Window > 0
+}
+
+// Decision 表示一次限流判定结果。
+type Decision struct {
+	Allowed bool
+	// RetryAfter: 被拒绝时建议的重试等待时间。
+	RetryAfter time.Duration
+	// Entered: 被拒绝时表示本次判定使 key 刚进入拒绝状态（限流期/锁定期内的首次拒绝），
+	// 调用方据此只在状态切换时写审计，避免每次拒绝都落库。
+	Entered bool
+}
+
+// Limiter 基于 Store 实现“请求限流”与“失败锁定”两类判定。
+type Limiter struct {
+	store Store
+}
+
+// New 创建限流器。
+func New(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// Allow 判定一次请求是否放行：窗口内已达上限则拒绝（被拒绝的请求不计数），否则记一次命中。
+func (l *Limiter) Allow(ctx context.Context, key string, rule Rule) (Decision, error) {
+	if l == nil || !rule.Enabled() {
+		return Decision{Allowed: true}, nil
+	}
+	return l.store.Take(ctx, key, rule)
+}
+
+// Locked 判定 key 是否处于失败锁定期（以 Fail 记录的解锁时间为准，与失败计数窗口无关）。
+func (l *Limiter) Locked(ctx context.Context, key string) (Decision, error) {
+	if l == nil {
+		return Decision{Allowed: true}, nil
+	}
+	d, err := l.store.LockedFor(ctx, key)
+	if err != nil {
+		return Decision{}, err
+	}
+	if d > 0 {
+		return Decision{Allowed: false, RetryAfter: d}, nil
+	}
+	return Decision{Allowed: true}, nil
+}
+
+// Fail 记录一次失败：rule.Window 内失败达到 rule.Limit 次时锁定 key lockFor（并清空失败计数）；
+// 返回值表示记录后是否处于锁定状态，Entered 表示本次失败触发了锁定。
+func (l *Limiter) Fail(ctx context.Context, key string, rule Rule, lockFor time.Duration) (Decision, error) {
+	if l == nil || !rule.Enabled() || lockFor <= 0 {
+		return Decision{Allowed: true}, nil
+	}
+	w, err := l.store.Hit(ctx, key, rule.Window)
+	if err != nil {
+		return Decision{}, err
+	}
+	if w.Count < rule.Limit {
+		return Decision{Allowed: true}, nil
+	}
+	entered, err := l.store.Lock(ctx, key, lockFor)
+	if err != nil {
+		return Decision{}, err
+	}
+	return Decision{Allowed: false, RetryAfter: lockFor, Entered: entered}, nil
+}
+
+// Reset 清空 key 的计数与锁定状态（例如登录成功后清除失败记录）。
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	if l == nil {
+		return nil
+	}
+	return l.store.Reset(ctx, key)
+}
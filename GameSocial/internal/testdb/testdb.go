@@ -0,0 +1,30 @@
+// testdb 为依赖真实 MySQL 的测试提供数据库连接：未设置 GAMESOCIAL_TEST_DSN 时跳过测试。
+// 测试会写入数据，请指向已用 gamesocial_init.sql 初始化的专用测试库（DSN 需带 parseTime=true）。
+package testdb
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+
+	"gamesocial/internal/database"
+)
+
+// EnvDSN 是测试库 DSN 的环境变量名。
+const EnvDSN = "GAMESOCIAL_TEST_DSN"
+
+// Open 连接测试库，测试结束时自动关闭；未配置 DSN 时调用 t.Skip。
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	dsn := strings.TrimSpace(os.Getenv(EnvDSN))
+	if dsn == "" {
+		t.Skipf("%s not set, skipping MySQL-backed test", EnvDSN)
+	}
+	db, err := database.InitMySQL(database.DBConfig{DSN: dsn})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
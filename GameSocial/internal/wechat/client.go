@@ -2,22 +2,54 @@
 package wechat
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
+// DefaultBaseURL 是微信开放接口的正式地址。
+const DefaultBaseURL = "https://api.weixin.qq.com"
+
+// SessionProvider 抽象 code2session 能力：线上使用 *Client，本地开发可替换为 FakeSessionProvider。
+type SessionProvider interface {
+	Code2Session(ctx context.Context, code string) (Code2SessionResult, error)
+}
+
 // Client 是微信开放接口的最小客户端封装。
 type Client struct {
 	httpClient *http.Client
+	baseURL    string
 	appID      string
 	appSecret  string
 }
 
+// Option 用于定制 Client（测试/私有网关场景）。
+type Option func(*Client)
+
+// WithBaseURL 替换微信接口地址（如指向 wechatfake 本地服务或内网代理）；为空时保持默认。
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/"); baseURL != "" {
+			c.baseURL = baseURL
+		}
+	}
+}
+
+// WithHTTPClient 替换底层 HTTP 客户端（超时、代理等）。
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc != nil {
+			c.httpClient = hc
+		}
+	}
+}
+
 // Code2SessionResult 是微信 code2session 接口的响应结构。
 type Code2SessionResult struct {
 	OpenID     string `json:"openid"`
@@ -28,14 +60,30 @@ type Code2SessionResult struct {
 	ErrMsg  string `json:"errmsg"`
 }
 
+// APIError 表示微信接口返回的非 0 errcode（HTTP 200 但业务失败）。
+type APIError struct {
+	API     string
+	ErrCode int
+	ErrMsg  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("wechat %s failed: %d %s", e.API, e.ErrCode, e.ErrMsg)
+}
+
 // NewClient 创建微信客户端。
 // appid/secret 来自微信小程序后台；用于 code2session 换取 openid。
-func NewClient(appID, appSecret string) *Client {
-	return &Client{
+func NewClient(appID, appSecret string, opts ...Option) *Client {
+	c := &Client{
 		httpClient: &http.Client{Timeout: 10 * time.Second},
+		baseURL:    DefaultBaseURL,
 		appID:      appID,
 		appSecret:  appSecret,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Code2Session 使用小程序 wx.login() 返回的 code 换取用户 openid/unionid 与 session_key。
@@ -45,7 +93,7 @@ func (c *Client) Code2Session(ctx context.Context, code string) (Code2SessionRes
 	values.Set("secret", c.appSecret)
 	values.Set("js_code", code)
 	values.Set("grant_type", "authorization_code")
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.weixin.qq.com/sns/jscode2session?"+values.Encode(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/sns/jscode2session?"+values.Encode(), nil)
 	if err != nil {
 		return Code2SessionResult{}, err
 	}
@@ -72,10 +120,69 @@ func (c *Client) Code2Session(ctx context.Context, code string) (Code2SessionRes
 		return Code2SessionResult{}, fmt.Errorf("wechat code2session decode failed: %w", err)
 	}
 	if out.ErrCode != 0 {
-		return Code2SessionResult{}, fmt.Errorf("wechat code2session failed: %d %s", out.ErrCode, out.ErrMsg)
+		return Code2SessionResult{}, &APIError{API: "code2session", ErrCode: out.ErrCode, ErrMsg: out.ErrMsg}
 	}
 	if out.OpenID == "" {
 		return Code2SessionResult{}, fmt.Errorf("wechat code2session missing openid")
 	}
 	return out, nil
 }
+
+// apiStatus 是微信接口响应中的公共错误字段。
+type apiStatus struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// call 调用微信接口并将响应解码到 out；body 非 nil 时以 JSON POST 发送。
+// errcode 非 0 时返回 *APIError（out 需要同时解码 errcode，因此这里单独解一次 apiStatus）。
+func (c *Client) call(ctx context.Context, api, path string, query url.Values, body any, out any) error {
+	method := http.MethodGet
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		method = http.MethodPost
+		reqBody = bytes.NewReader(b)
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("wechat %s http error: %s", api, resp.Status)
+	}
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var st apiStatus
+	if err := json.Unmarshal(respBytes, &st); err != nil {
+		return fmt.Errorf("wechat %s decode failed: %w", api, err)
+	}
+	if st.ErrCode != 0 {
+		return &APIError{API: api, ErrCode: st.ErrCode, ErrMsg: st.ErrMsg}
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBytes, out); err != nil {
+			return fmt.Errorf("wechat %s decode failed: %w", api, err)
+		}
+	}
+	return nil
+}
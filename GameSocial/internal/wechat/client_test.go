@@ -0,0 +1,80 @@
+package wechat_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gamesocial/internal/wechat"
+	"gamesocial/internal/wechat/wechatfake"
+)
+
+func newFakeClient(t *testing.T) (*wechatfake.Server, *wechat.Client) {
+	t.Helper()
+	srv := wechatfake.New()
+	t.Cleanup(srv.Close)
+	return srv, wechat.NewClient(srv.AppID, srv.AppSecret, wechat.WithBaseURL(srv.URL))
+}
+
+func TestCode2Session(t *testing.T) {
+	srv, client := newFakeClient(t)
+	srv.AddCode("code-1", "openid-1", "unionid-1")
+
+	got, err := client.Code2Session(context.Background(), "code-1")
+	if err != nil {
+		t.Fatalf("Code2Session: %v", err)
+	}
+	if got.OpenID != "openid-1" || got.UnionID != "unionid-1" || got.SessionKey == "" {
+		t.Fatalf("Code2Session = %+v", got)
+	}
+
+	// code 只能使用一次。
+	_, err = client.Code2Session(context.Background(), "code-1")
+	assertAPIError(t, err, wechatfake.ErrCodeCodeUsed)
+	if n := srv.Calls(wechatfake.PathCode2Session); n != 2 {
+		t.Fatalf("code2session calls = %d, want 2", n)
+	}
+}
+
+func TestCode2SessionErrCode(t *testing.T) {
+	srv, client := newFakeClient(t)
+	srv.AddCode("code-1", "openid-1", "")
+
+	// 排队的一次性错误只影响下一次调用。
+	srv.FailNext(wechatfake.PathCode2Session, wechatfake.ErrCodeInvalidCode, "invalid code")
+	_, err := client.Code2Session(context.Background(), "code-1")
+	assertAPIError(t, err, wechatfake.ErrCodeInvalidCode)
+	if _, err := client.Code2Session(context.Background(), "code-1"); err != nil {
+		t.Fatalf("Code2Session after FailNext: %v", err)
+	}
+
+	// 固定错误持续生效直到清除。
+	srv.SetError(wechatfake.PathCode2Session, wechatfake.ErrCodeSystemBusy, "system busy")
+	_, err = client.Code2Session(context.Background(), "code-2")
+	assertAPIError(t, err, wechatfake.ErrCodeSystemBusy)
+
+	// 未预置的 code 与真实微信一致返回 40029。
+	srv.ClearError(wechatfake.PathCode2Session)
+	_, err = client.Code2Session(context.Background(), "unknown")
+	assertAPIError(t, err, wechatfake.ErrCodeInvalidCode)
+}
+
+func TestCode2SessionWrongSecret(t *testing.T) {
+	srv, _ := newFakeClient(t)
+	srv.AddCode("code-1", "openid-1", "")
+	client := wechat.NewClient(srv.AppID, "wrong", wechat.WithBaseURL(srv.URL))
+
+	_, err := client.Code2Session(context.Background(), "code-1")
+	assertAPIError(t, err, wechatfake.ErrCodeInvalidSecret)
+}
+
+func assertAPIError(t *testing.T, err error, code int) {
+	t.Helper()
+	var apiErr *wechat.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *wechat.APIError", err)
+	}
+	if apiErr.ErrCode != code {
+		t.Fatalf("errcode = %d, want %d", apiErr.ErrCode, code)
+	}
+}
@@ -0,0 +1,47 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// FakeOpenIDPrefix 是假 code 的前缀：code 为 "openid:<openid>" 时直接以该 openid 登录。
+const FakeOpenIDPrefix = "openid:"
+
+// FakeSessionProvider 是本地开发用的 code2session 实现：不访问微信，只在测试 openid 白名单内换取身份。
+// - code 为 "openid:<openid>"：返回指定 openid（必须在白名单内）
+// - 其他任意 code（如开发者工具 wx.login() 返回值）：返回白名单中的第一个 openid
+type FakeSessionProvider struct {
+	openIDs []string
+}
+
+// NewFakeSessionProvider 创建假 code2session；openIDs 为允许登录的测试 openid（至少一个）。
+func NewFakeSessionProvider(openIDs []string) *FakeSessionProvider {
+	return &FakeSessionProvider{openIDs: openIDs}
+}
+
+// Code2Session 按上述规则返回测试 openid。
+func (f *FakeSessionProvider) Code2Session(_ context.Context, code string) (Code2SessionResult, error) {
+	if len(f.openIDs) == 0 {
+		return Code2SessionResult{}, fmt.Errorf("fake code2session: no test openid configured")
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return Code2SessionResult{}, fmt.Errorf("fake code2session: empty code")
+	}
+	openID := f.openIDs[0]
+	if v, ok := strings.CutPrefix(code, FakeOpenIDPrefix); ok {
+		openID = ""
+		for _, id := range f.openIDs {
+			if id == v {
+				openID = id
+				break
+			}
+		}
+		if openID == "" {
+			return Code2SessionResult{}, fmt.Errorf("fake code2session: openid %q is not whitelisted", v)
+		}
+	}
+	return Code2SessionResult{OpenID: openID, SessionKey: "fake-session-key"}, nil
+}
@@ -0,0 +1,41 @@
+package wechat
+
+import (
+	"context"
+	"net/url"
+)
+
+// ErrCodeInvalidPhoneCode: getPhoneNumber 的 code 无效或已过期（code 5 分钟有效且只能使用一次）。
+const ErrCodeInvalidPhoneCode = 40029
+
+// PhoneInfo 是 getuserphonenumber 返回的手机号信息。
+type PhoneInfo struct {
+	// PhoneNumber: 带区号的号码（境外手机号会有区号）。
+	PhoneNumber string `json:"phoneNumber"`
+	// PurePhoneNumber: 不带区号的号码。
+	PurePhoneNumber string `json:"purePhoneNumber"`
+	CountryCode     string `json:"countryCode"`
+}
+
+// GetPhoneNumber 使用给定 access_token，把小程序 getPhoneNumber 按钮返回的 code 换成手机号。
+func (c *Client) GetPhoneNumber(ctx context.Context, accessToken, code string) (PhoneInfo, error) {
+	var out struct {
+		PhoneInfo PhoneInfo `json:"phone_info"`
+	}
+	body := map[string]string{"code": code}
+	if err := c.call(ctx, "getuserphonenumber", "/wxa/business/getuserphonenumber", url.Values{"access_token": {accessToken}}, body, &out); err != nil {
+		return PhoneInfo{}, err
+	}
+	return out.PhoneInfo, nil
+}
+
+// GetPhoneNumber 换取手机号；access_token 失效时刷新并重试一次。
+func (m *Messenger) GetPhoneNumber(ctx context.Context, code string) (PhoneInfo, error) {
+	var info PhoneInfo
+	err := m.tokens.Do(ctx, func(accessToken string) error {
+		var err error
+		info, err = m.client.GetPhoneNumber(ctx, accessToken, code)
+		return err
+	})
+	return info, err
+}
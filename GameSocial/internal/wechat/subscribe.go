@@ -0,0 +1,57 @@
+package wechat
+
+import (
+	"context"
+	"net/url"
+)
+
+// 订阅消息发送失败时不应重试的 errcode。
+const (
+	// ErrCodeSubscribeRefused: 用户拒绝接收或订阅次数已用完。
+	ErrCodeSubscribeRefused = 43101
+	// ErrCodeInvalidOpenID: openid 无效。
+	ErrCodeInvalidOpenID = 40003
+	// ErrCodeInvalidTemplate: template_id 不正确。
+	ErrCodeInvalidTemplate = 40037
+	// ErrCodeInvalidTemplateData: 模板参数不准确（如 thing 超长、time 格式错误）。
+	ErrCodeInvalidTemplateData = 47003
+)
+
+// SubscribeMessage 是小程序订阅消息（subscribeMessage.send）的请求体。
+type SubscribeMessage struct {
+	ToUser     string `json:"touser"`
+	TemplateID string `json:"template_id"`
+	Page       string `json:"page,omitempty"`
+	// MiniprogramState: 跳转小程序类型 developer/trial/formal，默认 formal。
+	MiniprogramState string                    `json:"miniprogram_state,omitempty"`
+	Lang             string                    `json:"lang,omitempty"`
+	Data             map[string]SubscribeValue `json:"data"`
+}
+
+// SubscribeValue 是模板中单个关键词的值。
+type SubscribeValue struct {
+	Value string `json:"value"`
+}
+
+// SendSubscribeMessage 使用给定 access_token 发送订阅消息。
+func (c *Client) SendSubscribeMessage(ctx context.Context, accessToken string, msg SubscribeMessage) error {
+	return c.call(ctx, "subscribe message", "/cgi-bin/message/subscribe/send", url.Values{"access_token": {accessToken}}, msg, nil)
+}
+
+// Messenger 调用需要 access_token 的服务端接口（订阅消息、手机号等），自动携带/刷新 token。
+type Messenger struct {
+	client *Client
+	tokens *TokenManager
+}
+
+// NewMessenger 创建服务端接口调用器。
+func NewMessenger(client *Client, tokens *TokenManager) *Messenger {
+	return &Messenger{client: client, tokens: tokens}
+}
+
+// SendSubscribeMessage 发送订阅消息；access_token 失效时刷新并重试一次。
+func (m *Messenger) SendSubscribeMessage(ctx context.Context, msg SubscribeMessage) error {
+	return m.tokens.Do(ctx, func(accessToken string) error {
+		return m.client.SendSubscribeMessage(ctx, accessToken, msg)
+	})
+}
@@ -0,0 +1,148 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// 需要刷新 access_token 后重试的 errcode。
+const (
+	errCodeInvalidCredential = 40001 // access_token 无效或不是最新
+	errCodeInvalidToken      = 40014 // 不合法的 access_token
+	errCodeTokenExpired      = 42001 // access_token 已过期
+)
+
+// defaultRefreshMargin 表示距过期多久时提前刷新（微信 token 有效期 7200 秒）；
+// 有效期过短时按有效期的一半截断，避免每次调用都重新获取。
+const defaultRefreshMargin = 5 * time.Minute
+
+// IsAccessTokenError 判断错误是否为 access_token 失效类错误（刷新后可重试）。
+func IsAccessTokenError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrCode {
+	case errCodeInvalidCredential, errCodeInvalidToken, errCodeTokenExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+// TokenManager 管理小程序全局 access_token：缓存到临近过期前，并发调用方只触发一次刷新。
+// 注意：每次向微信换取新 token 都会使旧 token 在短时间后失效，多实例部署时应只由一处持有刷新职责。
+type TokenManager struct {
+	client *Client
+	margin time.Duration
+
+	mu        sync.Mutex
+	token     string
+	refreshAt time.Time
+	inflight  *tokenFlight
+}
+
+// tokenFlight 表示一次进行中的刷新，等待者共享其结果。
+type tokenFlight struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// NewTokenManager 创建 access_token 管理器。
+func NewTokenManager(client *Client) *TokenManager {
+	return &TokenManager{client: client, margin: defaultRefreshMargin}
+}
+
+// Token 返回可用的 access_token；缓存为空或即将过期时刷新。
+func (m *TokenManager) Token(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	if m.token != "" && time.Now().Before(m.refreshAt) {
+		token := m.token
+		m.mu.Unlock()
+		return token, nil
+	}
+	return m.refreshLocked(ctx)
+}
+
+// Invalidate 丢弃缓存中的 token（仅当其仍为 token 时），下次 Token 会重新获取。
+// 传入调用失败时使用的 token，避免并发场景下误删别人刚刷新的新 token。
+func (m *TokenManager) Invalidate(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token == token {
+		m.token = ""
+	}
+}
+
+// Do 使用 access_token 执行 fn；若返回 token 失效类错误（40001/40014/42001），刷新后重试一次。
+func (m *TokenManager) Do(ctx context.Context, fn func(accessToken string) error) error {
+	token, err := m.Token(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn(token)
+	if !IsAccessTokenError(err) {
+		return err
+	}
+	m.Invalidate(token)
+	token, err = m.Token(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(token)
+}
+
+// refreshLocked 在持有 m.mu 时调用：发起或加入一次刷新，返回时已释放锁。
+func (m *TokenManager) refreshLocked(ctx context.Context) (string, error) {
+	if f := m.inflight; f != nil {
+		m.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.token, f.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	f := &tokenFlight{done: make(chan struct{})}
+	m.inflight = f
+	m.mu.Unlock()
+
+	// 刷新不随首个调用方取消而中断，其他等待者依赖同一结果。
+	token, expiresIn, err := m.client.fetchAccessToken(context.WithoutCancel(ctx))
+
+	m.mu.Lock()
+	if err == nil {
+		ttl := time.Duration(expiresIn) * time.Second
+		m.token = token
+		m.refreshAt = time.Now().Add(ttl - min(m.margin, ttl/2))
+	}
+	f.token, f.err = token, err
+	m.inflight = nil
+	close(f.done)
+	m.mu.Unlock()
+	return token, err
+}
+
+// fetchAccessToken 调用 /cgi-bin/token 获取新的 access_token。
+func (c *Client) fetchAccessToken(ctx context.Context) (string, int64, error) {
+	values := url.Values{}
+	values.Set("grant_type", "client_credential")
+	values.Set("appid", c.appID)
+	values.Set("secret", c.appSecret)
+
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := c.call(ctx, "token", "/cgi-bin/token", values, nil, &out); err != nil {
+		return "", 0, err
+	}
+	if out.AccessToken == "" || out.ExpiresIn <= 0 {
+		return "", 0, errors.New("wechat token missing access_token")
+	}
+	return out.AccessToken, out.ExpiresIn, nil
+}
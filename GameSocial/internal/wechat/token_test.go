@@ -0,0 +1,122 @@
+package wechat_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gamesocial/internal/wechat"
+	"gamesocial/internal/wechat/wechatfake"
+)
+
+// sendWith 用给定 access_token 调用假服务的订阅消息接口，errcode 非 0 时返回 *wechat.APIError。
+func sendWith(srv *wechatfake.Server, token string) error {
+	body, _ := json.Marshal(map[string]any{"touser": "openid-1", "template_id": "tpl-1"})
+	resp, err := http.Post(srv.URL+wechatfake.PathSubscribeSend+"?access_token="+url.QueryEscape(token), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return err
+	}
+	if out.ErrCode != 0 {
+		return &wechat.APIError{API: "subscribe.send", ErrCode: out.ErrCode, ErrMsg: out.ErrMsg}
+	}
+	return nil
+}
+
+func TestTokenConcurrentSingleFetch(t *testing.T) {
+	srv, client := newFakeClient(t)
+	m := wechat.NewTokenManager(client)
+
+	const callers = 20
+	var wg sync.WaitGroup
+	tokens := make([]string, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], errs[i] = m.Token(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil || tokens[i] != srv.AccessToken() {
+			t.Fatalf("caller %d: token=%q err=%v", i, tokens[i], errs[i])
+		}
+	}
+	if n := srv.Calls(wechatfake.PathToken); n != 1 {
+		t.Fatalf("token calls = %d, want 1", n)
+	}
+}
+
+func TestTokenDoRetriesOnInvalidToken(t *testing.T) {
+	srv, client := newFakeClient(t)
+	m := wechat.NewTokenManager(client)
+
+	// 第一次调用返回 40001：Do 丢弃缓存、重新获取 token 后重试一次。
+	srv.FailNext(wechatfake.PathSubscribeSend, wechatfake.ErrCodeInvalidToken, "invalid credential")
+	calls := 0
+	err := m.Do(context.Background(), func(token string) error {
+		calls++
+		return sendWith(srv, token)
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("fn calls = %d, want 2", calls)
+	}
+	if n := srv.Calls(wechatfake.PathToken); n != 2 {
+		t.Fatalf("token calls = %d, want 2", n)
+	}
+	if n := len(srv.Messages()); n != 1 {
+		t.Fatalf("messages = %d, want 1", n)
+	}
+
+	// 持续失效时只重试一次，并返回最后的错误。
+	srv.SetError(wechatfake.PathSubscribeSend, wechatfake.ErrCodeInvalidToken, "invalid credential")
+	calls = 0
+	err = m.Do(context.Background(), func(token string) error {
+		calls++
+		return sendWith(srv, token)
+	})
+	assertAPIError(t, err, wechatfake.ErrCodeInvalidToken)
+	if calls != 2 {
+		t.Fatalf("fn calls = %d, want 2", calls)
+	}
+}
+
+func TestTokenShortExpiryIsCached(t *testing.T) {
+	// expires_in 小于两倍提前刷新量时，提前量截断为有效期的一半，token 仍会被缓存。
+	var fetches atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "short-lived", "expires_in": 120})
+	}))
+	t.Cleanup(ts.Close)
+	m := wechat.NewTokenManager(wechat.NewClient("appid", "secret", wechat.WithBaseURL(ts.URL)))
+
+	for i := 0; i < 3; i++ {
+		token, err := m.Token(context.Background())
+		if err != nil || token != "short-lived" {
+			t.Fatalf("Token = %q, %v", token, err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("token fetches = %d, want 1", n)
+	}
+}
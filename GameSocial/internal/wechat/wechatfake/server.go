@@ -0,0 +1,337 @@
+// wechatfake 提供进程内的假微信开放接口服务，用于在无网络环境下联调/测试登录等链路。
+//
+// 用法：
+//
+//	srv := wechatfake.New()
+//	defer srv.Close()
+//	srv.AddCode("code-1", "openid-1", "")
+//	client := wechat.NewClient(srv.AppID, srv.AppSecret, wechat.WithBaseURL(srv.URL))
+package wechatfake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// 默认的假 appid/secret；调用方应使用 Server.AppID/AppSecret 构造客户端。
+const (
+	DefaultAppID     = "wx_fake_appid"
+	DefaultAppSecret = "wx_fake_secret"
+)
+
+// 与真实微信一致的常见 errcode。
+const (
+	ErrCodeSystemBusy      = -1
+	ErrCodeInvalidToken    = 40001
+	ErrCodeInvalidAppID    = 40013
+	ErrCodeInvalidCode     = 40029
+	ErrCodeExpiredToken    = 42001
+	ErrCodeCodeUsed        = 40163
+	ErrCodeInvalidSecret   = 40125
+	ErrCodeRateLimited     = 45011
+	ErrCodeInvalidArgument = 40003
+)
+
+// 已实现的接口路径（用于 FailNext/SetError/Calls）。
+const (
+	PathCode2Session   = "/sns/jscode2session"
+	PathToken          = "/cgi-bin/token"
+	PathSubscribeSend  = "/cgi-bin/message/subscribe/send"
+	PathGetPhoneNumber = "/wxa/business/getuserphonenumber"
+)
+
+// accessTokenExpiresIn 与真实微信一致：access_token 有效期 7200 秒。
+const accessTokenExpiresIn = 7200
+
+// Session 是 code 对应的用户身份。
+type Session struct {
+	OpenID  string
+	UnionID string
+}
+
+// Phone 是手机号 code 对应的号码信息。
+type Phone struct {
+	PhoneNumber     string
+	PurePhoneNumber string
+	CountryCode     string
+}
+
+// SubscribeMessage 记录一次订阅消息发送请求的原始 JSON。
+type SubscribeMessage map[string]any
+
+type apiError struct {
+	code int
+	msg  string
+}
+
+// Server 是假微信服务；零值不可用，请使用 New 创建。
+type Server struct {
+	// URL: 服务地址，传给 wechat.WithBaseURL。
+	URL string
+	// AppID/AppSecret: 换取 access_token、code2session 时校验的凭证。
+	AppID     string
+	AppSecret string
+
+	srv *httptest.Server
+	mux *http.ServeMux
+
+	mu          sync.Mutex
+	sessions    map[string]Session
+	usedCodes   map[string]bool
+	phones      map[string]Phone
+	accessToken string
+	sticky      map[string]apiError
+	oneShot     map[string][]apiError
+	calls       map[string]int
+	messages    []SubscribeMessage
+}
+
+// New 启动假微信服务。
+func New() *Server {
+	s := &Server{
+		AppID:     DefaultAppID,
+		AppSecret: DefaultAppSecret,
+		mux:       http.NewServeMux(),
+		sessions:  map[string]Session{},
+		usedCodes: map[string]bool{},
+		phones:    map[string]Phone{},
+		sticky:    map[string]apiError{},
+		oneShot:   map[string][]apiError{},
+		calls:     map[string]int{},
+	}
+	s.accessToken = newToken()
+	s.mux.HandleFunc("GET "+PathCode2Session, s.handleCode2Session)
+	s.mux.HandleFunc("GET "+PathToken, s.handleToken)
+	s.mux.HandleFunc("POST "+PathSubscribeSend, s.handleSubscribeSend)
+	s.mux.HandleFunc("POST "+PathGetPhoneNumber, s.handleGetPhoneNumber)
+	s.srv = httptest.NewServer(s.mux)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close 关闭服务。
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// HandleFunc 注册额外的接口（用于尚未内置的微信 API）；pattern 与 http.ServeMux 相同。
+func (s *Server) HandleFunc(pattern string, h http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, h)
+}
+
+// AddCode 预置一个 wx.login() code；code 只能使用一次（与微信一致，再次使用返回 40163）。
+func (s *Server) AddCode(code, openID, unionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[code] = Session{OpenID: openID, UnionID: unionID}
+	delete(s.usedCodes, code)
+}
+
+// AddPhoneCode 预置一个 getPhoneNumber 返回的手机号 code。
+func (s *Server) AddPhoneCode(code string, p Phone) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.phones[code] = p
+}
+
+// FailNext 让 path 的下一次调用返回指定 errcode（可多次调用排队）。
+func (s *Server) FailNext(path string, errCode int, errMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.oneShot[path] = append(s.oneShot[path], apiError{code: errCode, msg: errMsg})
+}
+
+// SetError 让 path 的每次调用都返回指定 errcode，直到 ClearError。
+func (s *Server) SetError(path string, errCode int, errMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sticky[path] = apiError{code: errCode, msg: errMsg}
+}
+
+// ClearError 清除 path 上的固定错误与排队错误。
+func (s *Server) ClearError(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sticky, path)
+	delete(s.oneShot, path)
+}
+
+// AccessToken 返回当前有效的 access_token。
+func (s *Server) AccessToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accessToken
+}
+
+// RevokeAccessToken 使当前 access_token 失效（模拟在别处被刷新），之后携带旧 token 的调用返回 40001。
+func (s *Server) RevokeAccessToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessToken = newToken()
+}
+
+// Calls 返回 path 被调用的次数（含失败）。
+func (s *Server) Calls(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[path]
+}
+
+// Messages 返回已成功“发送”的订阅消息。
+func (s *Server) Messages() []SubscribeMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]SubscribeMessage, len(s.messages))
+	copy(out, s.messages)
+	return out
+}
+
+// begin 记录调用次数，并返回预设错误（若有）。
+func (s *Server) begin(path string) (apiError, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[path]++
+	if q := s.oneShot[path]; len(q) > 0 {
+		s.oneShot[path] = q[1:]
+		return q[0], true
+	}
+	if e, ok := s.sticky[path]; ok {
+		return e, true
+	}
+	return apiError{}, false
+}
+
+// checkToken 校验 query 中的 access_token。
+func (s *Server) checkToken(r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return r.URL.Query().Get("access_token") == s.accessToken
+}
+
+func (s *Server) handleCode2Session(w http.ResponseWriter, r *http.Request) {
+	if e, ok := s.begin(PathCode2Session); ok {
+		writeErr(w, e.code, e.msg)
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("appid") != s.AppID {
+		writeErr(w, ErrCodeInvalidAppID, "invalid appid")
+		return
+	}
+	if q.Get("secret") != s.AppSecret {
+		writeErr(w, ErrCodeInvalidSecret, "invalid appsecret")
+		return
+	}
+	code := q.Get("js_code")
+	s.mu.Lock()
+	sess, ok := s.sessions[code]
+	used := s.usedCodes[code]
+	if ok && !used {
+		s.usedCodes[code] = true
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeErr(w, ErrCodeInvalidCode, "invalid code")
+		return
+	}
+	if used {
+		writeErr(w, ErrCodeCodeUsed, "code been used")
+		return
+	}
+	writeJSON(w, map[string]any{
+		"openid":      sess.OpenID,
+		"unionid":     sess.UnionID,
+		"session_key": newToken(),
+	})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if e, ok := s.begin(PathToken); ok {
+		writeErr(w, e.code, e.msg)
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("appid") != s.AppID {
+		writeErr(w, ErrCodeInvalidAppID, "invalid appid")
+		return
+	}
+	if q.Get("secret") != s.AppSecret {
+		writeErr(w, ErrCodeInvalidSecret, "invalid appsecret")
+		return
+	}
+	writeJSON(w, map[string]any{
+		"access_token": s.AccessToken(),
+		"expires_in":   accessTokenExpiresIn,
+	})
+}
+
+func (s *Server) handleSubscribeSend(w http.ResponseWriter, r *http.Request) {
+	if e, ok := s.begin(PathSubscribeSend); ok {
+		writeErr(w, e.code, e.msg)
+		return
+	}
+	if !s.checkToken(r) {
+		writeErr(w, ErrCodeInvalidToken, "invalid credential, access_token is invalid or not latest")
+		return
+	}
+	var msg SubscribeMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg["touser"] == nil || msg["template_id"] == nil {
+		writeErr(w, ErrCodeInvalidArgument, "invalid argument")
+		return
+	}
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+	writeErr(w, 0, "ok")
+}
+
+func (s *Server) handleGetPhoneNumber(w http.ResponseWriter, r *http.Request) {
+	if e, ok := s.begin(PathGetPhoneNumber); ok {
+		writeErr(w, e.code, e.msg)
+		return
+	}
+	if !s.checkToken(r) {
+		writeErr(w, ErrCodeInvalidToken, "invalid credential, access_token is invalid or not latest")
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	s.mu.Lock()
+	p, ok := s.phones[req.Code]
+	s.mu.Unlock()
+	if !ok {
+		writeErr(w, ErrCodeInvalidCode, "invalid code")
+		return
+	}
+	writeJSON(w, map[string]any{
+		"errcode": 0,
+		"errmsg":  "ok",
+		"phone_info": map[string]any{
+			"phoneNumber":     p.PhoneNumber,
+			"purePhoneNumber": p.PurePhoneNumber,
+			"countryCode":     p.CountryCode,
+		},
+	})
+}
+
+// writeErr 按微信的习惯返回 HTTP 200 + errcode/errmsg。
+func writeErr(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, map[string]any{"errcode": code, "errmsg": msg})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newToken() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,145 @@
+package wechatfake_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"gamesocial/internal/wechat/wechatfake"
+)
+
+// getJSON 以 GET 调用假服务并解析 JSON 响应。
+func getJSON(t *testing.T, srv *wechatfake.Server, path string, q url.Values, out any) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path + "?" + q.Encode())
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+}
+
+// postJSON 以 POST 调用需要 access_token 的假接口并解析 JSON 响应。
+func postJSON(t *testing.T, srv *wechatfake.Server, path, token string, body, out any) {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	resp, err := http.Post(srv.URL+path+"?access_token="+url.QueryEscape(token), "application/json", bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+}
+
+type errResp struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func TestToken(t *testing.T) {
+	srv := wechatfake.New()
+	t.Cleanup(srv.Close)
+
+	var got struct {
+		errResp
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	q := url.Values{"grant_type": {"client_credential"}, "appid": {srv.AppID}, "secret": {srv.AppSecret}}
+	getJSON(t, srv, wechatfake.PathToken, q, &got)
+	if got.ErrCode != 0 || got.AccessToken != srv.AccessToken() || got.ExpiresIn != 7200 {
+		t.Fatalf("token = %+v", got)
+	}
+
+	// 错误的 secret 返回 40125。
+	var bad errResp
+	q.Set("secret", "wrong")
+	getJSON(t, srv, wechatfake.PathToken, q, &bad)
+	if bad.ErrCode != wechatfake.ErrCodeInvalidSecret {
+		t.Fatalf("wrong secret errcode = %d, want %d", bad.ErrCode, wechatfake.ErrCodeInvalidSecret)
+	}
+	if n := srv.Calls(wechatfake.PathToken); n != 2 {
+		t.Fatalf("token calls = %d, want 2", n)
+	}
+}
+
+func TestSubscribeSend(t *testing.T) {
+	srv := wechatfake.New()
+	t.Cleanup(srv.Close)
+	msg := map[string]any{"touser": "openid-1", "template_id": "tpl-1", "data": map[string]any{}}
+
+	var got errResp
+	postJSON(t, srv, wechatfake.PathSubscribeSend, srv.AccessToken(), msg, &got)
+	if got.ErrCode != 0 {
+		t.Fatalf("subscribe/send = %+v", got)
+	}
+	msgs := srv.Messages()
+	if len(msgs) != 1 || msgs[0]["touser"] != "openid-1" || msgs[0]["template_id"] != "tpl-1" {
+		t.Fatalf("messages = %+v", msgs)
+	}
+
+	// 缺少 template_id 返回 40003，不记录消息。
+	got = errResp{}
+	postJSON(t, srv, wechatfake.PathSubscribeSend, srv.AccessToken(), map[string]any{"touser": "openid-1"}, &got)
+	if got.ErrCode != wechatfake.ErrCodeInvalidArgument {
+		t.Fatalf("missing template_id errcode = %d, want %d", got.ErrCode, wechatfake.ErrCodeInvalidArgument)
+	}
+
+	// 旧 token 被吊销后返回 40001。
+	old := srv.AccessToken()
+	srv.RevokeAccessToken()
+	got = errResp{}
+	postJSON(t, srv, wechatfake.PathSubscribeSend, old, msg, &got)
+	if got.ErrCode != wechatfake.ErrCodeInvalidToken {
+		t.Fatalf("revoked token errcode = %d, want %d", got.ErrCode, wechatfake.ErrCodeInvalidToken)
+	}
+	if n := len(srv.Messages()); n != 1 {
+		t.Fatalf("messages = %d, want 1", n)
+	}
+}
+
+func TestGetPhoneNumber(t *testing.T) {
+	srv := wechatfake.New()
+	t.Cleanup(srv.Close)
+	srv.AddPhoneCode("phone-1", wechatfake.Phone{PhoneNumber: "+86 13800000000", PurePhoneNumber: "13800000000", CountryCode: "86"})
+
+	var got struct {
+		errResp
+		PhoneInfo struct {
+			PhoneNumber     string `json:"phoneNumber"`
+			PurePhoneNumber string `json:"purePhoneNumber"`
+			CountryCode     string `json:"countryCode"`
+		} `json:"phone_info"`
+	}
+	postJSON(t, srv, wechatfake.PathGetPhoneNumber, srv.AccessToken(), map[string]string{"code": "phone-1"}, &got)
+	if got.ErrCode != 0 || got.PhoneInfo.PurePhoneNumber != "13800000000" || got.PhoneInfo.CountryCode != "86" {
+		t.Fatalf("getuserphonenumber = %+v", got)
+	}
+
+	// 未预置的 code 返回 40029；排队错误只影响下一次调用。
+	var bad errResp
+	postJSON(t, srv, wechatfake.PathGetPhoneNumber, srv.AccessToken(), map[string]string{"code": "unknown"}, &bad)
+	if bad.ErrCode != wechatfake.ErrCodeInvalidCode {
+		t.Fatalf("unknown code errcode = %d, want %d", bad.ErrCode, wechatfake.ErrCodeInvalidCode)
+	}
+	srv.FailNext(wechatfake.PathGetPhoneNumber, wechatfake.ErrCodeSystemBusy, "system busy")
+	bad = errResp{}
+	postJSON(t, srv, wechatfake.PathGetPhoneNumber, srv.AccessToken(), map[string]string{"code": "phone-1"}, &bad)
+	if bad.ErrCode != wechatfake.ErrCodeSystemBusy {
+		t.Fatalf("FailNext errcode = %d, want %d", bad.ErrCode, wechatfake.ErrCodeSystemBusy)
+	}
+	got.PhoneInfo.PurePhoneNumber = ""
+	postJSON(t, srv, wechatfake.PathGetPhoneNumber, srv.AccessToken(), map[string]string{"code": "phone-1"}, &got)
+	if got.ErrCode != 0 || got.PhoneInfo.PurePhoneNumber != "13800000000" {
+		t.Fatalf("getuserphonenumber after FailNext = %+v", got)
+	}
+}
@@ -0,0 +1,363 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLen 是管理员密码的最小长度。
+const minPasswordLen = 8
+
+// CreateAdminRequest 创建管理员（员工账号）入参。
+type CreateAdminRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     Role   `json:"role"`
+}
+
+// UpdateAdminRequest 更新管理员入参：只更新非 nil 字段；status=0 即禁用。
+type UpdateAdminRequest struct {
+	Role   *Role `json:"role"`
+	Status *int  `json:"status"`
+}
+
+// ListAdminRequest 管理员列表入参。
+type ListAdminRequest struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	Status int `json:"status"`
+}
+
+// Create 创建员工账号，并写入审计日志。
+func (s *service) Create(ctx context.Context, operatorID uint64, req CreateAdminRequest) (Admin, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return Admin{}, errors.New("database disabled")
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || utf8.RuneCountInString(req.Username) > 64 {
+		return Admin{}, errors.New("用户名不合法")
+	}
+	if req.Role == "" {
+		req.Role = RoleCashier
+	}
+	if !ValidRole(req.Role) {
+		return Admin{}, errors.New("角色不合法")
+	}
+	if err := checkPassword(req.Password); err != nil {
+		return Admin{}, err
+	}
+	hash, err := HashPassword(req.Password)
+	if err != nil {
+		return Admin{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Admin{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 2) 用户名唯一（uk_admin_user_username 兜底并发）。
+	var exists int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM admin_user WHERE username = ?`, req.Username).Scan(&exists); err != nil {
+		return Admin{}, err
+	}
+	if exists > 0 {
+		return Admin{}, errors.New("用户名已存在")
+	}
+
+	// 3) 写入账号与审计日志。
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO admin_user (username, password_hash, role, status, created_at, updated_at)
+		VALUES (?, ?, ?, 1, NOW(), NOW())
+	`, req.Username, hash, string(req.Role))
+	if err != nil {
+		return Admin{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Admin{}, err
+	}
+	if err := writeAudit(ctx, tx, operatorID, "ADMIN_CREATE", uint64(id), map[string]any{
+		"username": req.Username,
+		"role":     req.Role,
+	}); err != nil {
+		return Admin{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Admin{}, err
+	}
+	return s.Get(ctx, uint64(id))
+}
+
+// List 获取管理员列表。
+func (s *service) List(ctx context.Context, req ListAdminRequest) ([]Admin, error) {
+	// 1) 基础校验与分页兜底。
+	if s.db == nil {
+		return nil, errors.New("database disabled")
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	if req.Limit > 200 {
+		req.Limit = 200
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	// 2) 默认不过滤状态；如果传了 status 则按 status 过滤。
+	where := ""
+	args := make([]any, 0, 3)
+	if req.Status != 0 {
+		where = "WHERE status = ?"
+		args = append(args, req.Status)
+	}
+	args = append(args, req.Limit, req.Offset)
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, username, role, status, created_at
+		FROM admin_user
+		`+where+`
+		ORDER BY id ASC
+		LIMIT ? OFFSET ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Admin, 0, req.Limit)
+	for rows.Next() {
+		var a Admin
+		if err := rows.Scan(&a.ID, &a.Username, &a.Role, &a.Status, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Update 修改管理员角色/状态；禁用时立即吊销其全部会话。
+func (s *service) Update(ctx context.Context, operatorID, id uint64, req UpdateAdminRequest) (Admin, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return Admin{}, errors.New("database disabled")
+	}
+	if id == 0 {
+		return Admin{}, errors.New("invalid id")
+	}
+	if req.Role == nil && req.Status == nil {
+		return s.Get(ctx, id)
+	}
+	if req.Role != nil && !ValidRole(*req.Role) {
+		return Admin{}, errors.New("角色不合法")
+	}
+	if req.Status != nil && *req.Status != 0 && *req.Status != 1 {
+		return Admin{}, errors.New("状态不合法")
+	}
+	if id == operatorID {
+		return Admin{}, errors.New("不能修改自己的角色或状态")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Admin{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 2) 锁定目标账号，读取当前值用于校验与审计。
+	var before Admin
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, username, role, status, created_at
+		FROM admin_user
+		WHERE id = ?
+		FOR UPDATE
+	`, id).Scan(&before.ID, &before.Username, &before.Role, &before.Status, &before.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Admin{}, errors.New("admin not found")
+	}
+	if err != nil {
+		return Admin{}, err
+	}
+
+	after := before
+	if req.Role != nil {
+		after.Role = *req.Role
+	}
+	if req.Status != nil {
+		after.Status = *req.Status
+	}
+
+	// 3) 不允许移除最后一个启用中的 OWNER，避免无人可管理账号。
+	if before.Role == RoleOwner && before.Status == 1 && (after.Role != RoleOwner || after.Status != 1) {
+		var owners int
+		if err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(1) FROM admin_user
+			WHERE role = 'OWNER' AND status = 1 AND id <> ?
+			FOR UPDATE
+		`, id).Scan(&owners); err != nil {
+			return Admin{}, err
+		}
+		if owners == 0 {
+			return Admin{}, errors.New("至少需要保留一个启用的 OWNER")
+		}
+	}
+
+	// 4) 更新账号；禁用时吊销会话。
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE admin_user SET role = ?, status = ?, updated_at = NOW() WHERE id = ?
+	`, string(after.Role), after.Status, id); err != nil {
+		return Admin{}, err
+	}
+	action := "ADMIN_UPDATE"
+	if after.Status == 0 && before.Status != 0 {
+		action = "ADMIN_DISABLE"
+		if err := revokeSessions(ctx, tx, id, ""); err != nil {
+			return Admin{}, err
+		}
+	}
+
+	// 5) 审计日志记录变更前后值。
+	if err := writeAudit(ctx, tx, operatorID, action, id, map[string]any{
+		"before": map[string]any{"role": before.Role, "status": before.Status},
+		"after":  map[string]any{"role": after.Role, "status": after.Status},
+	}); err != nil {
+		return Admin{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Admin{}, err
+	}
+	return s.Get(ctx, id)
+}
+
+// ResetPassword 由管理员强制重置他人密码，并吊销对方全部会话（需用新密码重新登录）。
+func (s *service) ResetPassword(ctx context.Context, operatorID, id uint64, newPassword string) error {
+	if s.db == nil {
+		return errors.New("database disabled")
+	}
+	if id == 0 {
+		return errors.New("invalid id")
+	}
+	if err := checkPassword(newPassword); err != nil {
+		return err
+	}
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE admin_user SET password_hash = ?, updated_at = NOW() WHERE id = ?
+	`, hash, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return errors.New("admin not found")
+	}
+	if err := revokeSessions(ctx, tx, id, ""); err != nil {
+		return err
+	}
+	if err := writeAudit(ctx, tx, operatorID, "ADMIN_PASSWORD_RESET", id, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ChangePassword 修改自己的密码：校验旧密码，成功后吊销除当前会话外的其他会话。
+func (s *service) ChangePassword(ctx context.Context, adminID uint64, sessionID, oldPassword, newPassword string) error {
+	if s.db == nil {
+		return errors.New("database disabled")
+	}
+	if adminID == 0 {
+		return errors.New("invalid id")
+	}
+	if err := checkPassword(newPassword); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var hash string
+	err = tx.QueryRowContext(ctx, `SELECT password_hash FROM admin_user WHERE id = ? FOR UPDATE`, adminID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return errors.New("admin not found")
+	}
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(oldPassword)) != nil {
+		return errors.New("原密码错误")
+	}
+
+	newHash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE admin_user SET password_hash = ?, updated_at = NOW() WHERE id = ?
+	`, newHash, adminID); err != nil {
+		return err
+	}
+	if err := revokeSessions(ctx, tx, adminID, sessionID); err != nil {
+		return err
+	}
+	if err := writeAudit(ctx, tx, adminID, "ADMIN_PASSWORD_CHANGE", adminID, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkPassword 校验新密码强度（当前仅限制长度）。
+func checkPassword(password string) error {
+	if len(password) < minPasswordLen {
+		return errors.New("密码长度至少 8 位")
+	}
+	if len(password) > 72 {
+		// bcrypt 只使用前 72 字节，超出部分会被静默忽略。
+		return errors.New("密码长度不能超过 72 字节")
+	}
+	return nil
+}
+
+// revokeSessions 吊销管理员的全部会话；exceptSessionID 非空时保留该会话。
+func revokeSessions(ctx context.Context, db execer, adminID uint64, exceptSessionID string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE admin_session
+		SET revoked_at = NOW()
+		WHERE admin_id = ? AND revoked_at IS NULL AND id <> ?
+	`, adminID, exceptSessionID)
+	return err
+}
+
+// writeAudit 写入一条针对管理员账号（biz_type=ADMIN）的审计日志。
+func writeAudit(ctx context.Context, db execer, operatorID uint64, action string, targetID uint64, detail any) error {
+	return insertAudit(ctx, db, AuditEntry{
+		AdminID: operatorID,
+		Action:  action,
+		BizType: "ADMIN",
+		BizID:   strconv.FormatUint(targetID, 10),
+		Detail:  detail,
+	})
+}
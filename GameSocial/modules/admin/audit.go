@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// AuditEntry 表示一条待写入 admin_audit_log 的审计记录。
+type AuditEntry struct {
+	// AdminID 为 0 时写入 NULL（如登录限流等未登录事件）。
+	AdminID uint64
+	Action  string
+	BizType string
+	BizID   string
+	// Detail 会被序列化为 detail_json；为 nil 时写入 NULL。
+	Detail any
+}
+
+// execer 是 *sql.DB 与 *sql.Tx 的公共写接口，便于审计日志与业务写入共用事务。
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// RecordAudit 写入一条审计日志（供审计中间件等在业务事务之外调用）。
+func (s *service) RecordAudit(ctx context.Context, e AuditEntry) error {
+	if s.db == nil {
+		return errors.New("database disabled")
+	}
+	return insertAudit(ctx, s.db, e)
+}
+
+// RecordAuditTx 在调用方事务内写入审计日志：业务写入与审计同时提交或回滚。
+func RecordAuditTx(ctx context.Context, tx *sql.Tx, e AuditEntry) error {
+	return insertAudit(ctx, tx, e)
+}
+
+func insertAudit(ctx context.Context, db execer, e AuditEntry) error {
+	e.Action = strings.TrimSpace(e.Action)
+	if e.Action == "" {
+		return errors.New("invalid audit action")
+	}
+	var detailJSON any
+	if e.Detail != nil {
+		b, err := json.Marshal(e.Detail)
+		if err != nil {
+			return err
+		}
+		detailJSON = string(b)
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO admin_audit_log (admin_id, action, biz_type, biz_id, detail_json, created_at)
+		VALUES (NULLIF(?, 0), ?, NULLIF(?, ''), NULLIF(?, ''), ?, NOW())
+	`, e.AdminID, truncate(e.Action, 64), truncate(e.BizType, 32), truncate(e.BizID, 64), detailJSON)
+	return err
+}
+
+// truncate 按列宽截断字符串，避免超长 action/biz 字段导致写入失败。
+// VARCHAR(n) 按字符计长，因此按 rune 截断，不会切出半个多字节字符（utf8mb4 会拒绝非法 UTF-8）。
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
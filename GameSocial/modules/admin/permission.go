@@ -0,0 +1,80 @@
+package admin
+
+// Role 表示管理员角色（对应 admin_user.role）。
+type Role string
+
+const (
+	// RoleOwner 店主：拥有全部权限（含账号管理、审计查询、大额积分调整审批）。
+	RoleOwner Role = "OWNER"
+	// RoleManager 店长：日常运营（商品/赛事/任务/用户/订单/积分），不含账号管理。
+	RoleManager Role = "MANAGER"
+	// RoleCashier 收银员：只读查询 + 核销兑换订单/饮品。
+	RoleCashier Role = "CASHIER"
+)
+
+// Permission 表示一个后台操作权限点，在注册路由时声明。
+type Permission string
+
+const (
+	PermGoodsRead       Permission = "goods:read"
+	PermGoodsWrite      Permission = "goods:write"
+	PermTournamentRead  Permission = "tournament:read"
+	PermTournamentWrite Permission = "tournament:write"
+	PermTaskRead        Permission = "task:read"
+	PermTaskWrite       Permission = "task:write"
+	PermUserRead        Permission = "user:read"
+	PermUserWrite       Permission = "user:write"
+	PermRedeemRead      Permission = "redeem:read"
+	PermRedeemWrite     Permission = "redeem:write"
+	PermRedeemUse       Permission = "redeem:use"
+	PermDrinkUse        Permission = "drink:use"
+	PermPointsAdjust    Permission = "points:adjust"
+	PermPointsApprove   Permission = "points:approve"
+	PermQRCodeCreate    Permission = "qrcode:create"
+	PermAuditRead       Permission = "audit:read"
+	PermAdminManage     Permission = "admin:manage"
+)
+
+// rolePermissions 定义各角色拥有的权限；OWNER 不在表中，默认拥有全部权限。
+var rolePermissions = map[Role][]Permission{
+	RoleManager: {
+		PermGoodsRead, PermGoodsWrite,
+		PermTournamentRead, PermTournamentWrite,
+		PermTaskRead, PermTaskWrite,
+		PermUserRead, PermUserWrite,
+		PermRedeemRead, PermRedeemWrite, PermRedeemUse,
+		PermDrinkUse,
+		PermPointsAdjust,
+		PermQRCodeCreate,
+	},
+	RoleCashier: {
+		PermGoodsRead,
+		PermTournamentRead,
+		PermUserRead,
+		PermRedeemRead, PermRedeemUse,
+		PermDrinkUse,
+	},
+}
+
+// ValidRole 判断角色是否为已定义的取值。
+func ValidRole(role Role) bool {
+	switch role {
+	case RoleOwner, RoleManager, RoleCashier:
+		return true
+	default:
+		return false
+	}
+}
+
+// Can 判断角色是否拥有指定权限；未知角色一律无权限。
+func (role Role) Can(perm Permission) bool {
+	if role == RoleOwner {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -1,5 +1,231 @@
-// admin 模块负责后台管理员登录、鉴权与审计等业务能力（后续实现）。
+// admin 模块负责后台管理员登录、鉴权与审计等业务能力。
 package admin
 
-// Service 定义 admin 模块对外提供的业务接口（后续补充具体方法）。
-type Service interface{}
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials 表示用户名或密码错误（不区分具体原因，避免枚举账号）。
+var ErrInvalidCredentials = errors.New("用户名或密码错误")
+
+// ErrUnauthenticated 表示管理员 token 无效、过期或会话已被吊销。
+var ErrUnauthenticated = errors.New("admin unauthenticated")
+
+// Admin 对应数据库 admin_user 表的对外字段（不包含 password_hash）。
+type Admin struct {
+	ID        uint64    `json:"id"`
+	Username  string    `json:"username"`
+	Role      Role      `json:"role"`
+	Status    int       `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LoginResult 表示管理员登录成功后的返回：token、过期时间与管理员信息。
+type LoginResult struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Admin     Admin     `json:"admin"`
+}
+
+// Session 表示一次已通过校验的管理员会话。
+type Session struct {
+	ID    string
+	Admin Admin
+}
+
+// Service 定义 admin 模块对外提供的业务接口。
+type Service interface {
+	Login(ctx context.Context, username, password string) (LoginResult, error)
+	Authenticate(ctx context.Context, token string) (Session, error)
+	Logout(ctx context.Context, sessionID string) error
+	Get(ctx context.Context, id uint64) (Admin, error)
+	List(ctx context.Context, req ListAdminRequest) ([]Admin, error)
+	Create(ctx context.Context, operatorID uint64, req CreateAdminRequest) (Admin, error)
+	Update(ctx context.Context, operatorID, id uint64, req UpdateAdminRequest) (Admin, error)
+	ResetPassword(ctx context.Context, operatorID, id uint64, newPassword string) error
+	ChangePassword(ctx context.Context, adminID uint64, sessionID, oldPassword, newPassword string) error
+	RecordAudit(ctx context.Context, e AuditEntry) error
+}
+
+type service struct {
+	db          *sql.DB
+	tokenSecret []byte
+	tokenTTL    time.Duration
+}
+
+// NewService 创建 admin 模块服务。
+func NewService(db *sql.DB, tokenSecret string, tokenTTLSeconds int64) Service {
+	return &service{
+		db:          db,
+		tokenSecret: []byte(strings.TrimSpace(tokenSecret)),
+		tokenTTL:    time.Duration(tokenTTLSeconds) * time.Second,
+	}
+}
+
+// dummyHash 用于账号不存在时也执行一次 bcrypt 比对，抹平响应时间差。
+var dummyHash = []byte("$2a$10$rg4t97NFehD8QARJDqb2m.7BRjIMR.Hp2hudDr9mdwjfQIUIa1ela")
+
+// HashPassword 生成 bcrypt 密码哈希（用于写入 admin_user.password_hash）。
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("password is empty")
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Login 校验用户名密码，创建服务端会话并签发管理员 token。
+func (s *service) Login(ctx context.Context, username, password string) (LoginResult, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return LoginResult{}, errors.New("database disabled")
+	}
+	if len(s.tokenSecret) == 0 {
+		return LoginResult{}, errors.New("ADMIN_TOKEN_SECRET is empty")
+	}
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return LoginResult{}, ErrInvalidCredentials
+	}
+
+	// 2) 读取账号与密码哈希。
+	var a Admin
+	var hash string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, username, password_hash, role, status, created_at
+		FROM admin_user
+		WHERE username = ?
+		LIMIT 1
+	`, username).Scan(&a.ID, &a.Username, &hash, &a.Role, &a.Status, &a.CreatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return LoginResult{}, err
+	}
+	if err == sql.ErrNoRows {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return LoginResult{}, ErrInvalidCredentials
+	}
+
+	// 3) 校验密码与账号状态（禁用账号与密码错误返回同一提示）。
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return LoginResult{}, ErrInvalidCredentials
+	}
+	if a.Status != 1 {
+		return LoginResult{}, ErrInvalidCredentials
+	}
+
+	// 4) 写入 admin_session，token 只携带 sid，是否有效以服务端记录为准。
+	sessionID, err := newSessionID()
+	if err != nil {
+		return LoginResult{}, err
+	}
+	expiresAt := time.Now().Add(s.tokenTTL)
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO admin_session (id, admin_id, expires_at, revoked_at, created_at)
+		VALUES (?, ?, ?, NULL, NOW())
+	`, sessionID, a.ID, expiresAt); err != nil {
+		return LoginResult{}, err
+	}
+
+	token, err := MakeToken(a.ID, sessionID, expiresAt, s.tokenSecret)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	return LoginResult{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		Admin:     a,
+	}, nil
+}
+
+// Authenticate 校验管理员 token，并确认会话未吊销、账号仍为启用状态。
+// 角色每次从库中读取，调整角色后无需重新登录即可生效。
+func (s *service) Authenticate(ctx context.Context, token string) (Session, error) {
+	if s.db == nil {
+		return Session{}, errors.New("database disabled")
+	}
+	if len(s.tokenSecret) == 0 {
+		return Session{}, ErrUnauthenticated
+	}
+	adminID, sessionID, err := ParseToken(token, s.tokenSecret, time.Now())
+	if err != nil {
+		return Session{}, ErrUnauthenticated
+	}
+
+	var a Admin
+	err = s.db.QueryRowContext(ctx, `
+		SELECT a.id, a.username, a.role, a.status, a.created_at
+		FROM admin_session s
+		INNER JOIN admin_user a ON a.id = s.admin_id
+		WHERE s.id = ? AND s.admin_id = ? AND s.revoked_at IS NULL AND s.expires_at > NOW()
+		LIMIT 1
+	`, sessionID, adminID).Scan(&a.ID, &a.Username, &a.Role, &a.Status, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Session{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	if a.Status != 1 {
+		return Session{}, ErrUnauthenticated
+	}
+	return Session{ID: sessionID, Admin: a}, nil
+}
+
+// Logout 吊销指定会话；重复调用视为成功。
+func (s *service) Logout(ctx context.Context, sessionID string) error {
+	if s.db == nil {
+		return errors.New("database disabled")
+	}
+	if sessionID == "" {
+		return errors.New("invalid session")
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE admin_session
+		SET revoked_at = NOW()
+		WHERE id = ? AND revoked_at IS NULL
+	`, sessionID)
+	return err
+}
+
+// Get 获取管理员详情。
+func (s *service) Get(ctx context.Context, id uint64) (Admin, error) {
+	if s.db == nil {
+		return Admin{}, errors.New("database disabled")
+	}
+	if id == 0 {
+		return Admin{}, errors.New("invalid id")
+	}
+	var a Admin
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, username, role, status, created_at
+		FROM admin_user
+		WHERE id = ?
+		LIMIT 1
+	`, id).Scan(&a.ID, &a.Username, &a.Role, &a.Status, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Admin{}, errors.New("admin not found")
+	}
+	if err != nil {
+		return Admin{}, err
+	}
+	return a, nil
+}
+
+func newSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,128 @@
+package admin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenAudience 是管理员 token 的 aud 声明，用于与用户 token 区分。
+const TokenAudience = "admin"
+
+// MakeToken 生成管理员 JWT（HS256），携带 sid 指向服务端会话记录。
+func MakeToken(adminID uint64, sessionID string, expiresAt time.Time, secret []byte) (string, error) {
+	if adminID == 0 {
+		return "", fmt.Errorf("invalid adminID")
+	}
+	if sessionID == "" {
+		return "", fmt.Errorf("invalid sessionID")
+	}
+	if len(secret) == 0 {
+		return "", fmt.Errorf("empty secret")
+	}
+
+	exp := expiresAt.Unix()
+	if exp <= 0 {
+		return "", fmt.Errorf("invalid expiresAt")
+	}
+
+	headerJSON, err := json.Marshal(map[string]string{
+		"alg": "HS256",
+		"typ": "JWT",
+	})
+	if err != nil {
+		return "", err
+	}
+	payloadJSON, err := json.Marshal(map[string]any{
+		"sub": strconv.FormatUint(adminID, 10),
+		"sid": sessionID,
+		"aud": TokenAudience,
+		"exp": exp,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(headerJSON) + "." + enc.EncodeToString(payloadJSON)
+
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(signingInput))
+	sig := enc.EncodeToString(mac.Sum(nil))
+
+	return signingInput + "." + sig, nil
+}
+
+// ParseToken 校验管理员 token 的签名/aud/过期时间，返回管理员 ID 与会话 ID。
+// 注意：这里只做无状态校验，会话是否被吊销由 Service.Authenticate 查库判断。
+func ParseToken(token string, secret []byte, now time.Time) (uint64, string, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return 0, "", fmt.Errorf("empty token")
+	}
+	if len(secret) == 0 {
+		return 0, "", fmt.Errorf("empty secret")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, "", fmt.Errorf("invalid token format")
+	}
+
+	enc := base64.RawURLEncoding
+	headerBytes, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return 0, "", fmt.Errorf("invalid header")
+	}
+	if header.Alg != "HS256" {
+		return 0, "", fmt.Errorf("unsupported alg")
+	}
+
+	// 先验签再解析 payload，避免对伪造内容做任何业务判断。
+	signingInput := parts[0] + "." + parts[1]
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(signingInput))
+	gotSig, err := enc.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(gotSig, mac.Sum(nil)) {
+		return 0, "", fmt.Errorf("invalid signature")
+	}
+
+	payloadBytes, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid payload")
+	}
+	var payload struct {
+		Sub string `json:"sub"`
+		Sid string `json:"sid"`
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+	}
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return 0, "", fmt.Errorf("invalid payload")
+	}
+	if payload.Aud != TokenAudience {
+		return 0, "", fmt.Errorf("invalid aud")
+	}
+	if payload.Exp <= 0 || now.Unix() > payload.Exp {
+		return 0, "", fmt.Errorf("token expired")
+	}
+	if payload.Sid == "" {
+		return 0, "", fmt.Errorf("invalid sid")
+	}
+	adminID, err := strconv.ParseUint(payload.Sub, 10, 64)
+	if err != nil || adminID == 0 {
+		return 0, "", fmt.Errorf("invalid sub")
+	}
+	return adminID, payload.Sid, nil
+}
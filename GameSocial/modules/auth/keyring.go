@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// 支持的 JWT 签名算法。
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+)
+
+// LegacyKID 是 AUTH_TOKEN_SECRET 对应的 kid；header 不带 kid 的旧 token 也用它校验。
+const LegacyKID = "default"
+
+// KeyringConfig 描述用户 token 密钥环的来源（由 config.Config 组装）。
+type KeyringConfig struct {
+	// Alg: 新签发 token 使用的算法（HS256/EdDSA/RS256），默认 HS256。
+	Alg string
+	// ActiveKID: 当前用于签发的 kid；为空时 HS256 默认使用 LegacyKID。
+	ActiveKID string
+	// LegacySecret: AUTH_TOKEN_SECRET，作为 kid=default 的 HS256 密钥。
+	LegacySecret string
+	// HMACKeys: 其他 HS256 密钥，kid -> secret（旧密钥仅用于校验）。
+	HMACKeys map[string]string
+	// PrivateKeyPEM: EdDSA/RS256 时当前签名私钥（PKCS#8/PKCS#1 PEM）。
+	PrivateKeyPEM string
+	// PublicKeysPEM: 旧的非对称公钥，kid -> PEM（轮换后用于校验尚未过期的 token）。
+	PublicKeysPEM map[string]string
+}
+
+// Keyring 持有一把当前签名密钥和若干仅用于校验的旧密钥。
+// 每把密钥绑定唯一算法，校验时 header.alg 必须与 kid 对应密钥的算法一致，避免算法混淆攻击。
+type Keyring struct {
+	active *jwtKey
+	keys   map[string]*jwtKey
+}
+
+type jwtKey struct {
+	kid    string
+	alg    string
+	secret []byte
+	signer crypto.Signer
+	public crypto.PublicKey
+}
+
+// NewKeyring 根据配置构建密钥环。
+func NewKeyring(cfg KeyringConfig) (*Keyring, error) {
+	k := &Keyring{keys: map[string]*jwtKey{}}
+
+	// 1) 对称密钥：AUTH_TOKEN_SECRET + AUTH_TOKEN_KEYS。
+	if s := strings.TrimSpace(cfg.LegacySecret); s != "" {
+		k.keys[LegacyKID] = &jwtKey{kid: LegacyKID, alg: AlgHS256, secret: []byte(s)}
+	}
+	for kid, secret := range cfg.HMACKeys {
+		kid, secret = strings.TrimSpace(kid), strings.TrimSpace(secret)
+		if kid == "" || secret == "" {
+			return nil, errors.New("invalid hmac key entry")
+		}
+		if _, ok := k.keys[kid]; ok {
+			return nil, fmt.Errorf("duplicate kid %q", kid)
+		}
+		k.keys[kid] = &jwtKey{kid: kid, alg: AlgHS256, secret: []byte(secret)}
+	}
+
+	// 2) 旧的非对称公钥（仅校验）。
+	for kid, pemText := range cfg.PublicKeysPEM {
+		kid = strings.TrimSpace(kid)
+		if kid == "" {
+			return nil, errors.New("invalid public key entry")
+		}
+		if _, ok := k.keys[kid]; ok {
+			return nil, fmt.Errorf("duplicate kid %q", kid)
+		}
+		pub, alg, err := parsePublicKeyPEM(pemText)
+		if err != nil {
+			return nil, fmt.Errorf("public key %q: %w", kid, err)
+		}
+		k.keys[kid] = &jwtKey{kid: kid, alg: alg, public: pub}
+	}
+
+	// 3) 选择签名密钥。
+	alg := strings.TrimSpace(cfg.Alg)
+	if alg == "" {
+		alg = AlgHS256
+	}
+	activeKID := strings.TrimSpace(cfg.ActiveKID)
+	switch alg {
+	case AlgHS256:
+		if activeKID == "" {
+			activeKID = LegacyKID
+		}
+		key, ok := k.keys[activeKID]
+		if !ok || key.alg != AlgHS256 {
+			return nil, fmt.Errorf("active kid %q has no HS256 secret", activeKID)
+		}
+		k.active = key
+	case AlgEdDSA, AlgRS256:
+		if activeKID == "" {
+			return nil, errors.New("AUTH_TOKEN_ACTIVE_KID is required for asymmetric alg")
+		}
+		if _, ok := k.keys[activeKID]; ok {
+			return nil, fmt.Errorf("duplicate kid %q", activeKID)
+		}
+		signer, err := parsePrivateKeyPEM(cfg.PrivateKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		keyAlg := algForPublicKey(signer.Public())
+		if keyAlg != alg {
+			return nil, fmt.Errorf("private key type does not match alg %s", alg)
+		}
+		key := &jwtKey{kid: activeKID, alg: alg, signer: signer, public: signer.Public()}
+		k.keys[activeKID] = key
+		k.active = key
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", alg)
+	}
+	return k, nil
+}
+
+// sign 使用当前密钥对 signingInput 签名（header 中的 alg/kid 需与 active 一致）。
+func (k *Keyring) sign(signingInput string) ([]byte, error) {
+	if k == nil || k.active == nil {
+		return nil, errors.New("empty keyring")
+	}
+	key := k.active
+	switch key.alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, key.secret)
+		_, _ = mac.Write([]byte(signingInput))
+		return mac.Sum(nil), nil
+	case AlgEdDSA:
+		return key.signer.Sign(rand.Reader, []byte(signingInput), crypto.Hash(0))
+	case AlgRS256:
+		sum := sha256.Sum256([]byte(signingInput))
+		return key.signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+	default:
+		return nil, errors.New("unsupported alg")
+	}
+}
+
+// verify 按 header 中的 kid 找到密钥并校验签名；不带 kid 的旧 token 使用 LegacyKID。
+func (k *Keyring) verify(alg, kid, signingInput string, sig []byte) error {
+	if k == nil {
+		return errors.New("empty keyring")
+	}
+	if kid == "" {
+		kid = LegacyKID
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return errors.New("unknown kid")
+	}
+	if key.alg != alg {
+		return errors.New("alg mismatch")
+	}
+	switch key.alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, key.secret)
+		_, _ = mac.Write([]byte(signingInput))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case AlgEdDSA:
+		pub, _ := key.public.(ed25519.PublicKey)
+		if !ed25519.Verify(pub, []byte(signingInput), sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case AlgRS256:
+		pub, _ := key.public.(*rsa.PublicKey)
+		if pub == nil {
+			return errors.New("invalid key")
+		}
+		sum := sha256.Sum256([]byte(signingInput))
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig)
+	default:
+		return errors.New("unsupported alg")
+	}
+}
+
+// JWK 是 JWKS 中单把公钥的表示（RFC 7517）。
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS 返回全部非对称公钥，供其他服务无需密钥即可校验 token；HS256 密钥不会公开。
+func (k *Keyring) JWKS() []JWK {
+	out := make([]JWK, 0)
+	if k == nil {
+		return out
+	}
+	enc := base64.RawURLEncoding
+	for _, key := range k.keys {
+		switch pub := key.public.(type) {
+		case ed25519.PublicKey:
+			out = append(out, JWK{Kty: "OKP", Kid: key.kid, Alg: AlgEdDSA, Use: "sig", Crv: "Ed25519", X: enc.EncodeToString(pub)})
+		case *rsa.PublicKey:
+			out = append(out, JWK{
+				Kty: "RSA", Kid: key.kid, Alg: AlgRS256, Use: "sig",
+				N: enc.EncodeToString(pub.N.Bytes()),
+				E: enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Kid < out[j].Kid })
+	return out
+}
+
+// ParseKeyList 解析 "kid1:value1,kid2:value2" 形式的配置串（value 中不能含逗号，PEM 请先 base64）。
+func ParseKeyList(v string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kid, value, ok := strings.Cut(item, ":")
+		if !ok || strings.TrimSpace(kid) == "" || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("invalid key entry %q (want kid:value)", item)
+		}
+		out[strings.TrimSpace(kid)] = strings.TrimSpace(value)
+	}
+	return out, nil
+}
+
+func parsePrivateKeyPEM(pemText string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(pemText)))
+	if block == nil {
+		return nil, errors.New("invalid private key pem")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch k := key.(type) {
+		case ed25519.PrivateKey:
+			return k, nil
+		case *rsa.PrivateKey:
+			return k, nil
+		}
+		return nil, errors.New("unsupported private key type")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("invalid private key")
+}
+
+func parsePublicKeyPEM(pemText string) (crypto.PublicKey, string, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(pemText)))
+	if block == nil {
+		return nil, "", errors.New("invalid public key pem")
+	}
+	var pub crypto.PublicKey
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		pub = key
+	} else if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		pub = key
+	} else {
+		return nil, "", errors.New("invalid public key")
+	}
+	alg := algForPublicKey(pub)
+	if alg == "" {
+		return nil, "", errors.New("unsupported public key type")
+	}
+	return pub, alg, nil
+}
+
+func algForPublicKey(pub crypto.PublicKey) string {
+	switch pub.(type) {
+	case ed25519.PublicKey:
+		return AlgEdDSA
+	case *rsa.PublicKey:
+		return AlgRS256
+	default:
+		return ""
+	}
+}
@@ -5,10 +5,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
-	"log"
 	"time"
 
+	"gamesocial/internal/cache"
 	"gamesocial/internal/wechat"
 )
 
@@ -22,46 +21,87 @@ type User struct {
 	Status    int    `json:"status"`
 }
 
-// LoginResult 表示登录成功后的返回：token 与用户信息。
+// LoginResult 表示登录/刷新成功后的返回：短期 access token、可轮换的 refresh token 与用户信息。
 type LoginResult struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	// Token: access token，放在 Authorization: Bearer 中访问接口。
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// RefreshToken: 用于 POST /api/auth/refresh 换取新 token，每次使用后轮换。
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+	User             User      `json:"user"`
+}
+
+// ErrDevLoginDisabled 表示未开启开发登录模式（DEV_LOGIN_ENABLED），不允许 openid 直登。
+var ErrDevLoginDisabled = errors.New("openid 直登仅在开发登录模式下可用")
+
+// ErrDevOpenIDNotAllowed 表示 openid 不在开发登录白名单内。
+var ErrDevOpenIDNotAllowed = errors.New("openid 不在测试白名单内")
+
+// DevLoginConfig 描述开发登录模式：开启后允许白名单内的测试 openid 跳过 code2session 直接登录。
+type DevLoginConfig struct {
+	Enabled bool
+	OpenIDs []string
 }
 
 // Service 定义 auth 模块对外提供的业务接口。
 type Service interface {
 	WechatLogin(ctx context.Context, code string) (LoginResult, error)
 	OpenIDLogin(ctx context.Context, openID string) (LoginResult, error)
+	Refresh(ctx context.Context, refreshToken string) (LoginResult, error)
+	Authenticate(ctx context.Context, token string) (Session, error)
+	Logout(ctx context.Context, userID uint64, sessionID string) error
+	LogoutAll(ctx context.Context, userID uint64) error
 }
 
 type service struct {
 	db           *sql.DB
-	wechatClient *wechat.Client
-	tokenSecret  []byte
+	wechatClient wechat.SessionProvider
+	devOpenIDs   map[string]bool
+	keys         *Keyring
 	tokenTTL     time.Duration
+	refreshTTL   time.Duration
+	cache        cache.Cache
+	cacheTTL     time.Duration
 }
 
 // NewService 创建 auth 模块服务。
-func NewService(db *sql.DB, wechatClient *wechat.Client, tokenSecret string, tokenTTLSeconds int64) Service {
+// keys 为用户 token 签名密钥环；tokenTTLSeconds 为 access token 有效期，refreshTTLSeconds 为 refresh token（会话）有效期。
+// statusCache 用于缓存每次请求的会话/封禁状态校验结果（为 nil 或 statusCacheTTLSeconds<=0 时每次查库）。
+// devLogin 未开启时 OpenIDLogin 一律拒绝。
+func NewService(db *sql.DB, wechatClient wechat.SessionProvider, keys *Keyring, tokenTTLSeconds, refreshTTLSeconds int64, statusCache cache.Cache, statusCacheTTLSeconds int64, devLogin DevLoginConfig) Service {
+	if statusCacheTTLSeconds <= 0 {
+		statusCache = nil
+	}
+	var devOpenIDs map[string]bool
+	if devLogin.Enabled {
+		devOpenIDs = make(map[string]bool, len(devLogin.OpenIDs))
+		for _, id := range devLogin.OpenIDs {
+			devOpenIDs[id] = true
+		}
+	}
 	return &service{
 		db:           db,
 		wechatClient: wechatClient,
-		tokenSecret:  []byte(tokenSecret),
+		devOpenIDs:   devOpenIDs,
+		keys:         keys,
 		tokenTTL:     time.Duration(tokenTTLSeconds) * time.Second,
+		refreshTTL:   time.Duration(refreshTTLSeconds) * time.Second,
+		cache:        statusCache,
+		cacheTTL:     time.Duration(statusCacheTTLSeconds) * time.Second,
 	}
 }
 
 // WechatLogin 使用小程序 wx.login() 的 code 完成登录并签发 token。
 func (s *service) WechatLogin(ctx context.Context, code string) (LoginResult, error) {
-	log.Printf("WechatLogin: code=%s", code)
 	if s.wechatClient == nil {
 		return LoginResult{}, errors.New("wechat client is nil")
 	}
 	if s.db == nil {
 		return LoginResult{}, errors.New("database disabled")
 	}
-	if len(s.tokenSecret) == 0 {
-		return LoginResult{}, errors.New("AUTH_TOKEN_SECRET is empty")
+	if s.keys == nil {
+		return LoginResult{}, errors.New("auth token key is not configured")
 	}
 	if code == "" {
 		return LoginResult{}, errors.New("code is empty")
@@ -77,53 +117,40 @@ func (s *service) WechatLogin(ctx context.Context, code string) (LoginResult, er
 		return LoginResult{}, err
 	}
 	if u.Status == 0 {
-		return LoginResult{}, fmt.Errorf("user is banned")
+		return LoginResult{}, ErrUserBanned
 	}
 
-	token, err := MakeTokenV1(u.ID, time.Now().Add(s.tokenTTL), s.tokenSecret)
-	if err != nil {
-		return LoginResult{}, err
-	}
-	log.Println(token)
-	return LoginResult{
-		Token: token,
-		User:  u,
-	}, nil
+	return s.issueSession(ctx, u)
 }
 
-// OpenIDLogin 使用 openid 直接完成登录（临时方案：跳过 code2session）。
+// OpenIDLogin 使用 openid 直接完成登录（跳过 code2session，无任何身份证明）。
+// 仅开发登录模式下可用，且 openid 必须在测试白名单内。
 func (s *service) OpenIDLogin(ctx context.Context, openID string) (LoginResult, error) {
+	if s.devOpenIDs == nil {
+		return LoginResult{}, ErrDevLoginDisabled
+	}
 	if s.db == nil {
 		return LoginResult{}, errors.New("database disabled")
 	}
 	if openID == "" {
 		return LoginResult{}, errors.New("openId is empty")
 	}
+	if !s.devOpenIDs[openID] {
+		return LoginResult{}, ErrDevOpenIDNotAllowed
+	}
 
 	u, err := s.ensureUser(ctx, openID, "")
 	if err != nil {
 		return LoginResult{}, err
 	}
 	if u.Status == 0 {
-		return LoginResult{}, fmt.Errorf("user is banned")
+		return LoginResult{}, ErrUserBanned
 	}
 
-	token, err := MakeTokenV1(u.ID, time.Now().Add(s.tokenTTL), s.tokenSecret)
-	if err != nil {
-		return LoginResult{}, err
+	if s.keys == nil {
+		return LoginResult{}, errors.New("auth token key is not configured")
 	}
-	if len(s.tokenSecret) != 0 && s.tokenTTL > 0 {
-		t, err := MakeTokenV1(u.ID, time.Now().Add(s.tokenTTL), s.tokenSecret)
-		if err != nil {
-			return LoginResult{}, err
-		}
-		token = t
-	}
-
-	return LoginResult{
-		Token: token,
-		User:  u,
-	}, nil
+	return s.issueSession(ctx, u)
 }
 
 func (s *service) ensureUser(ctx context.Context, openID, unionID string) (User, error) {
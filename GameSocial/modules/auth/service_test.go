@@ -0,0 +1,100 @@
+package auth_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gamesocial/internal/testdb"
+	"gamesocial/internal/wechat"
+	"gamesocial/internal/wechat/wechatfake"
+	"gamesocial/modules/auth"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+func newWechatAuth(t *testing.T, db *sql.DB) (*wechatfake.Server, auth.Service) {
+	t.Helper()
+	srv := wechatfake.New()
+	t.Cleanup(srv.Close)
+	keys, err := auth.NewKeyring(auth.KeyringConfig{LegacySecret: "test-secret"})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	client := wechat.NewClient(srv.AppID, srv.AppSecret, wechat.WithBaseURL(srv.URL))
+	return srv, auth.NewService(db, client, keys, 600, 3600, nil, 0, auth.DevLoginConfig{})
+}
+
+// TestWechatLogin 需要真实 MySQL：未设置 GAMESOCIAL_TEST_DSN 时跳过（见 internal/testdb）。
+func TestWechatLogin(t *testing.T) {
+	srv, svc := newWechatAuth(t, testdb.Open(t))
+	openID := fmt.Sprintf("test-openid-%d", time.Now().UnixNano())
+	srv.AddCode("code-1", openID, "")
+
+	res, err := svc.WechatLogin(context.Background(), "code-1")
+	if err != nil {
+		t.Fatalf("WechatLogin: %v", err)
+	}
+	if res.User.ID == 0 || res.User.OpenID != openID || res.Token == "" || res.RefreshToken == "" {
+		t.Fatalf("WechatLogin = %+v", res)
+	}
+
+	// 签发的 access token 能通过服务端校验，并指向同一用户。
+	sess, err := svc.Authenticate(context.Background(), res.Token)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if sess.UserID != res.User.ID {
+		t.Fatalf("Authenticate userId = %d, want %d", sess.UserID, res.User.ID)
+	}
+
+	// 同一 openid 再次登录复用用户。
+	srv.AddCode("code-2", openID, "")
+	again, err := svc.WechatLogin(context.Background(), "code-2")
+	if err != nil {
+		t.Fatalf("WechatLogin again: %v", err)
+	}
+	if again.User.ID != res.User.ID {
+		t.Fatalf("second login userId = %d, want %d", again.User.ID, res.User.ID)
+	}
+}
+
+func TestWechatLoginErrCode(t *testing.T) {
+	// code2session 失败时不会访问数据库：sql.Open 不建立连接，无需测试库。
+	db, err := sql.Open("mysql", "test:test@tcp(127.0.0.1:1)/gamesocial_test")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	srv, svc := newWechatAuth(t, db)
+	srv.SetError(wechatfake.PathCode2Session, wechatfake.ErrCodeInvalidCode, "invalid code")
+
+	_, err = svc.WechatLogin(context.Background(), "code-1")
+	var apiErr *wechat.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrCode != wechatfake.ErrCodeInvalidCode {
+		t.Fatalf("WechatLogin err = %v, want errcode %d", err, wechatfake.ErrCodeInvalidCode)
+	}
+}
+
+func TestWechatLoginReachesDB(t *testing.T) {
+	// code2session 成功后才会访问数据库：指向不可达的库，错误应来自数据库而不是微信接口。
+	db, err := sql.Open("mysql", "test:test@tcp(127.0.0.1:1)/gamesocial_test?timeout=1s")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	srv, svc := newWechatAuth(t, db)
+	srv.AddCode("code-1", "openid-1", "")
+
+	_, err = svc.WechatLogin(context.Background(), "code-1")
+	var apiErr *wechat.APIError
+	if err == nil || errors.As(err, &apiErr) {
+		t.Fatalf("WechatLogin err = %v, want database error", err)
+	}
+	if n := srv.Calls(wechatfake.PathCode2Session); n != 1 {
+		t.Fatalf("code2session calls = %d, want 1", n)
+	}
+}
@@ -0,0 +1,370 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrUnauthenticated 表示 access token 无效、过期或会话已被吊销。
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ErrUserBanned 表示用户已被封禁（status=0）。
+var ErrUserBanned = errors.New("user banned")
+
+// ErrInvalidRefreshToken 表示 refresh token 无效、过期或已被使用。
+var ErrInvalidRefreshToken = errors.New("refresh token 无效或已过期，请重新登录")
+
+// Session 表示一次已通过校验的用户会话。
+type Session struct {
+	ID     string
+	UserID uint64
+}
+
+// issueSession 创建 user_session 记录并签发 access/refresh token。
+func (s *service) issueSession(ctx context.Context, u User) (LoginResult, error) {
+	sessionID, err := randomHex(16)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return LoginResult{}, err
+	}
+	now := time.Now()
+	refreshExpiresAt := now.Add(s.refreshTTL)
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_session (id, user_id, refresh_hash, prev_refresh_hash, expires_at, revoked_at, created_at, updated_at)
+		VALUES (?, ?, ?, NULL, ?, NULL, NOW(), NOW())
+	`, sessionID, u.ID, hashRefreshToken(refreshToken), refreshExpiresAt); err != nil {
+		return LoginResult{}, err
+	}
+
+	accessExpiresAt := now.Add(s.tokenTTL)
+	token, err := MakeTokenV1(u.ID, sessionID, accessExpiresAt, s.keys)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	return LoginResult{
+		Token:            token,
+		ExpiresAt:        accessExpiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+		User:             u,
+	}, nil
+}
+
+// refreshReuseGrace 是旧 refresh token 的重试宽限期：轮换后该时间内再次使用旧值视为客户端重试（首次响应丢失），
+// 重新轮换一次而不吊销会话；超过宽限期再使用才视为盗用。
+const refreshReuseGrace = 30 * time.Second
+
+// lockedSession 是刷新时锁定的会话与用户信息。
+type lockedSession struct {
+	id        string
+	expiresAt time.Time
+	revokedAt sql.NullTime
+	// inGrace 表示距上次轮换未超过 refreshReuseGrace（仅按 prev_refresh_hash 命中时有意义）。
+	inGrace bool
+	user    User
+}
+
+// Refresh 使用 refresh token 换取新的 access/refresh token（refresh token 轮换，旧值立即失效）。
+// 已轮换掉的旧 refresh token 在宽限期内再次使用（弱网重试）会再轮换一次；超过宽限期再使用（可能被盗用）则吊销整个会话。
+func (s *service) Refresh(ctx context.Context, refreshToken string) (LoginResult, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return LoginResult{}, errors.New("database disabled")
+	}
+	if s.keys == nil {
+		return LoginResult{}, errors.New("auth token key is not configured")
+	}
+	if refreshToken == "" {
+		return LoginResult{}, ErrInvalidRefreshToken
+	}
+	hash := hashRefreshToken(refreshToken)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 2) 锁定会话行，串行化同一会话的并发刷新（并发的第二个请求在第一个提交后按 prev_refresh_hash 命中）。
+	sess, err := lockSessionBy(ctx, tx, "refresh_hash", hash)
+	reused := false
+	if err == sql.ErrNoRows {
+		// 3) 旧 refresh token：宽限期内视为重试，否则视为重放并吊销会话。
+		sess, err = lockSessionBy(ctx, tx, "prev_refresh_hash", hash)
+		if err == sql.ErrNoRows {
+			return LoginResult{}, ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return LoginResult{}, err
+		}
+		if !sess.inGrace {
+			if sess.revokedAt.Valid {
+				return LoginResult{}, ErrInvalidRefreshToken
+			}
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE user_session
+				SET revoked_at = NOW(), updated_at = NOW()
+				WHERE id = ? AND revoked_at IS NULL
+			`, sess.id); err != nil {
+				return LoginResult{}, err
+			}
+			if err := tx.Commit(); err != nil {
+				return LoginResult{}, err
+			}
+			if s.cache != nil {
+				s.cache.Delete(sessionCacheKey(sess.id))
+			}
+			return LoginResult{}, ErrInvalidRefreshToken
+		}
+		reused = true
+	}
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if sess.revokedAt.Valid || !time.Now().Before(sess.expiresAt) {
+		return LoginResult{}, ErrInvalidRefreshToken
+	}
+	u := sess.user
+	if u.Status == 0 {
+		return LoginResult{}, ErrUserBanned
+	}
+
+	// 4) 轮换 refresh token，并顺延会话有效期。
+	// 宽限期内的重试：只替换当前值（上一轮签发但客户端未收到的 token 作废），prev_refresh_hash/rotated_at 不变，宽限期不会被顺延。
+	newRefresh, err := newRefreshToken()
+	if err != nil {
+		return LoginResult{}, err
+	}
+	now := time.Now()
+	refreshExpiresAt := now.Add(s.refreshTTL)
+	if reused {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE user_session
+			SET refresh_hash = ?, expires_at = ?, updated_at = NOW()
+			WHERE id = ?
+		`, hashRefreshToken(newRefresh), refreshExpiresAt, sess.id)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE user_session
+			SET refresh_hash = ?, prev_refresh_hash = ?, rotated_at = NOW(), expires_at = ?, updated_at = NOW()
+			WHERE id = ?
+		`, hashRefreshToken(newRefresh), hash, refreshExpiresAt, sess.id)
+	}
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return LoginResult{}, err
+	}
+
+	accessExpiresAt := now.Add(s.tokenTTL)
+	token, err := MakeTokenV1(u.ID, sess.id, accessExpiresAt, s.keys)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	return LoginResult{
+		Token:            token,
+		ExpiresAt:        accessExpiresAt,
+		RefreshToken:     newRefresh,
+		RefreshExpiresAt: refreshExpiresAt,
+		User:             u,
+	}, nil
+}
+
+// lockSessionBy 按 refresh_hash 或 prev_refresh_hash 锁定会话行（column 只接受这两个固定列名）。
+func lockSessionBy(ctx context.Context, tx *sql.Tx, column, hash string) (lockedSession, error) {
+	if column != "refresh_hash" && column != "prev_refresh_hash" {
+		return lockedSession{}, errors.New("invalid session column")
+	}
+	var sess lockedSession
+	u := &sess.user
+	err := tx.QueryRowContext(ctx, `
+		SELECT s.id, s.expires_at, s.revoked_at,
+			IFNULL(s.rotated_at > NOW() - INTERVAL ? SECOND, 0),
+			u.id, u.openid, IFNULL(u.unionid, ''), IFNULL(u.nickname, ''), IFNULL(u.avatar_url, ''), u.status
+		FROM user_session s
+		INNER JOIN user u ON u.id = s.user_id
+		WHERE s.`+column+` = ?
+		LIMIT 1
+		FOR UPDATE
+	`, int64(refreshReuseGrace/time.Second), hash).Scan(&sess.id, &sess.expiresAt, &sess.revokedAt, &sess.inGrace,
+		&u.ID, &u.OpenID, &u.UnionID, &u.Nickname, &u.AvatarURL, &u.Status)
+	return sess, err
+}
+
+// sessionState 是 Authenticate 查库结果的缓存值。
+type sessionState struct {
+	userID uint64
+	// banned 表示用户已封禁（优先于会话状态判断，便于返回明确的封禁提示）。
+	banned bool
+	valid  bool
+}
+
+func sessionCacheKey(sessionID string) string {
+	return "auth:session:" + sessionID
+}
+
+// Authenticate 校验 access token，并确认用户未被封禁、会话未被吊销且未过期。
+// 查库结果会短暂缓存（statusCacheTTL），封禁/吊销最多延迟一个缓存周期生效。
+func (s *service) Authenticate(ctx context.Context, token string) (Session, error) {
+	if s.db == nil {
+		return Session{}, errors.New("database disabled")
+	}
+	if s.keys == nil {
+		return Session{}, ErrUnauthenticated
+	}
+	claims, err := ParseTokenV1(token, s.keys, time.Now())
+	if err != nil {
+		return Session{}, ErrUnauthenticated
+	}
+
+	st, err := s.loadSessionState(ctx, claims.SessionID)
+	if err != nil {
+		return Session{}, err
+	}
+	if st.userID != claims.UserID {
+		return Session{}, ErrUnauthenticated
+	}
+	if st.banned {
+		return Session{}, ErrUserBanned
+	}
+	if !st.valid {
+		return Session{}, ErrUnauthenticated
+	}
+	return Session{ID: claims.SessionID, UserID: claims.UserID}, nil
+}
+
+// loadSessionState 读取会话与用户状态：先查缓存，未命中再查库并回填（不存在的会话也会缓存）。
+func (s *service) loadSessionState(ctx context.Context, sessionID string) (sessionState, error) {
+	key := sessionCacheKey(sessionID)
+	if s.cache != nil {
+		if v, ok := s.cache.Get(key); ok {
+			if st, ok := v.(sessionState); ok {
+				return st, nil
+			}
+		}
+	}
+
+	var (
+		st     sessionState
+		status int
+		valid  bool
+	)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT s.user_id, u.status, (s.revoked_at IS NULL AND s.expires_at > NOW())
+		FROM user_session s
+		INNER JOIN user u ON u.id = s.user_id
+		WHERE s.id = ?
+		LIMIT 1
+	`, sessionID).Scan(&st.userID, &status, &valid)
+	if err != nil && err != sql.ErrNoRows {
+		return sessionState{}, err
+	}
+	if err == nil {
+		st.banned = status == 0
+		st.valid = valid
+	}
+
+	if s.cache != nil {
+		s.cache.Set(key, st, s.cacheTTL)
+	}
+	return st, nil
+}
+
+// Logout 吊销当前设备的会话；重复调用视为成功。
+func (s *service) Logout(ctx context.Context, userID uint64, sessionID string) error {
+	if s.db == nil {
+		return errors.New("database disabled")
+	}
+	if userID == 0 || sessionID == "" {
+		return errors.New("invalid session")
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE user_session
+		SET revoked_at = NOW(), updated_at = NOW()
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if s.cache != nil {
+		s.cache.Delete(sessionCacheKey(sessionID))
+	}
+	return nil
+}
+
+// LogoutAll 吊销用户在所有设备上的会话。
+func (s *service) LogoutAll(ctx context.Context, userID uint64) error {
+	if s.db == nil {
+		return errors.New("database disabled")
+	}
+	if userID == 0 {
+		return errors.New("invalid userID")
+	}
+
+	// 先取出待吊销的会话 id，吊销后逐个清理本进程缓存。
+	var sessionIDs []string
+	if s.cache != nil {
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT id FROM user_session WHERE user_id = ? AND revoked_at IS NULL
+		`, userID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			sessionIDs = append(sessionIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE user_session
+		SET revoked_at = NOW(), updated_at = NOW()
+		WHERE user_id = ? AND revoked_at IS NULL
+	`, userID); err != nil {
+		return err
+	}
+	for _, id := range sessionIDs {
+		s.cache.Delete(sessionCacheKey(id))
+	}
+	return nil
+}
+
+// newRefreshToken 生成不透明的随机 refresh token；服务端只保存其 SHA-256。
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
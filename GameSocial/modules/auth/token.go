@@ -1,8 +1,6 @@
 package auth
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -11,13 +9,23 @@ import (
 	"time"
 )
 
-// MakeTokenV1 生成一个 JWT token（HS256）。
-func MakeTokenV1(userID uint64, expiresAt time.Time, secret []byte) (string, error) {
+// Claims 表示用户 access token 中解析出的声明。
+type Claims struct {
+	UserID uint64
+	// SessionID 对应服务端 user_session.id，用于吊销校验。
+	SessionID string
+}
+
+// MakeTokenV1 使用密钥环的当前密钥生成 JWT access token，header 带 kid，sid 指向服务端会话记录。
+func MakeTokenV1(userID uint64, sessionID string, expiresAt time.Time, keys *Keyring) (string, error) {
 	if userID == 0 {
 		return "", fmt.Errorf("invalid userID")
 	}
-	if len(secret) == 0 {
-		return "", fmt.Errorf("empty secret")
+	if sessionID == "" {
+		return "", fmt.Errorf("invalid sessionID")
+	}
+	if keys == nil || keys.active == nil {
+		return "", fmt.Errorf("empty keyring")
 	}
 
 	exp := expiresAt.Unix()
@@ -26,14 +34,16 @@ func MakeTokenV1(userID uint64, expiresAt time.Time, secret []byte) (string, err
 	}
 
 	headerJSON, err := json.Marshal(map[string]string{
-		"alg": "HS256",
+		"alg": keys.active.alg,
 		"typ": "JWT",
+		"kid": keys.active.kid,
 	})
 	if err != nil {
 		return "", err
 	}
 	payloadJSON, err := json.Marshal(map[string]any{
 		"sub": strconv.FormatUint(userID, 10),
+		"sid": sessionID,
 		"exp": exp,
 	})
 	if err != nil {
@@ -45,76 +55,84 @@ func MakeTokenV1(userID uint64, expiresAt time.Time, secret []byte) (string, err
 	payload := enc.EncodeToString(payloadJSON)
 	signingInput := header + "." + payload
 
-	mac := hmac.New(sha256.New, secret)
-	_, _ = mac.Write([]byte(signingInput))
-	sig := enc.EncodeToString(mac.Sum(nil))
-
-	return signingInput + "." + sig, nil
+	sig, err := keys.sign(signingInput)
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
 }
 
-func ParseTokenV1(token string, secret []byte, now time.Time) (uint64, error) {
+// ParseTokenV1 校验 access token 的签名与过期时间并返回声明。
+// 按 header.kid 选择校验密钥：当前密钥与轮换前的旧密钥都能通过，未知 kid 一律拒绝。
+// 注意：这里只做无状态校验，会话是否被吊销由 Service.Authenticate 查库判断。
+func ParseTokenV1(token string, keys *Keyring, now time.Time) (Claims, error) {
 	token = strings.TrimSpace(token)
 	if token == "" {
-		return 0, fmt.Errorf("empty token")
+		return Claims{}, fmt.Errorf("empty token")
 	}
-	if len(secret) == 0 {
-		return 0, fmt.Errorf("empty secret")
+	if keys == nil {
+		return Claims{}, fmt.Errorf("empty keyring")
 	}
 
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
-		return 0, fmt.Errorf("invalid token format")
+		return Claims{}, fmt.Errorf("invalid token format")
 	}
 
 	enc := base64.RawURLEncoding
 	headerBytes, err := enc.DecodeString(parts[0])
 	if err != nil {
-		return 0, fmt.Errorf("invalid header")
+		return Claims{}, fmt.Errorf("invalid header")
 	}
 	var header struct {
 		Alg string `json:"alg"`
 		Typ string `json:"typ"`
+		Kid string `json:"kid"`
 	}
 	if err := json.Unmarshal(headerBytes, &header); err != nil {
-		return 0, fmt.Errorf("invalid header")
+		return Claims{}, fmt.Errorf("invalid header")
+	}
+
+	// 先验签再解析 payload，避免对伪造内容做任何业务判断。
+	gotSig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("invalid signature")
 	}
-	if header.Alg != "HS256" {
-		return 0, fmt.Errorf("unsupported alg")
+	if err := keys.verify(header.Alg, header.Kid, parts[0]+"."+parts[1], gotSig); err != nil {
+		return Claims{}, fmt.Errorf("invalid signature: %w", err)
 	}
 
 	payloadBytes, err := enc.DecodeString(parts[1])
 	if err != nil {
-		return 0, fmt.Errorf("invalid payload")
+		return Claims{}, fmt.Errorf("invalid payload")
 	}
 	var payload struct {
 		Sub string `json:"sub"`
+		Sid string `json:"sid"`
+		Aud string `json:"aud"`
 		Exp int64  `json:"exp"`
 	}
 	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
-		return 0, fmt.Errorf("invalid payload")
+		return Claims{}, fmt.Errorf("invalid payload")
+	}
+	// 用户 token 不带 aud；带 aud 的（如管理员 token）一律拒绝，避免两套 token 串用。
+	if payload.Aud != "" {
+		return Claims{}, fmt.Errorf("invalid aud")
 	}
 	if payload.Exp <= 0 {
-		return 0, fmt.Errorf("invalid exp")
+		return Claims{}, fmt.Errorf("invalid exp")
 	}
 	if now.Unix() > payload.Exp {
-		return 0, fmt.Errorf("token expired")
+		return Claims{}, fmt.Errorf("token expired")
 	}
 
 	userID, err := strconv.ParseUint(payload.Sub, 10, 64)
 	if err != nil || userID == 0 {
-		return 0, fmt.Errorf("invalid sub")
-	}
-
-	signingInput := parts[0] + "." + parts[1]
-	mac := hmac.New(sha256.New, secret)
-	_, _ = mac.Write([]byte(signingInput))
-	expectedSig := mac.Sum(nil)
-	gotSig, err := enc.DecodeString(parts[2])
-	if err != nil {
-		return 0, fmt.Errorf("invalid signature")
+		return Claims{}, fmt.Errorf("invalid sub")
 	}
-	if !hmac.Equal(gotSig, expectedSig) {
-		return 0, fmt.Errorf("invalid signature")
+	// 旧版无 sid 的 token 无法吊销，一律要求重新登录。
+	if payload.Sid == "" {
+		return Claims{}, fmt.Errorf("invalid sid")
 	}
-	return userID, nil
+	return Claims{UserID: userID, SessionID: payload.Sid}, nil
 }
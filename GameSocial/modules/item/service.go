@@ -23,6 +23,9 @@ type Goods struct {
 	Status      int       `json:"status"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
+
+	// PickupWindowHours: 兑换后的取货期限（小时）；nil 表示使用全局配置，0 表示不限期。
+	PickupWindowHours *int `json:"pickupWindowHours,omitempty"`
 }
 
 // CreateGoodsRequest 创建商品的入参。
@@ -33,6 +36,9 @@ type CreateGoodsRequest struct {
 	PointsPrice int64    `json:"pointsPrice"`
 	Stock       int      `json:"stock"`
 	Status      int      `json:"status"`
+
+	// PickupWindowHours: 取货期限（小时）；不传表示使用全局配置，0 表示不限期。
+	PickupWindowHours *int `json:"pickupWindowHours,omitempty"`
 }
 
 // UpdateGoodsRequest 更新商品入参（只更新可变字段）。
@@ -43,8 +49,17 @@ type UpdateGoodsRequest struct {
 	PointsPrice int64    `json:"pointsPrice"`
 	Stock       int      `json:"stock"`
 	Status      int      `json:"status"`
+
+	// PickupWindowHours: 取货期限（小时）；不传保持原值，0 表示不限期，PickupWindowUseDefault(-1) 表示恢复使用全局配置。
+	PickupWindowHours *int `json:"pickupWindowHours,omitempty"`
 }
 
+// PickupWindowUseDefault 用于更新商品时清除单独配置的取货期限，恢复使用全局配置。
+const PickupWindowUseDefault = -1
+
+// errPickupWindowUnsupported: 旧表结构缺少 pickup_window_hours 时，设置取货期限的请求直接失败。
+var errPickupWindowUnsupported = errors.New("pickupWindowHours requires goods.pickup_window_hours (see the upgrade notes in gamesocial_init.sql)")
+
 // ListGoodsRequest 列表查询入参（分页 + 状态筛选）。
 type ListGoodsRequest struct {
 	Offset int `json:"offset"`
@@ -85,6 +100,9 @@ func (s *service) CreateGoods(ctx context.Context, req CreateGoodsRequest) (Good
 	if req.Stock < 0 {
 		return Goods{}, errors.New("stock must be >= 0")
 	}
+	if req.PickupWindowHours != nil && *req.PickupWindowHours < 0 {
+		return Goods{}, errors.New("pickupWindowHours must be >= 0")
+	}
 	if req.Status == 0 {
 		req.Status = 1
 	}
@@ -107,10 +125,14 @@ func (s *service) CreateGoods(ctx context.Context, req CreateGoodsRequest) (Good
 
 	// 2) 写入 goods 表：cover_url 允许为空，因此用 NULLIF(?, '') 转成 NULL。
 	res, err := s.db.ExecContext(ctx, `
-		INSERT INTO goods (name, cover_url, image_urls_json, points_price, stock, status, created_at)
-		VALUES (?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, NOW())
-	`, req.Name, req.CoverURL, imageURLsJSON, req.PointsPrice, req.Stock, req.Status)
+		INSERT INTO goods (name, cover_url, image_urls_json, points_price, stock, status, pickup_window_hours, created_at)
+		VALUES (?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?, NOW())
+	`, req.Name, req.CoverURL, imageURLsJSON, req.PointsPrice, req.Stock, req.Status, req.PickupWindowHours)
 	if err != nil && isUnknownColumn(err, "image_urls_json") {
+		// 旧表结构也没有 pickup_window_hours：传了取货期限时直接报错，不静默丢弃。
+		if req.PickupWindowHours != nil {
+			return Goods{}, errPickupWindowUnsupported
+		}
 		res, err = s.db.ExecContext(ctx, `
 			INSERT INTO goods (name, cover_url, points_price, stock, status, created_at)
 			VALUES (?, NULLIF(?, ''), ?, ?, ?, NOW())
@@ -145,6 +167,9 @@ func (s *service) UpdateGoods(ctx context.Context, id uint64, req UpdateGoodsReq
 	if req.Stock < 0 {
 		return Goods{}, errors.New("stock must be >= 0")
 	}
+	if req.PickupWindowHours != nil && *req.PickupWindowHours < PickupWindowUseDefault {
+		return Goods{}, errors.New("pickupWindowHours must be >= 0 (or -1 to use the global setting)")
+	}
 	if req.Status == 0 {
 		req.Status = 1
 	}
@@ -164,13 +189,27 @@ func (s *service) UpdateGoods(ctx context.Context, id uint64, req UpdateGoodsReq
 		}
 		imageURLsJSON = string(b)
 	}
-	// 2) 更新 goods 表可变字段。
-	result, err := s.db.ExecContext(ctx, `
+	// 2) 更新 goods 表可变字段；取货期限只在请求携带时更新（不传保持原值，-1 写 NULL 恢复使用全局配置）。
+	query := `
 		UPDATE goods
-		SET name = ?, cover_url = NULLIF(?, ''), image_urls_json = NULLIF(?, ''), points_price = ?, stock = ?, status = ?
-		WHERE id = ?
-	`, req.Name, req.CoverURL, imageURLsJSON, req.PointsPrice, req.Stock, req.Status, id)
+		SET name = ?, cover_url = NULLIF(?, ''), image_urls_json = NULLIF(?, ''), points_price = ?, stock = ?, status = ?`
+	args := []any{req.Name, req.CoverURL, imageURLsJSON, req.PointsPrice, req.Stock, req.Status}
+	if req.PickupWindowHours != nil {
+		var window any
+		if *req.PickupWindowHours != PickupWindowUseDefault {
+			window = *req.PickupWindowHours
+		}
+		query += ", pickup_window_hours = ?"
+		args = append(args, window)
+	}
+	query += `
+		WHERE id = ?`
+	args = append(args, id)
+	result, err := s.db.ExecContext(ctx, query, args...)
 	if err != nil && isUnknownColumn(err, "image_urls_json") {
+		if req.PickupWindowHours != nil {
+			return Goods{}, errPickupWindowUnsupported
+		}
 		result, err = s.db.ExecContext(ctx, `
 			UPDATE goods
 			SET name = ?, cover_url = NULLIF(?, ''), points_price = ?, stock = ?, status = ?
@@ -228,16 +267,18 @@ func (s *service) GetGoods(ctx context.Context, id uint64) (Goods, error) {
 		return Goods{}, errors.New("invalid id")
 	}
 
-	// 2) 查询单条记录：cover_url 可空，用 sql.NullString 承接。
+	// 2) 查询单条记录：cover_url/pickup_window_hours 可空，用 sql.Null* 承接。
+	// 旧表结构（缺 image_urls_json/updated_at）同样没有 pickup_window_hours，降级查询时取货期限留空（使用全局配置）。
 	var g Goods
 	var cover, imageURLs sql.NullString
+	var window sql.NullInt64
 	row := s.db.QueryRowContext(ctx, `
-		SELECT id, name, cover_url, image_urls_json, points_price, stock, status, created_at, updated_at
+		SELECT id, name, cover_url, image_urls_json, points_price, stock, status, created_at, updated_at, pickup_window_hours
 		FROM goods
 		WHERE id = ?
 		LIMIT 1
 	`, id)
-	err := row.Scan(&g.ID, &g.Name, &cover, &imageURLs, &g.PointsPrice, &g.Stock, &g.Status, &g.CreatedAt, &g.UpdatedAt)
+	err := row.Scan(&g.ID, &g.Name, &cover, &imageURLs, &g.PointsPrice, &g.Stock, &g.Status, &g.CreatedAt, &g.UpdatedAt, &window)
 	if err != nil && (isUnknownColumn(err, "image_urls_json") || isUnknownColumn(err, "updated_at")) {
 		missImage := isUnknownColumn(err, "image_urls_json")
 		missUpdated := isUnknownColumn(err, "updated_at")
@@ -287,6 +328,7 @@ func (s *service) GetGoods(ctx context.Context, id uint64) (Goods, error) {
 	if len(g.ImageURLs) == 0 && g.CoverURL != "" {
 		g.ImageURLs = []string{g.CoverURL}
 	}
+	g.PickupWindowHours = windowHours(window)
 	return g, nil
 }
 
@@ -318,16 +360,19 @@ func (s *service) ListGoods(ctx context.Context, req ListGoodsRequest) ([]Goods,
 	args = append(args, req.Limit, req.Offset)
 
 	// 3) 查询列表：按 id 倒序，便于后台优先看到最新创建的商品。
+	// 降级查询对应旧表结构，不含 pickup_window_hours（取货期限留空）。
 	withImageURLsJSON := true
 	withUpdatedAt := true
+	withPickupWindow := true
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, name, IFNULL(cover_url, ''), IFNULL(image_urls_json, ''), points_price, stock, status, created_at, updated_at
+		SELECT id, name, IFNULL(cover_url, ''), IFNULL(image_urls_json, ''), points_price, stock, status, created_at, updated_at, pickup_window_hours
 		FROM goods
 		`+statusClause+`
 		ORDER BY id DESC
 		LIMIT ? OFFSET ?
 	`, args...)
 	if err != nil && (isUnknownColumn(err, "image_urls_json") || isUnknownColumn(err, "updated_at")) {
+		withPickupWindow = false
 		if isUnknownColumn(err, "image_urls_json") {
 			withImageURLsJSON = false
 		}
@@ -360,26 +405,20 @@ func (s *service) ListGoods(ctx context.Context, req ListGoodsRequest) ([]Goods,
 	for rows.Next() {
 		var g Goods
 		var imageURLsJSON string
+		var window sql.NullInt64
+		dest := []any{&g.ID, &g.Name, &g.CoverURL}
 		if withImageURLsJSON {
-			if withUpdatedAt {
-				if err := rows.Scan(&g.ID, &g.Name, &g.CoverURL, &imageURLsJSON, &g.PointsPrice, &g.Stock, &g.Status, &g.CreatedAt, &g.UpdatedAt); err != nil {
-					return nil, err
-				}
-			} else {
-				if err := rows.Scan(&g.ID, &g.Name, &g.CoverURL, &imageURLsJSON, &g.PointsPrice, &g.Stock, &g.Status, &g.CreatedAt); err != nil {
-					return nil, err
-				}
-			}
-		} else {
-			if withUpdatedAt {
-				if err := rows.Scan(&g.ID, &g.Name, &g.CoverURL, &g.PointsPrice, &g.Stock, &g.Status, &g.CreatedAt, &g.UpdatedAt); err != nil {
-					return nil, err
-				}
-			} else {
-				if err := rows.Scan(&g.ID, &g.Name, &g.CoverURL, &g.PointsPrice, &g.Stock, &g.Status, &g.CreatedAt); err != nil {
-					return nil, err
-				}
-			}
+			dest = append(dest, &imageURLsJSON)
+		}
+		dest = append(dest, &g.PointsPrice, &g.Stock, &g.Status, &g.CreatedAt)
+		if withUpdatedAt {
+			dest = append(dest, &g.UpdatedAt)
+		}
+		if withPickupWindow {
+			dest = append(dest, &window)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
 		}
 		if strings.TrimSpace(imageURLsJSON) != "" {
 			var list []string
@@ -390,6 +429,7 @@ func (s *service) ListGoods(ctx context.Context, req ListGoodsRequest) ([]Goods,
 		if len(g.ImageURLs) == 0 && g.CoverURL != "" {
 			g.ImageURLs = []string{g.CoverURL}
 		}
+		g.PickupWindowHours = windowHours(window)
 		out = append(out, g)
 	}
 	if err := rows.Err(); err != nil {
@@ -398,6 +438,15 @@ func (s *service) ListGoods(ctx context.Context, req ListGoodsRequest) ([]Goods,
 	return out, nil
 }
 
+// windowHours 把可空的 pickup_window_hours 转为响应字段（NULL 表示使用全局配置）。
+func windowHours(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	hours := int(v.Int64)
+	return &hours
+}
+
 func isUnknownColumn(err error, column string) bool {
 	if err == nil {
 		return false
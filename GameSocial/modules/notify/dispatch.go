@@ -0,0 +1,362 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"gamesocial/internal/wechat"
+)
+
+const (
+	// retryBaseDelay/retryMaxDelay: 失败重试的指数退避区间。
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = time.Hour
+	// sendingStaleAfter: SENDING 超过该时长视为进程中断，退回订阅次数并重新放回队列。
+	sendingStaleAfter = 5 * time.Minute
+	// maxErrorLen: last_error 列长度上限。
+	maxErrorLen = 512
+)
+
+// Enqueue 写入一条待发送消息；事件未配置模板时不入队。
+// data 为模板关键词 -> 值，入队时即固化，发送时不再回查业务数据。
+func (s *service) Enqueue(ctx context.Context, userID uint64, event, bizID, page string, data map[string]string) (bool, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return false, errors.New("database disabled")
+	}
+	if userID == 0 || bizID == "" {
+		return false, errors.New("invalid params")
+	}
+	templateID := s.cfg.Templates[event]
+	if templateID == "" {
+		return false, nil
+	}
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+
+	// 2) 唯一键 (user_id, event, biz_id) 去重：扫描任务重复执行不会重复发送。
+	res, err := s.db.ExecContext(ctx, `
+		INSERT IGNORE INTO notify_delivery
+			(user_id, event, biz_id, template_id, page, data_json, status, attempts, next_attempt_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), ?, 'PENDING', 0, NOW(), NOW(), NOW())
+	`, userID, event, bizID, templateID, page, string(dataJSON))
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// pendingDelivery 是 Dispatch 读取的一条待发送记录。
+type pendingDelivery struct {
+	id         uint64
+	userID     uint64
+	event      string
+	templateID string
+	page       string
+	data       []byte
+	attempts   int
+	openID     string
+}
+
+// Dispatch 发送到期消息：
+// - 认领（PENDING -> SENDING 条件更新）与扣减订阅次数在同一事务内，多实例并发执行时每条只会被一个实例发送；
+// - 次数不足直接 SKIPPED；SENDING 状态的记录一定已扣过次数，回收时按条退回；
+// - 可重试错误按指数退避重新排队，达到 MaxAttempts 后置为 FAILED。
+func (s *service) Dispatch(ctx context.Context, limit int) (int, error) {
+	if s.db == nil {
+		return 0, errors.New("database disabled")
+	}
+	if s.sender == nil {
+		return 0, errors.New("sender not configured")
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	// 1) 回收卡在 SENDING 的记录（进程在发送过程中退出），并退回认领时扣减的次数。
+	if err := s.reclaimStale(ctx); err != nil {
+		return 0, err
+	}
+
+	// 2) 取出到期的待发送记录。
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.id, d.user_id, d.event, d.template_id, IFNULL(d.page, ''), d.data_json, d.attempts, u.openid
+		FROM notify_delivery d
+		INNER JOIN user u ON u.id = d.user_id
+		WHERE d.status = 'PENDING' AND d.next_attempt_at <= NOW()
+		ORDER BY d.next_attempt_at ASC, d.id ASC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+	batch := make([]pendingDelivery, 0, limit)
+	for rows.Next() {
+		var d pendingDelivery
+		if err := rows.Scan(&d.id, &d.userID, &d.event, &d.templateID, &d.page, &d.data, &d.attempts, &d.openID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, d)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	// 3) 逐条认领并发送。
+	processed := 0
+	for _, d := range batch {
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+		ok, err := s.deliver(ctx, d)
+		if err != nil {
+			return processed, err
+		}
+		if ok {
+			processed++
+		}
+	}
+	return processed, nil
+}
+
+// deliver 处理一条记录；返回 false 表示已被其他实例认领。
+func (s *service) deliver(ctx context.Context, d pendingDelivery) (bool, error) {
+	// 1) 认领并扣减订阅次数（一次性订阅消息每次授权只能发送一条）：同一事务提交，
+	// 保证 SENDING 的记录都已扣过次数，回收时退回不会多退或少退。
+	claimed, quota, err := s.claim(ctx, d)
+	if err != nil || !claimed || !quota {
+		return claimed, err
+	}
+	d.attempts++
+
+	// 2) 发送。
+	var values map[string]string
+	if err := json.Unmarshal(d.data, &values); err != nil {
+		return true, s.release(ctx, d, errors.New("invalid data_json: "+err.Error()), true)
+	}
+	msg := wechat.SubscribeMessage{
+		ToUser:           d.openID,
+		TemplateID:       d.templateID,
+		Page:             d.page,
+		MiniprogramState: s.cfg.MiniprogramState,
+		Lang:             "zh_CN",
+		Data:             make(map[string]wechat.SubscribeValue, len(values)),
+	}
+	for k, v := range values {
+		msg.Data[k] = wechat.SubscribeValue{Value: v}
+	}
+	sendErr := s.sender.SendSubscribeMessage(ctx, msg)
+	if sendErr == nil {
+		return true, s.finish(ctx, d.id, StatusSent, "")
+	}
+
+	// 3) 失败处理：43101 说明用户在微信侧已无可用次数，清零本地额度；其余错误退回额度。
+	var apiErr *wechat.APIError
+	// 先离开 SENDING 再清零，避免中途退出后被回收时又退回一次。
+	if errors.As(sendErr, &apiErr) && apiErr.ErrCode == wechat.ErrCodeSubscribeRefused {
+		if err := s.finish(ctx, d.id, StatusSkipped, sendErr.Error()); err != nil {
+			return true, err
+		}
+		_, err := s.db.ExecContext(ctx, `
+			UPDATE notify_subscription SET remaining = 0, updated_at = NOW() WHERE user_id = ? AND event = ?
+		`, d.userID, d.event)
+		return true, err
+	}
+	return true, s.release(ctx, d, sendErr, isPermanent(sendErr))
+}
+
+// claim 在一个事务内认领记录并扣减订阅次数；claimed=false 表示已被其他实例认领。
+// 次数不足时 quota=false，并在同一事务内直接置为 SKIPPED。
+func (s *service) claim(ctx context.Context, d pendingDelivery) (claimed, quota bool, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 1) 只有 PENDING 才能进入 SENDING。
+	res, err := tx.ExecContext(ctx, `
+		UPDATE notify_delivery
+		SET status = 'SENDING', attempts = attempts + 1, updated_at = NOW()
+		WHERE id = ? AND status = 'PENDING'
+	`, d.id)
+	if err != nil {
+		return false, false, err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return false, false, nil
+	}
+
+	// 2) 扣减次数；不足则 SKIPPED，不进入发送。
+	res, err = tx.ExecContext(ctx, `
+		UPDATE notify_subscription
+		SET remaining = remaining - 1, updated_at = NOW()
+		WHERE user_id = ? AND event = ? AND remaining > 0
+	`, d.userID, d.event)
+	if err != nil {
+		return false, false, err
+	}
+	quota = true
+	if n, _ := res.RowsAffected(); n != 1 {
+		quota = false
+		if _, err := finishWith(ctx, tx.ExecContext, d.id, StatusSkipped, "no subscription quota"); err != nil {
+			return false, false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, false, err
+	}
+	return true, quota, nil
+}
+
+// reclaimStale 把超时的 SENDING 记录放回 PENDING，并逐条退回认领时扣减的订阅次数。
+// 进程若在发送成功后、写入 SENT 前退出，该消息会被再次发送（至少一次语义）。
+func (s *service) reclaimStale(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 1) 锁定超时记录，避免多实例重复回收、重复退回。
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, user_id, event
+		FROM notify_delivery
+		WHERE status = 'SENDING' AND updated_at < NOW() - INTERVAL ? SECOND
+		FOR UPDATE
+	`, int64(sendingStaleAfter/time.Second))
+	if err != nil {
+		return err
+	}
+	var stale []pendingDelivery
+	for rows.Next() {
+		var d pendingDelivery
+		if err := rows.Scan(&d.id, &d.userID, &d.event); err != nil {
+			rows.Close()
+			return err
+		}
+		stale = append(stale, d)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+	if len(stale) == 0 {
+		return nil
+	}
+
+	// 2) 放回队列并退回次数。
+	for _, d := range stale {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE notify_delivery SET status = 'PENDING', updated_at = NOW() WHERE id = ? AND status = 'SENDING'
+		`, d.id); err != nil {
+			return err
+		}
+		if err := refundWith(ctx, tx.ExecContext, d); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// release 退回订阅次数并写入失败结果：permanent 或达到最大次数时置为 FAILED，否则按指数退避重新排队。
+// 状态更新与退回在同一事务内，且仅当记录仍为 SENDING 时退回，不会与 reclaimStale 重复退回。
+func (s *service) release(ctx context.Context, d pendingDelivery, cause error, permanent bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var res sql.Result
+	if permanent || d.attempts >= s.cfg.MaxAttempts {
+		res, err = finishWith(ctx, tx.ExecContext, d.id, StatusFailed, cause.Error())
+	} else {
+		res, err = tx.ExecContext(ctx, `
+			UPDATE notify_delivery
+			SET status = 'PENDING', next_attempt_at = NOW() + INTERVAL ? SECOND, last_error = ?, updated_at = NOW()
+			WHERE id = ? AND status = 'SENDING'
+		`, int64(retryDelay(d.attempts)/time.Second), truncateError(cause.Error()), d.id)
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		if err := refundWith(ctx, tx.ExecContext, d); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// finish 把记录置为终态（SENT/FAILED/SKIPPED）。
+func (s *service) finish(ctx context.Context, id uint64, status, lastError string) error {
+	_, err := finishWith(ctx, s.db.ExecContext, id, status, lastError)
+	return err
+}
+
+// finishWith 同 finish；exec 为 *sql.DB 或 *sql.Tx 的 ExecContext。
+func finishWith(ctx context.Context, exec func(context.Context, string, ...any) (sql.Result, error), id uint64, status, lastError string) (sql.Result, error) {
+	return exec(ctx, `
+		UPDATE notify_delivery
+		SET status = ?, last_error = NULLIF(?, ''), sent_at = IF(? = 'SENT', NOW(), sent_at), updated_at = NOW()
+		WHERE id = ? AND status = 'SENDING'
+	`, status, truncateError(lastError), status, id)
+}
+
+// refundWith 退回认领时扣减的订阅次数（消息未送达）；exec 为 *sql.DB 或 *sql.Tx 的 ExecContext。
+func refundWith(ctx context.Context, exec func(context.Context, string, ...any) (sql.Result, error), d pendingDelivery) error {
+	_, err := exec(ctx, `
+		UPDATE notify_subscription SET remaining = remaining + 1, updated_at = NOW() WHERE user_id = ? AND event = ?
+	`, d.userID, d.event)
+	return err
+}
+
+// isPermanent 判断错误是否重试也不会成功（openid/模板/参数错误）。
+func isPermanent(err error) bool {
+	var apiErr *wechat.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrCode {
+	case wechat.ErrCodeInvalidOpenID, wechat.ErrCodeInvalidTemplate, wechat.ErrCodeInvalidTemplateData:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryDelay 返回第 attempts 次失败后的等待时间：30s、60s、120s ... 最长 1 小时。
+func retryDelay(attempts int) time.Duration {
+	d := retryBaseDelay
+	for i := 1; i < attempts && d < retryMaxDelay; i++ {
+		d *= 2
+	}
+	if d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d
+}
+
+func truncateError(s string) string {
+	if len(s) <= maxErrorLen {
+		return s
+	}
+	s = s[:maxErrorLen]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
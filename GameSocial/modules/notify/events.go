@@ -0,0 +1,212 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// 模板关键词约定（在微信公众平台选用模板时按此顺序配置关键词）：
+// - TOURNAMENT_START:  thing1=赛事名称；time2=开始时间；thing3=温馨提示
+// - TOURNAMENT_RESULT: thing1=赛事名称；character_string2=名次；thing3=温馨提示
+// - REDEEM_USED:       character_string1=订单号；time2=核销时间；thing3=温馨提示
+// - VIP_EXPIRING:      time1=到期时间；thing2=温馨提示
+const (
+	timeLayout = "2006-01-02 15:04"
+	// thingMaxRunes: thing 类关键词最多 20 个字符，超出会被微信拒绝（47003）。
+	thingMaxRunes = 20
+	// recentWindow: 结果发布/核销事件的回溯范围，超过该时长的旧事件不再补发。
+	recentWindow = 24 * time.Hour
+)
+
+// 点击消息后跳转的小程序页面。
+const (
+	pageTournament = "pages/tournament/detail?id="
+	pageRedeem     = "pages/redeem/detail?orderNo="
+	pageVip        = "pages/vip/index"
+)
+
+// ScanTournamentStarts 为即将开始（TournamentLead 内）的已发布赛事的报名用户入队提醒。
+func (s *service) ScanTournamentStarts(ctx context.Context) (int, error) {
+	if s.cfg.Templates[EventTournamentStart] == "" || s.cfg.TournamentLead <= 0 {
+		return 0, nil
+	}
+	if s.db == nil {
+		return 0, errors.New("database disabled")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.user_id, t.id, t.title, t.start_at
+		FROM tournament t
+		INNER JOIN tournament_participant p ON p.tournament_id = t.id AND p.join_status = 'JOINED'
+		INNER JOIN notify_subscription ns ON ns.user_id = p.user_id AND ns.event = ? AND ns.remaining > 0
+		WHERE t.status = 'PUBLISHED' AND t.start_at > NOW() AND t.start_at <= NOW() + INTERVAL ? SECOND
+	`, EventTournamentStart, int64(s.cfg.TournamentLead/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	return s.enqueueRows(ctx, EventTournamentStart, rows, func(rows *sql.Rows) (uint64, string, string, map[string]string, error) {
+		var userID, tournamentID uint64
+		var title string
+		var startAt time.Time
+		if err := rows.Scan(&userID, &tournamentID, &title, &startAt); err != nil {
+			return 0, "", "", nil, err
+		}
+		id := strconv.FormatUint(tournamentID, 10)
+		return userID, id, pageTournament + id, map[string]string{
+			"thing1": thing(title),
+			"time2":  startAt.Format(timeLayout),
+			"thing3": "比赛即将开始，请提前到场签到",
+		}, nil
+	})
+}
+
+// ScanTournamentResults 为最近发布成绩的赛事参赛用户入队成绩通知。
+func (s *service) ScanTournamentResults(ctx context.Context) (int, error) {
+	if s.cfg.Templates[EventTournamentResult] == "" {
+		return 0, nil
+	}
+	if s.db == nil {
+		return 0, errors.New("database disabled")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.user_id, t.id, t.title, r.rank_no
+		FROM tournament_result r
+		INNER JOIN tournament t ON t.id = r.tournament_id
+		INNER JOIN notify_subscription ns ON ns.user_id = r.user_id AND ns.event = ? AND ns.remaining > 0
+		WHERE r.published_at >= NOW() - INTERVAL ? SECOND
+	`, EventTournamentResult, int64(recentWindow/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	return s.enqueueRows(ctx, EventTournamentResult, rows, func(rows *sql.Rows) (uint64, string, string, map[string]string, error) {
+		var userID, tournamentID uint64
+		var title string
+		var rankNo int
+		if err := rows.Scan(&userID, &tournamentID, &title, &rankNo); err != nil {
+			return 0, "", "", nil, err
+		}
+		id := strconv.FormatUint(tournamentID, 10)
+		return userID, id, pageTournament + id, map[string]string{
+			"thing1":            thing(title),
+			"character_string2": strconv.Itoa(rankNo),
+			"thing3":            "赛事成绩已公布，点击查看",
+		}, nil
+	})
+}
+
+// ScanRedeemUsed 为最近核销的兑换订单入队核销通知。
+func (s *service) ScanRedeemUsed(ctx context.Context) (int, error) {
+	if s.cfg.Templates[EventRedeemUsed] == "" {
+		return 0, nil
+	}
+	if s.db == nil {
+		return 0, errors.New("database disabled")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT o.user_id, o.order_no, o.used_at
+		FROM redeem_order o
+		INNER JOIN notify_subscription ns ON ns.user_id = o.user_id AND ns.event = ? AND ns.remaining > 0
+		WHERE o.status = 'USED' AND o.used_at >= NOW() - INTERVAL ? SECOND
+	`, EventRedeemUsed, int64(recentWindow/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	return s.enqueueRows(ctx, EventRedeemUsed, rows, func(rows *sql.Rows) (uint64, string, string, map[string]string, error) {
+		var userID uint64
+		var orderNo string
+		var usedAt time.Time
+		if err := rows.Scan(&userID, &orderNo, &usedAt); err != nil {
+			return 0, "", "", nil, err
+		}
+		return userID, orderNo, pageRedeem + orderNo, map[string]string{
+			"character_string1": orderNo,
+			"time2":             usedAt.Format(timeLayout),
+			"thing3":            "兑换订单已核销",
+		}, nil
+	})
+}
+
+// ScanVipExpiring 为 VipExpireAhead 内到期且没有后续续费记录的会员入队到期提醒。
+func (s *service) ScanVipExpiring(ctx context.Context) (int, error) {
+	if s.cfg.Templates[EventVipExpiring] == "" || s.cfg.VipExpireAhead <= 0 {
+		return 0, nil
+	}
+	if s.db == nil {
+		return 0, errors.New("database disabled")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT v.user_id, v.id, v.end_at
+		FROM vip_subscription v
+		INNER JOIN notify_subscription ns ON ns.user_id = v.user_id AND ns.event = ? AND ns.remaining > 0
+		WHERE v.status = 'ACTIVE' AND v.end_at > NOW() AND v.end_at <= NOW() + INTERVAL ? SECOND
+			AND NOT EXISTS (
+				SELECT 1 FROM vip_subscription v2
+				WHERE v2.user_id = v.user_id AND v2.status = 'ACTIVE' AND v2.end_at > v.end_at
+			)
+	`, EventVipExpiring, int64(s.cfg.VipExpireAhead/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	return s.enqueueRows(ctx, EventVipExpiring, rows, func(rows *sql.Rows) (uint64, string, string, map[string]string, error) {
+		var userID, subID uint64
+		var endAt time.Time
+		if err := rows.Scan(&userID, &subID, &endAt); err != nil {
+			return 0, "", "", nil, err
+		}
+		return userID, strconv.FormatUint(subID, 10), pageVip, map[string]string{
+			"time1":  endAt.Format(timeLayout),
+			"thing2": "会员即将到期，续费可继续享受权益",
+		}, nil
+	})
+}
+
+// enqueueRows 读取扫描结果（先读完再写，避免占用连接）并逐条入队，返回新入队条数。
+func (s *service) enqueueRows(ctx context.Context, event string, rows *sql.Rows, scan func(*sql.Rows) (uint64, string, string, map[string]string, error)) (int, error) {
+	type item struct {
+		userID uint64
+		bizID  string
+		page   string
+		data   map[string]string
+	}
+	items := make([]item, 0)
+	for rows.Next() {
+		userID, bizID, page, data, err := scan(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		items = append(items, item{userID: userID, bizID: bizID, page: page, data: data})
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	created := 0
+	for _, it := range items {
+		ok, err := s.Enqueue(ctx, it.userID, event, it.bizID, it.page, it.data)
+		if err != nil {
+			return created, err
+		}
+		if ok {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// thing 截断 thing 类关键词到微信允许的长度。
+func thing(s string) string {
+	r := []rune(s)
+	if len(r) <= thingMaxRunes {
+		return s
+	}
+	return string(r[:thingMaxRunes-1]) + "…"
+}
@@ -0,0 +1,272 @@
+// notify 模块负责小程序订阅消息：记录用户授权的模板订阅次数、按事件生成待发送消息、失败重试与投递日志。
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gamesocial/internal/wechat"
+)
+
+// 通知事件（同时作为 notify_subscription.event / notify_delivery.event）。
+const (
+	// EventTournamentStart: 已报名赛事即将开始。
+	EventTournamentStart = "TOURNAMENT_START"
+	// EventTournamentResult: 赛事成绩已发布。
+	EventTournamentResult = "TOURNAMENT_RESULT"
+	// EventRedeemUsed: 兑换订单已核销。
+	EventRedeemUsed = "REDEEM_USED"
+	// EventVipExpiring: 会员即将到期。
+	EventVipExpiring = "VIP_EXPIRING"
+)
+
+// Events 是全部支持的通知事件。
+var Events = []string{EventTournamentStart, EventTournamentResult, EventRedeemUsed, EventVipExpiring}
+
+// 投递状态。
+const (
+	StatusPending = "PENDING"
+	StatusSending = "SENDING"
+	StatusSent    = "SENT"
+	StatusFailed  = "FAILED"
+	StatusSkipped = "SKIPPED"
+)
+
+// Config 是通知模块的运行配置。
+type Config struct {
+	// Templates: 事件 -> 订阅消息模板 ID；未配置的事件不会发送。
+	Templates map[string]string
+	// MiniprogramState: 点击消息跳转的小程序版本 developer/trial/formal。
+	MiniprogramState string
+	// MaxAttempts: 单条消息最多发送次数（含首次）。
+	MaxAttempts int
+	// TournamentLead: 赛事开始前多久提醒。
+	TournamentLead time.Duration
+	// VipExpireAhead: 会员到期前多久提醒。
+	VipExpireAhead time.Duration
+}
+
+// Sender 发送订阅消息（由 wechat.Messenger 实现）。
+type Sender interface {
+	SendSubscribeMessage(ctx context.Context, msg wechat.SubscribeMessage) error
+}
+
+// TemplateStatus 表示某个事件的模板配置与当前用户剩余可接收次数。
+type TemplateStatus struct {
+	Event      string `json:"event"`
+	TemplateID string `json:"templateId"`
+	Remaining  int    `json:"remaining"`
+}
+
+// Delivery 对应 notify_delivery 表（投递日志）。
+type Delivery struct {
+	ID            uint64          `json:"id"`
+	UserID        uint64          `json:"userId"`
+	Event         string          `json:"event"`
+	BizID         string          `json:"bizId"`
+	TemplateID    string          `json:"templateId"`
+	Page          string          `json:"page,omitempty"`
+	Data          json.RawMessage `json:"data,omitempty"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+	LastError     string          `json:"lastError,omitempty"`
+	SentAt        *time.Time      `json:"sentAt,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+}
+
+// ListDeliveryRequest 投递日志查询入参（UserID/Event/Status 为空表示不过滤）。
+type ListDeliveryRequest struct {
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+	UserID uint64 `json:"userId"`
+	Event  string `json:"event"`
+	Status string `json:"status"`
+}
+
+// Service 定义 notify 模块对外提供的业务接口。
+type Service interface {
+	// Templates 返回已配置模板的事件列表及用户剩余订阅次数（userID=0 时 Remaining 恒为 0）。
+	Templates(ctx context.Context, userID uint64) ([]TemplateStatus, error)
+	// Grant 记录 wx.requestSubscribeMessage 的结果：templateId -> accept/reject/ban。
+	Grant(ctx context.Context, userID uint64, results map[string]string) ([]TemplateStatus, error)
+	// Enqueue 为用户生成一条待发送消息；同一 (user, event, bizID) 只会生成一次。
+	Enqueue(ctx context.Context, userID uint64, event, bizID, page string, data map[string]string) (bool, error)
+	// Dispatch 发送到期的待发送消息，返回本次处理条数。
+	Dispatch(ctx context.Context, limit int) (int, error)
+	// ScanTournamentStarts/ScanTournamentResults/ScanRedeemUsed/ScanVipExpiring 扫描业务事件并入队。
+	ScanTournamentStarts(ctx context.Context) (int, error)
+	ScanTournamentResults(ctx context.Context) (int, error)
+	ScanRedeemUsed(ctx context.Context) (int, error)
+	ScanVipExpiring(ctx context.Context) (int, error)
+	// ListDeliveries 查询投递日志（管理端）。
+	ListDeliveries(ctx context.Context, req ListDeliveryRequest) ([]Delivery, error)
+}
+
+type service struct {
+	db     *sql.DB
+	sender Sender
+	cfg    Config
+	// templateEvents: 模板 ID -> 事件，用于解析小程序授权结果。
+	templateEvents map[string]string
+}
+
+// NewService 创建 notify 模块服务。
+func NewService(db *sql.DB, sender Sender, cfg Config) Service {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.MiniprogramState == "" {
+		cfg.MiniprogramState = "formal"
+	}
+	templateEvents := make(map[string]string, len(cfg.Templates))
+	for event, id := range cfg.Templates {
+		if id != "" {
+			templateEvents[id] = event
+		}
+	}
+	return &service{db: db, sender: sender, cfg: cfg, templateEvents: templateEvents}
+}
+
+// Templates 返回已配置模板的事件列表；小程序据此调用 wx.requestSubscribeMessage。
+func (s *service) Templates(ctx context.Context, userID uint64) ([]TemplateStatus, error) {
+	out := make([]TemplateStatus, 0, len(Events))
+	for _, event := range Events {
+		if id := s.cfg.Templates[event]; id != "" {
+			out = append(out, TemplateStatus{Event: event, TemplateID: id})
+		}
+	}
+	if userID == 0 || len(out) == 0 {
+		return out, nil
+	}
+	if s.db == nil {
+		return nil, errors.New("database disabled")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT event, remaining FROM notify_subscription WHERE user_id = ?
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	remaining := map[string]int{}
+	for rows.Next() {
+		var event string
+		var n int
+		if err := rows.Scan(&event, &n); err != nil {
+			return nil, err
+		}
+		remaining[event] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Remaining = remaining[out[i].Event]
+	}
+	return out, nil
+}
+
+// Grant 记录订阅授权：每次 accept 对应一次可发送额度（一次性订阅消息）。
+func (s *service) Grant(ctx context.Context, userID uint64, results map[string]string) ([]TemplateStatus, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return nil, errors.New("database disabled")
+	}
+	if userID == 0 {
+		return nil, errors.New("invalid userID")
+	}
+
+	// 2) 只处理已配置模板的 accept；reject/ban 不改变已有额度。
+	for templateID, result := range results {
+		event, ok := s.templateEvents[templateID]
+		if !ok || strings.ToLower(strings.TrimSpace(result)) != "accept" {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, `
+			INSERT INTO notify_subscription (user_id, event, template_id, remaining, created_at, updated_at)
+			VALUES (?, ?, ?, 1, NOW(), NOW())
+			ON DUPLICATE KEY UPDATE template_id = VALUES(template_id), remaining = remaining + 1, updated_at = NOW()
+		`, userID, event, templateID); err != nil {
+			return nil, err
+		}
+	}
+	return s.Templates(ctx, userID)
+}
+
+// ListDeliveries 查询投递日志，按 id 倒序。
+func (s *service) ListDeliveries(ctx context.Context, req ListDeliveryRequest) ([]Delivery, error) {
+	if s.db == nil {
+		return nil, errors.New("database disabled")
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	if req.Limit > 200 {
+		req.Limit = 200
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	conds := make([]string, 0, 3)
+	args := make([]any, 0, 5)
+	if req.UserID != 0 {
+		conds = append(conds, "user_id = ?")
+		args = append(args, req.UserID)
+	}
+	if req.Event != "" {
+		conds = append(conds, "event = ?")
+		args = append(args, req.Event)
+	}
+	if req.Status != "" {
+		conds = append(conds, "status = ?")
+		args = append(args, req.Status)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, req.Limit, req.Offset)
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, event, biz_id, template_id, IFNULL(page, ''), data_json, status, attempts,
+			next_attempt_at, IFNULL(last_error, ''), sent_at, created_at
+		FROM notify_delivery
+		`+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Delivery, 0, req.Limit)
+	for rows.Next() {
+		var d Delivery
+		var data []byte
+		var sentAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.UserID, &d.Event, &d.BizID, &d.TemplateID, &d.Page, &data, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.LastError, &sentAt, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(data) != 0 {
+			d.Data = json.RawMessage(data)
+		}
+		if sentAt.Valid {
+			t := sentAt.Time
+			d.SentAt = &t
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,441 @@
+package points
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"gamesocial/modules/admin"
+)
+
+// BizTypeAdminAdjust 是管理员积分调整的流水业务类型（biz_id 为调整单 ID）。
+const BizTypeAdminAdjust = "ADMIN_ADJUST"
+
+// 调整原因（points_adjustment.reason_code）。
+const (
+	AdjustReasonCompensation = "COMPENSATION"
+	AdjustReasonActivity     = "ACTIVITY"
+	AdjustReasonCorrection   = "CORRECTION"
+	AdjustReasonOther        = "OTHER"
+)
+
+// adjustReasonLabels 是调整原因的展示名（写入流水备注）。
+var adjustReasonLabels = map[string]string{
+	AdjustReasonCompensation: "服务补偿",
+	AdjustReasonActivity:     "活动奖励",
+	AdjustReasonCorrection:   "错账更正",
+	AdjustReasonOther:        "其他",
+}
+
+// 调整单状态（points_adjustment.status）。
+const (
+	AdjustStatusPending  = "PENDING"
+	AdjustStatusApplied  = "APPLIED"
+	AdjustStatusRejected = "REJECTED"
+)
+
+// 审计动作（admin_audit_log.action）。
+const (
+	auditActionAdjust        = "POINTS_ADJUST"
+	auditActionAdjustRequest = "POINTS_ADJUST_REQUEST"
+	auditActionAdjustApprove = "POINTS_ADJUST_APPROVE"
+	auditActionAdjustReject  = "POINTS_ADJUST_REJECT"
+	auditBizTypeAdjustment   = "POINTS_ADJUSTMENT"
+)
+
+// maxIdempotencyKeyLen: points_adjustment.idempotency_key 列长度上限。
+const maxIdempotencyKeyLen = 64
+
+// 积分调整的业务错误。
+var (
+	ErrAdjustReasonInvalid      = errors.New("请选择有效的调整原因")
+	ErrAdjustReasonNoteRequired = errors.New("调整原因为“其他”时必须填写说明")
+	ErrIdempotencyKeyRequired   = errors.New("缺少幂等键 idempotencyKey")
+	ErrIdempotencyKeyReused     = errors.New("幂等键已用于其他调整")
+	ErrAdjustmentNotFound       = errors.New("调整单不存在")
+	ErrAdjustmentNotPending     = errors.New("调整单已处理")
+	ErrAdjustSelfApprove        = errors.New("不能审批自己发起的调整")
+	ErrUserNotFound             = errors.New("用户不存在")
+)
+
+// Adjustment 对应数据库 points_adjustment 表的数据结构。
+type Adjustment struct {
+	ID             uint64     `json:"id"`
+	UserID         uint64     `json:"userId"`
+	Amount         int64      `json:"amount"`
+	ReasonCode     string     `json:"reasonCode"`
+	ReasonNote     string     `json:"reasonNote"`
+	Status         string     `json:"status"`
+	IdempotencyKey string     `json:"idempotencyKey"`
+	CreatedBy      uint64     `json:"createdBy"`
+	ReviewedBy     uint64     `json:"reviewedBy,omitempty"`
+	ReviewedAt     *time.Time `json:"reviewedAt,omitempty"`
+	ReviewNote     string     `json:"reviewNote,omitempty"`
+	LedgerID       uint64     `json:"ledgerId,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// AdjustRequest 管理员积分调整入参。
+type AdjustRequest struct {
+	UserID uint64 `json:"userId"`
+	// Amount 为正表示增加积分，为负表示扣减积分。
+	Amount     int64  `json:"amount"`
+	ReasonCode string `json:"reasonCode"`
+	ReasonNote string `json:"reasonNote"`
+	// IdempotencyKey 由前端生成（如 UUID），同一管理员重复提交同一 key 只会生成一张调整单。
+	IdempotencyKey string `json:"idempotencyKey"`
+}
+
+// ListAdjustmentRequest 查询调整单列表入参。
+type ListAdjustmentRequest struct {
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+	Status string `json:"status"`
+	UserID uint64 `json:"userId"`
+}
+
+// queryer 是 *sql.DB 与 *sql.Tx 的公共读接口。
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Adjust 创建一笔管理员积分调整：
+// - 绝对值不超过审批阈值时直接入账（status=APPLIED）；
+// - 超过阈值时生成待审批单（status=PENDING），由另一名管理员审批后入账。
+func (s *service) Adjust(ctx context.Context, adminID uint64, req AdjustRequest) (Adjustment, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return Adjustment{}, errors.New("database disabled")
+	}
+	if adminID == 0 {
+		return Adjustment{}, errors.New("invalid adminId")
+	}
+	if req.UserID == 0 {
+		return Adjustment{}, errors.New("userId is empty")
+	}
+	if req.Amount == 0 {
+		return Adjustment{}, errors.New("amount must not be 0")
+	}
+	req.ReasonCode = strings.ToUpper(strings.TrimSpace(req.ReasonCode))
+	req.ReasonNote = strings.TrimSpace(req.ReasonNote)
+	req.IdempotencyKey = strings.TrimSpace(req.IdempotencyKey)
+	if _, ok := adjustReasonLabels[req.ReasonCode]; !ok {
+		return Adjustment{}, ErrAdjustReasonInvalid
+	}
+	if req.ReasonCode == AdjustReasonOther && req.ReasonNote == "" {
+		return Adjustment{}, ErrAdjustReasonNoteRequired
+	}
+	if req.IdempotencyKey == "" {
+		return Adjustment{}, ErrIdempotencyKeyRequired
+	}
+	if len(req.IdempotencyKey) > maxIdempotencyKeyLen {
+		return Adjustment{}, errors.New("idempotencyKey too long")
+	}
+	req.ReasonNote = truncateRemark(req.ReasonNote)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Adjustment{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 2) 幂等：同一管理员的同一 key 直接返回已有调整单（参数不一致视为误用）。
+	if a, err := findAdjustmentByKey(ctx, tx, adminID, req.IdempotencyKey); err == nil {
+		if a.UserID != req.UserID || a.Amount != req.Amount {
+			return Adjustment{}, ErrIdempotencyKeyReused
+		}
+		return a, nil
+	} else if !errors.Is(err, ErrAdjustmentNotFound) {
+		return Adjustment{}, err
+	}
+
+	// 3) 校验目标用户存在。
+	var exists uint64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM user WHERE id = ? LIMIT 1`, req.UserID).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return Adjustment{}, ErrUserNotFound
+		}
+		return Adjustment{}, err
+	}
+
+	// 4) 写调整单（先以 PENDING 落库，拿到 ID 作为流水 biz_id）。
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO points_adjustment
+			(user_id, amount, reason_code, reason_note, status, idempotency_key, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, NULLIF(?, ''), 'PENDING', ?, ?, NOW(), NOW())
+	`, req.UserID, req.Amount, req.ReasonCode, req.ReasonNote, req.IdempotencyKey, adminID)
+	if err != nil {
+		var myErr *mysql.MySQLError
+		if errors.As(err, &myErr) && myErr.Number == mysqlErrDupEntry {
+			// 并发重复提交：另一请求已写入，返回其结果。
+			_ = tx.Rollback()
+			return s.getAdjustmentByKey(ctx, adminID, req.IdempotencyKey)
+		}
+		return Adjustment{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Adjustment{}, err
+	}
+	a := Adjustment{
+		ID:             uint64(id),
+		UserID:         req.UserID,
+		Amount:         req.Amount,
+		ReasonCode:     req.ReasonCode,
+		ReasonNote:     req.ReasonNote,
+		Status:         AdjustStatusPending,
+		IdempotencyKey: req.IdempotencyKey,
+		CreatedBy:      adminID,
+	}
+
+	// 5) 未超过阈值直接入账；超过阈值等待审批。
+	action := auditActionAdjustRequest
+	var ledger Ledger
+	if abs(req.Amount) <= s.cfg.AdjustApprovalThreshold {
+		ledger, err = s.applyAdjustment(ctx, tx, a)
+		if err != nil {
+			return Adjustment{}, err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE points_adjustment SET status = 'APPLIED', ledger_id = ?, updated_at = NOW() WHERE id = ?
+		`, ledger.ID, a.ID); err != nil {
+			return Adjustment{}, err
+		}
+		action = auditActionAdjust
+	}
+
+	// 6) 审计日志与调整单同事务提交。
+	if err := admin.RecordAuditTx(ctx, tx, admin.AuditEntry{
+		AdminID: adminID,
+		Action:  action,
+		BizType: auditBizTypeAdjustment,
+		BizID:   strconv.FormatUint(a.ID, 10),
+		Detail:  adjustAuditDetail(a, ledger, ""),
+	}); err != nil {
+		return Adjustment{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Adjustment{}, err
+	}
+	return s.getAdjustment(ctx, a.ID)
+}
+
+// ApproveAdjustment 审批通过待审批调整单并入账；审批人不能是发起人。
+func (s *service) ApproveAdjustment(ctx context.Context, adminID, id uint64, note string) (Adjustment, error) {
+	return s.reviewAdjustment(ctx, adminID, id, true, note)
+}
+
+// RejectAdjustment 驳回待审批调整单（不入账）。
+func (s *service) RejectAdjustment(ctx context.Context, adminID, id uint64, note string) (Adjustment, error) {
+	return s.reviewAdjustment(ctx, adminID, id, false, note)
+}
+
+func (s *service) reviewAdjustment(ctx context.Context, adminID, id uint64, approve bool, note string) (Adjustment, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return Adjustment{}, errors.New("database disabled")
+	}
+	if adminID == 0 {
+		return Adjustment{}, errors.New("invalid adminId")
+	}
+	if id == 0 {
+		return Adjustment{}, errors.New("invalid id")
+	}
+	note = truncateRemark(strings.TrimSpace(note))
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Adjustment{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 2) 锁定调整单：并发审批只有一个能成功。
+	a, err := scanAdjustment(tx.QueryRowContext(ctx, adjustmentSelect+` WHERE id = ? FOR UPDATE`, id))
+	if err != nil {
+		return Adjustment{}, err
+	}
+	if a.Status != AdjustStatusPending {
+		return Adjustment{}, ErrAdjustmentNotPending
+	}
+
+	// 3) 审批通过则入账；驳回只改状态。
+	action := auditActionAdjustReject
+	var ledger Ledger
+	if approve {
+		if a.CreatedBy == adminID {
+			return Adjustment{}, ErrAdjustSelfApprove
+		}
+		ledger, err = s.applyAdjustment(ctx, tx, a)
+		if err != nil {
+			return Adjustment{}, err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE points_adjustment
+			SET status = 'APPLIED', ledger_id = ?, reviewed_by = ?, reviewed_at = NOW(), review_note = NULLIF(?, ''), updated_at = NOW()
+			WHERE id = ?
+		`, ledger.ID, adminID, note, a.ID); err != nil {
+			return Adjustment{}, err
+		}
+		a.Status = AdjustStatusApplied
+		action = auditActionAdjustApprove
+	} else {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE points_adjustment
+			SET status = 'REJECTED', reviewed_by = ?, reviewed_at = NOW(), review_note = NULLIF(?, ''), updated_at = NOW()
+			WHERE id = ?
+		`, adminID, note, a.ID); err != nil {
+			return Adjustment{}, err
+		}
+		a.Status = AdjustStatusRejected
+	}
+
+	// 4) 审计日志与状态变更同事务提交。
+	if err := admin.RecordAuditTx(ctx, tx, admin.AuditEntry{
+		AdminID: adminID,
+		Action:  action,
+		BizType: auditBizTypeAdjustment,
+		BizID:   strconv.FormatUint(a.ID, 10),
+		Detail:  adjustAuditDetail(a, ledger, note),
+	}); err != nil {
+		return Adjustment{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Adjustment{}, err
+	}
+	return s.getAdjustment(ctx, a.ID)
+}
+
+// ListAdjustments 查询调整单列表（按 ID 倒序）。
+func (s *service) ListAdjustments(ctx context.Context, req ListAdjustmentRequest) ([]Adjustment, error) {
+	// 1) 基础校验与分页兜底。
+	if s.db == nil {
+		return nil, errors.New("database disabled")
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	if req.Limit > 200 {
+		req.Limit = 200
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	// 2) 组装筛选条件：支持按 status/user_id 过滤。
+	where := " WHERE 1=1"
+	args := make([]any, 0, 4)
+	if req.Status != "" {
+		where += " AND status = ?"
+		args = append(args, strings.ToUpper(req.Status))
+	}
+	if req.UserID != 0 {
+		where += " AND user_id = ?"
+		args = append(args, req.UserID)
+	}
+	args = append(args, req.Limit, req.Offset)
+
+	rows, err := s.db.QueryContext(ctx, adjustmentSelect+where+` ORDER BY id DESC LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Adjustment, 0, req.Limit)
+	for rows.Next() {
+		a, err := scanAdjustment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// applyAdjustment 在事务内按调整单记一笔 ADMIN_ADJUST 流水（biz_id=调整单 ID，重复入账会被幂等键拦截）。
+func (s *service) applyAdjustment(ctx context.Context, tx *sql.Tx, a Adjustment) (Ledger, error) {
+	remark := "管理员调整：" + adjustReasonLabels[a.ReasonCode]
+	if a.ReasonNote != "" {
+		remark += "（" + a.ReasonNote + "）"
+	}
+	bizID := strconv.FormatUint(a.ID, 10)
+	if a.Amount > 0 {
+		return s.Credit(ctx, tx, a.UserID, BizTypeAdminAdjust, bizID, a.Amount, remark)
+	}
+	return s.Debit(ctx, tx, a.UserID, BizTypeAdminAdjust, bizID, -a.Amount, remark)
+}
+
+func (s *service) getAdjustment(ctx context.Context, id uint64) (Adjustment, error) {
+	return scanAdjustment(s.db.QueryRowContext(ctx, adjustmentSelect+` WHERE id = ? LIMIT 1`, id))
+}
+
+func (s *service) getAdjustmentByKey(ctx context.Context, adminID uint64, key string) (Adjustment, error) {
+	return findAdjustmentByKey(ctx, s.db, adminID, key)
+}
+
+func findAdjustmentByKey(ctx context.Context, q queryer, adminID uint64, key string) (Adjustment, error) {
+	return scanAdjustment(q.QueryRowContext(ctx, adjustmentSelect+` WHERE created_by = ? AND idempotency_key = ? LIMIT 1`, adminID, key))
+}
+
+const adjustmentSelect = `
+	SELECT id, user_id, amount, reason_code, IFNULL(reason_note, ''), status, idempotency_key, created_by,
+		IFNULL(reviewed_by, 0), reviewed_at, IFNULL(review_note, ''), IFNULL(ledger_id, 0), created_at, updated_at
+	FROM points_adjustment`
+
+// scanAdjustment 读取一行调整单；单行查询无结果时返回 ErrAdjustmentNotFound。
+func scanAdjustment(row interface{ Scan(dest ...any) error }) (Adjustment, error) {
+	var a Adjustment
+	var reviewedAt sql.NullTime
+	err := row.Scan(&a.ID, &a.UserID, &a.Amount, &a.ReasonCode, &a.ReasonNote, &a.Status, &a.IdempotencyKey, &a.CreatedBy,
+		&a.ReviewedBy, &reviewedAt, &a.ReviewNote, &a.LedgerID, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Adjustment{}, ErrAdjustmentNotFound
+		}
+		return Adjustment{}, err
+	}
+	if reviewedAt.Valid {
+		t := reviewedAt.Time
+		a.ReviewedAt = &t
+	}
+	return a, nil
+}
+
+// adjustAuditDetail 组装调整/审批审计日志的 detail_json。
+func adjustAuditDetail(a Adjustment, ledger Ledger, reviewNote string) map[string]any {
+	detail := map[string]any{
+		"user_id":         a.UserID,
+		"delta":           a.Amount,
+		"reason_code":     a.ReasonCode,
+		"reason":          a.ReasonNote,
+		"status":          a.Status,
+		"created_by":      a.CreatedBy,
+		"idempotency_key": a.IdempotencyKey,
+	}
+	if ledger.ID != 0 {
+		detail["status"] = AdjustStatusApplied
+		detail["ledger_id"] = ledger.ID
+		detail["balance_after"] = ledger.BalanceAfter
+	}
+	if reviewNote != "" {
+		detail["review_note"] = reviewNote
+	}
+	return detail
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
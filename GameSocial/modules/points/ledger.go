@@ -0,0 +1,263 @@
+package points
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 流水方向筛选（LedgerQuery.Direction）。
+const (
+	LedgerDirectionIncome = "income"
+	LedgerDirectionSpend  = "spend"
+)
+
+// maxLedgerBizTypes: 单次查询最多筛选的业务类型个数。
+const maxLedgerBizTypes = 10
+
+// ledgerTimeLayout 是游标中 created_at 的格式（与 DATETIME 精度一致）。
+const ledgerTimeLayout = "2006-01-02 15:04:05"
+
+// bizTypePattern 限制 biz_type 筛选值的格式（大写字母/数字/下划线）。
+var bizTypePattern = regexp.MustCompile(`^[A-Z0-9_]{1,32}$`)
+
+// 流水查询的参数错误。
+var (
+	ErrInvalidCursor    = errors.New("cursor 无效")
+	ErrInvalidDate      = errors.New("日期格式应为 yyyy-MM-dd")
+	ErrInvalidMonth     = errors.New("月份格式应为 yyyy-MM")
+	ErrInvalidDirection = errors.New("direction 只能是 income 或 spend")
+	ErrInvalidBizType   = errors.New("bizType 无效")
+)
+
+// LedgerQuery 用户积分流水查询条件（按 created_at、id 倒序）。
+type LedgerQuery struct {
+	// BizTypes 按业务类型筛选（为空表示不限）。
+	BizTypes []string
+	// StartDate/EndDate 按自然日筛选（yyyy-MM-dd，含首尾；为空表示不限）。
+	StartDate string
+	EndDate   string
+	// Direction: income=只看增加；spend=只看扣减；为空表示不限。
+	Direction string
+	// Cursor 为上一页返回的 NextCursor；为空时从最新一条开始（兼容旧的 Offset 分页）。
+	Cursor string
+	Offset int
+	Limit  int
+}
+
+// LedgerPage 是一页流水；NextCursor 为空表示没有更多。
+type LedgerPage struct {
+	Items      []Ledger `json:"items"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+// BizTypeSummary 是某个业务类型在一个月内的积分汇总。
+type BizTypeSummary struct {
+	BizType string `json:"bizType"`
+	Earned  int64  `json:"earned"`
+	Spent   int64  `json:"spent"`
+	Count   int    `json:"count"`
+}
+
+// MonthlySummary 是用户一个自然月的积分汇总（Spent 为正数）。
+type MonthlySummary struct {
+	Month     string           `json:"month"`
+	Earned    int64            `json:"earned"`
+	Spent     int64            `json:"spent"`
+	Net       int64            `json:"net"`
+	ByBizType []BizTypeSummary `json:"byBizType"`
+}
+
+// ListLedgers 查询用户积分流水：支持按业务类型/日期/方向筛选与游标分页。
+// 排序与游标条件均落在 idx_points_ledger_user_created (user_id, created_at) 上（InnoDB 二级索引隐含主键 id）。
+func (s *service) ListLedgers(ctx context.Context, userID uint64, q LedgerQuery) (LedgerPage, error) {
+	// 1) 基础校验与分页兜底。
+	if s.db == nil {
+		return LedgerPage{}, errors.New("database disabled")
+	}
+	if userID == 0 {
+		return LedgerPage{}, errors.New("invalid userId")
+	}
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	if q.Limit > 200 {
+		q.Limit = 200
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	// 2) 组装筛选条件。
+	conds := []string{"l.user_id = ?"}
+	args := []any{userID}
+	if len(q.BizTypes) > 0 {
+		if len(q.BizTypes) > maxLedgerBizTypes {
+			return LedgerPage{}, ErrInvalidBizType
+		}
+		marks := make([]string, 0, len(q.BizTypes))
+		for _, bt := range q.BizTypes {
+			if !bizTypePattern.MatchString(bt) {
+				return LedgerPage{}, ErrInvalidBizType
+			}
+			marks = append(marks, "?")
+			args = append(args, bt)
+		}
+		conds = append(conds, "l.biz_type IN ("+strings.Join(marks, ", ")+")")
+	}
+	if q.StartDate != "" {
+		start, err := time.Parse("2006-01-02", q.StartDate)
+		if err != nil {
+			return LedgerPage{}, ErrInvalidDate
+		}
+		conds = append(conds, "l.created_at >= ?")
+		args = append(args, start.Format(ledgerTimeLayout))
+	}
+	if q.EndDate != "" {
+		end, err := time.Parse("2006-01-02", q.EndDate)
+		if err != nil {
+			return LedgerPage{}, ErrInvalidDate
+		}
+		conds = append(conds, "l.created_at < ?")
+		args = append(args, end.AddDate(0, 0, 1).Format(ledgerTimeLayout))
+	}
+	switch q.Direction {
+	case "":
+	case LedgerDirectionIncome:
+		conds = append(conds, "l.change_amount > 0")
+	case LedgerDirectionSpend:
+		conds = append(conds, "l.change_amount < 0")
+	default:
+		return LedgerPage{}, ErrInvalidDirection
+	}
+
+	// 3) 游标：取排在上一页最后一条之后的记录；有游标时忽略 offset。
+	if q.Cursor != "" {
+		createdAt, id, err := decodeLedgerCursor(q.Cursor)
+		if err != nil {
+			return LedgerPage{}, err
+		}
+		conds = append(conds, "(l.created_at < ? OR (l.created_at = ? AND l.id < ?))")
+		args = append(args, createdAt, createdAt, id)
+		q.Offset = 0
+	}
+
+	// 4) 多取一条判断是否还有下一页；赠送流水关联赠送单（转入看转出方，转出看转入方）。
+	args = append(args, q.Limit+1, q.Offset)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT l.id, l.user_id, l.change_amount, l.balance_after, l.biz_type, l.biz_id, IFNULL(l.remark, ''), l.created_at,
+			IFNULL(u.id, 0), IFNULL(u.nickname, ''), IFNULL(t.message, '')
+		FROM points_ledger l
+		LEFT JOIN points_transfer t
+			ON l.biz_type IN ('TRANSFER_IN', 'TRANSFER_OUT') AND t.transfer_no = l.biz_id
+		LEFT JOIN user u
+			ON u.id = IF(l.biz_type = 'TRANSFER_IN', t.from_user_id, t.to_user_id)
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY l.created_at DESC, l.id DESC
+		LIMIT ? OFFSET ?
+	`, args...)
+	if err != nil {
+		return LedgerPage{}, err
+	}
+	defer rows.Close()
+
+	page := LedgerPage{Items: make([]Ledger, 0, q.Limit)}
+	for rows.Next() {
+		var l Ledger
+		if err := rows.Scan(&l.ID, &l.UserID, &l.ChangeAmount, &l.BalanceAfter, &l.BizType, &l.BizID, &l.Remark, &l.CreatedAt,
+			&l.CounterpartyUserID, &l.CounterpartyNickname, &l.TransferMessage); err != nil {
+			return LedgerPage{}, err
+		}
+		page.Items = append(page.Items, l)
+	}
+	if err := rows.Err(); err != nil {
+		return LedgerPage{}, err
+	}
+
+	// 5) 生成下一页游标。
+	if len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = encodeLedgerCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// MonthlySummary 按业务类型汇总用户某个自然月（yyyy-MM）的获得与消耗积分；month 为空表示当月。
+func (s *service) MonthlySummary(ctx context.Context, userID uint64, month string) (MonthlySummary, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return MonthlySummary{}, errors.New("database disabled")
+	}
+	if userID == 0 {
+		return MonthlySummary{}, errors.New("invalid userId")
+	}
+	if month == "" {
+		month = time.Now().Format("2006-01")
+	}
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return MonthlySummary{}, ErrInvalidMonth
+	}
+
+	// 2) 按 created_at 范围聚合（走 idx_points_ledger_user_created）。
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT biz_type,
+			IFNULL(SUM(IF(change_amount > 0, change_amount, 0)), 0),
+			IFNULL(SUM(IF(change_amount < 0, -change_amount, 0)), 0),
+			COUNT(*)
+		FROM points_ledger
+		WHERE user_id = ? AND created_at >= ? AND created_at < ?
+		GROUP BY biz_type
+		ORDER BY biz_type ASC
+	`, userID, start.Format(ledgerTimeLayout), start.AddDate(0, 1, 0).Format(ledgerTimeLayout))
+	if err != nil {
+		return MonthlySummary{}, err
+	}
+	defer rows.Close()
+
+	out := MonthlySummary{Month: start.Format("2006-01"), ByBizType: make([]BizTypeSummary, 0)}
+	for rows.Next() {
+		var it BizTypeSummary
+		if err := rows.Scan(&it.BizType, &it.Earned, &it.Spent, &it.Count); err != nil {
+			return MonthlySummary{}, err
+		}
+		out.Earned += it.Earned
+		out.Spent += it.Spent
+		out.ByBizType = append(out.ByBizType, it)
+	}
+	if err := rows.Err(); err != nil {
+		return MonthlySummary{}, err
+	}
+	out.Net = out.Earned - out.Spent
+	return out, nil
+}
+
+// encodeLedgerCursor 把排序键 (created_at, id) 编码为不透明游标。
+func encodeLedgerCursor(createdAt time.Time, id uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createdAt.Format(ledgerTimeLayout) + "|" + strconv.FormatUint(id, 10)))
+}
+
+// decodeLedgerCursor 解析游标，返回 created_at（DATETIME 字符串）与 id。
+func decodeLedgerCursor(cursor string) (string, uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	createdAt, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return "", 0, ErrInvalidCursor
+	}
+	if _, err := time.Parse(ledgerTimeLayout, createdAt); err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil || id == 0 {
+		return "", 0, ErrInvalidCursor
+	}
+	return createdAt, id, nil
+}
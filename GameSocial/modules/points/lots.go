@@ -0,0 +1,263 @@
+package points
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// 积分批次（points_lot）：每笔增加的积分生成一个批次，记录剩余数量与到期时间。
+// 扣减时按到期时间先进先出消耗；到期后剩余部分由定时任务写 EXPIRE 流水扣除。
+// 不变量：同一用户所有批次 remaining 之和 = points_account.balance。
+// 扣减流水消耗了哪些批次记在 points_lot_usage，赠送转入、退回时沿用被消耗批次的到期时间。
+
+// lotPart 是一笔扣减从某个批次消耗的积分及该批次的到期时间。
+type lotPart struct {
+	amount    int64
+	expiresAt time.Time
+}
+
+// insertLot 为一笔增加的积分生成批次。
+func (s *service) insertLot(ctx context.Context, tx *sql.Tx, userID, ledgerID uint64, amount int64) error {
+	var ledger any
+	if ledgerID != 0 {
+		ledger = ledgerID
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO points_lot (user_id, ledger_id, amount, remaining, earned_at, expires_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, NOW(), NOW() + INTERVAL ? MONTH, NOW(), NOW())
+	`, userID, ledger, amount, amount, s.cfg.ExpireMonths)
+	return err
+}
+
+// insertLots 为一笔增加的积分生成批次：先按 parts 沿用原到期时间，超出 parts 的部分按 ExpireMonths 起算。
+func (s *service) insertLots(ctx context.Context, tx *sql.Tx, userID, ledgerID uint64, amount int64, parts []lotPart) error {
+	for _, p := range parts {
+		if amount <= 0 {
+			break
+		}
+		take := p.amount
+		if take > amount {
+			take = amount
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO points_lot (user_id, ledger_id, amount, remaining, earned_at, expires_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, NOW(), ?, NOW(), NOW())
+		`, userID, ledgerID, take, take, p.expiresAt); err != nil {
+			return err
+		}
+		amount -= take
+	}
+	if amount <= 0 {
+		return nil
+	}
+	return s.insertLot(ctx, tx, userID, ledgerID, amount)
+}
+
+// lotUsage 读取一笔扣减流水消耗的批次（到期时间晚的在前，部分转入/退回时优先沿用较长的有效期）。
+func lotUsage(ctx context.Context, tx *sql.Tx, ledgerID uint64) ([]lotPart, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT u.amount, l.expires_at
+		FROM points_lot_usage u
+		INNER JOIN points_lot l ON l.id = u.lot_id
+		WHERE u.ledger_id = ?
+		ORDER BY l.expires_at DESC, u.id ASC
+	`, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parts := make([]lotPart, 0, 4)
+	for rows.Next() {
+		var p lotPart
+		if err := rows.Scan(&p.amount, &p.expiresAt); err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+	return parts, rows.Err()
+}
+
+// backfillLot 为批次上线前的历史余额补建批次（从现在起算有效期），调用方需已锁定账户行。
+func (s *service) backfillLot(ctx context.Context, tx *sql.Tx, userID uint64, balance int64) error {
+	var tracked int64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT IFNULL(SUM(remaining), 0) FROM points_lot WHERE user_id = ?
+	`, userID).Scan(&tracked); err != nil {
+		return err
+	}
+	if balance <= tracked {
+		return nil
+	}
+	return s.insertLot(ctx, tx, userID, 0, balance-tracked)
+}
+
+// consumeLots 按到期时间先进先出扣减批次剩余，调用方需已锁定账户行。
+// ledgerID 非 0 时把每个批次的消耗记入 points_lot_usage（对账修正等无流水的扣减传 0）。
+func consumeLots(ctx context.Context, tx *sql.Tx, userID uint64, amount int64, ledgerID uint64) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, remaining
+		FROM points_lot
+		WHERE user_id = ? AND remaining > 0
+		ORDER BY expires_at ASC, id ASC
+		FOR UPDATE
+	`, userID)
+	if err != nil {
+		return err
+	}
+	type lot struct {
+		id        uint64
+		remaining int64
+	}
+	lots := make([]lot, 0, 8)
+	for rows.Next() {
+		var l lot
+		if err := rows.Scan(&l.id, &l.remaining); err != nil {
+			rows.Close()
+			return err
+		}
+		lots = append(lots, l)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	for _, l := range lots {
+		if amount <= 0 {
+			break
+		}
+		take := l.remaining
+		if take > amount {
+			take = amount
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE points_lot SET remaining = remaining - ?, updated_at = NOW() WHERE id = ?
+		`, take, l.id); err != nil {
+			return err
+		}
+		if ledgerID != 0 {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO points_lot_usage (ledger_id, lot_id, amount, created_at) VALUES (?, ?, ?, NOW())
+			`, ledgerID, l.id, take); err != nil {
+				return err
+			}
+		}
+		amount -= take
+	}
+	return nil
+}
+
+// ExpireLots 处理已到期且仍有剩余的批次：每个批次一条 EXPIRE 流水（biz_id=批次 ID，可重复执行）。
+func (s *service) ExpireLots(ctx context.Context, limit int) (int, error) {
+	if s.db == nil {
+		return 0, errors.New("database disabled")
+	}
+	if limit <= 0 {
+		limit = 200
+	}
+
+	// 1) 取出到期批次（先读完再逐个处理，避免占用连接）。
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id
+		FROM points_lot
+		WHERE remaining > 0 AND expires_at <= NOW()
+		ORDER BY expires_at ASC, id ASC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+	type expiredLot struct {
+		id     uint64
+		userID uint64
+	}
+	batch := make([]expiredLot, 0, limit)
+	for rows.Next() {
+		var l expiredLot
+		if err := rows.Scan(&l.id, &l.userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, l)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	// 2) 逐个批次在独立事务内扣减。
+	processed := 0
+	for _, l := range batch {
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+		ok, err := s.expireLot(ctx, l.id, l.userID)
+		if err != nil {
+			return processed, err
+		}
+		if ok {
+			processed++
+		}
+	}
+	return processed, nil
+}
+
+// expireLot 扣减一个到期批次的剩余积分；返回 false 表示已被处理（并发实例或期间已被消耗）。
+func (s *service) expireLot(ctx context.Context, lotID, userID uint64) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 1) 先锁账户再锁批次（与 apply 的加锁顺序一致，避免死锁）。
+	var balance int64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT balance FROM points_account WHERE user_id = ? FOR UPDATE
+	`, userID).Scan(&balance); err != nil {
+		return false, err
+	}
+	var remaining int64
+	var earnedAt time.Time
+	if err := tx.QueryRowContext(ctx, `
+		SELECT remaining, earned_at FROM points_lot
+		WHERE id = ? AND user_id = ? AND expires_at <= NOW()
+		FOR UPDATE
+	`, lotID, userID).Scan(&remaining, &earnedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	if remaining <= 0 {
+		return false, nil
+	}
+
+	// 2) 写 EXPIRE 流水并清零批次；余额与批次不一致时最多扣到 0。
+	amount := remaining
+	if amount > balance {
+		amount = balance
+	}
+	if amount > 0 {
+		remark := "积分过期（" + earnedAt.Format("2006-01-02") + " 获得）"
+		if _, err := s.apply(ctx, tx, userID, BizTypeExpire, strconv.FormatUint(lotID, 10), -amount, remark, lotID, nil); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE points_lot SET remaining = 0, updated_at = NOW() WHERE id = ?
+	`, lotID); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
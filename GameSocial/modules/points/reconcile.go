@@ -0,0 +1,399 @@
+package points
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"gamesocial/modules/admin"
+)
+
+// BizTypeReconcile: 对账修正流水（biz_id 为修正时该用户最后一条流水 ID，同一状态重复修正会被幂等键拦截）。
+// 修正流水同时是对账锚点：之后的检查从它的 balance_after 起算，之前的历史问题不再报告。
+const BizTypeReconcile = "RECONCILE"
+
+// 对账问题类型。
+const (
+	// IssueAccountMissing: 有流水但没有 points_account 行。
+	IssueAccountMissing = "ACCOUNT_MISSING"
+	// IssueSumMismatch: 余额快照与按流水推算的余额（最近锚点的 balance_after + 其后变动之和）不一致（如历史上绕过账本直接改余额）。
+	IssueSumMismatch = "SUM_MISMATCH"
+	// IssueLastBalanceMismatch: 最后一条流水的 balance_after 与余额快照不一致。
+	IssueLastBalanceMismatch = "LAST_BALANCE_MISMATCH"
+	// IssueChainBroken: 最近锚点之后相邻流水的 balance_after 不连续（上一条 balance_after + 本条 change_amount != 本条 balance_after）。
+	IssueChainBroken = "CHAIN_BROKEN"
+)
+
+// reconcileBatchSize: 每批对账的用户数。
+const reconcileBatchSize = 500
+
+// maxChainBreaks: 每个用户最多记录的断链条数（避免报告过大）。
+const maxChainBreaks = 20
+
+// ReconcileOptions 对账参数。
+type ReconcileOptions struct {
+	// UserID 非 0 时只对账该用户。
+	UserID uint64
+	// Fix 为 true 时为余额不一致的用户写修正流水（以余额快照为准），并写审计日志。
+	Fix bool
+	// AdminID 为执行修正的管理员（审计日志 admin_id）；定时任务/命令行为 0。
+	AdminID uint64
+}
+
+// ChainBreak 表示一处 balance_after 断链。
+type ChainBreak struct {
+	LedgerID         uint64 `json:"ledgerId"`
+	PrevBalanceAfter int64  `json:"prevBalanceAfter"`
+	ChangeAmount     int64  `json:"changeAmount"`
+	BalanceAfter     int64  `json:"balanceAfter"`
+}
+
+// Mismatch 表示一个用户的对账结果（仅包含有问题的用户）。
+type Mismatch struct {
+	UserID           uint64       `json:"userId"`
+	Balance          int64        `json:"balance"`
+	LedgerSum        int64        `json:"ledgerSum"`
+	LastBalanceAfter int64        `json:"lastBalanceAfter"`
+	LedgerCount      int          `json:"ledgerCount"`
+	Issues           []string     `json:"issues"`
+	ChainBreaks      []ChainBreak `json:"chainBreaks,omitempty"`
+	// Corrected: 已写入修正流水（或补建账户）。
+	Corrected          bool   `json:"corrected"`
+	CorrectionLedgerID uint64 `json:"correctionLedgerId,omitempty"`
+
+	// ExpectedBalance: 按流水推算的余额（最近锚点的 balance_after + 其后变动之和）。
+	ExpectedBalance int64 `json:"expectedBalance"`
+	// AnchorLedgerID: 最近锚点流水 ID（最后一条 RECONCILE 流水；没有时为首条流水，期初余额 = balance_after - change_amount）。
+	AnchorLedgerID uint64 `json:"anchorLedgerId"`
+}
+
+// ReconcileReport 是一次对账的汇总报告。
+type ReconcileReport struct {
+	StartedAt    time.Time  `json:"startedAt"`
+	FinishedAt   time.Time  `json:"finishedAt"`
+	CheckedUsers int        `json:"checkedUsers"`
+	Corrected    int        `json:"corrected"`
+	Mismatches   []Mismatch `json:"mismatches"`
+}
+
+// Reconcile 逐用户比对 points_account.balance 与 points_ledger（从最近锚点起算）：
+// - 锚点为最后一条 RECONCILE 流水，没有时为首条流水（期初余额 = balance_after - change_amount，允许账本上线前已有余额）；
+// - 锚点 balance_after + 其后变动之和、最后一条 balance_after 是否等于余额快照；
+// - 锚点之后的 balance_after 链是否连续。
+// Fix=true 时以余额快照为准写 RECONCILE 修正流水作为新锚点（仅断链时写 0 变动的锚点；缺账户时按推算余额补建账户），
+// 历史断链无法改写，锚点之前的问题不再报告。
+func (s *service) Reconcile(ctx context.Context, opts ReconcileOptions) (ReconcileReport, error) {
+	if s.db == nil {
+		return ReconcileReport{}, errors.New("database disabled")
+	}
+	report := ReconcileReport{StartedAt: time.Now(), Mismatches: make([]Mismatch, 0)}
+
+	// 1) 按 user_id 游标分批：账户表与流水表中出现过的用户都要检查。
+	var cursor uint64
+	for {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		userIDs, err := s.reconcileUserBatch(ctx, cursor, opts.UserID)
+		if err != nil {
+			return report, err
+		}
+		if len(userIDs) == 0 {
+			break
+		}
+		cursor = userIDs[len(userIDs)-1]
+
+		// 2) 逐用户检查，有问题的按需修正。
+		for _, userID := range userIDs {
+			m, ok, err := s.checkUser(ctx, userID)
+			if err != nil {
+				return report, err
+			}
+			report.CheckedUsers++
+			if !ok {
+				continue
+			}
+			if opts.Fix {
+				if err := s.correctUser(ctx, &m, opts.AdminID); err != nil {
+					return report, err
+				}
+				if m.Corrected {
+					report.Corrected++
+				}
+			}
+			report.Mismatches = append(report.Mismatches, m)
+		}
+		if opts.UserID != 0 || len(userIDs) < reconcileBatchSize {
+			break
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// reconcileUserBatch 返回 user_id > cursor 的下一批用户 ID。
+func (s *service) reconcileUserBatch(ctx context.Context, cursor, onlyUserID uint64) ([]uint64, error) {
+	if onlyUserID != 0 {
+		return []uint64{onlyUserID}, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id FROM (
+			SELECT user_id FROM points_account WHERE user_id > ?
+			UNION
+			SELECT DISTINCT user_id FROM points_ledger WHERE user_id > ?
+		) t
+		ORDER BY user_id ASC
+		LIMIT ?
+	`, cursor, cursor, reconcileBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]uint64, 0, reconcileBatchSize)
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// checkUser 在只读事务（一致性快照）内检查一个用户；返回 ok=true 表示有问题。
+func (s *service) checkUser(ctx context.Context, userID uint64) (Mismatch, bool, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return Mismatch{}, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	m := Mismatch{UserID: userID, Issues: make([]string, 0, 2)}
+
+	// 1) 余额快照。
+	hasAccount := true
+	if err := tx.QueryRowContext(ctx, `
+		SELECT balance FROM points_account WHERE user_id = ?
+	`, userID).Scan(&m.Balance); err != nil {
+		if err != sql.ErrNoRows {
+			return Mismatch{}, false, err
+		}
+		hasAccount = false
+	}
+
+	// 2) 按 id 顺序遍历流水：累加变动，并从最近锚点起检查 balance_after 链。
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, change_amount, balance_after, biz_type
+		FROM points_ledger
+		WHERE user_id = ?
+		ORDER BY id ASC
+	`, userID)
+	if err != nil {
+		return Mismatch{}, false, err
+	}
+	defer rows.Close()
+
+	var prev int64
+	for rows.Next() {
+		var id uint64
+		var change, after int64
+		var bizType string
+		if err := rows.Scan(&id, &change, &after, &bizType); err != nil {
+			return Mismatch{}, false, err
+		}
+		switch {
+		case m.LedgerCount == 0 || bizType == BizTypeReconcile:
+			// 锚点：从它的 balance_after 重新起算，之前的断链已被修正覆盖。
+			m.AnchorLedgerID = id
+			m.ExpectedBalance = after
+			m.ChainBreaks = nil
+		default:
+			if prev+change != after && len(m.ChainBreaks) < maxChainBreaks {
+				m.ChainBreaks = append(m.ChainBreaks, ChainBreak{LedgerID: id, PrevBalanceAfter: prev, ChangeAmount: change, BalanceAfter: after})
+			}
+			m.ExpectedBalance += change
+		}
+		m.LedgerSum += change
+		m.LedgerCount++
+		m.LastBalanceAfter = after
+		prev = after
+	}
+	if err := rows.Err(); err != nil {
+		return Mismatch{}, false, err
+	}
+
+	// 3) 汇总问题。
+	if !hasAccount {
+		if m.LedgerCount == 0 {
+			return m, false, nil
+		}
+		m.Issues = append(m.Issues, IssueAccountMissing)
+	} else {
+		if m.ExpectedBalance != m.Balance {
+			m.Issues = append(m.Issues, IssueSumMismatch)
+		}
+		if m.LedgerCount > 0 && m.LastBalanceAfter != m.Balance {
+			m.Issues = append(m.Issues, IssueLastBalanceMismatch)
+		}
+	}
+	if len(m.ChainBreaks) > 0 {
+		m.Issues = append(m.Issues, IssueChainBroken)
+	}
+	return m, len(m.Issues) > 0, nil
+}
+
+// correctUser 在锁定账户后重新计算差额并修正：
+// - 缺账户：按流水推算的余额补建账户；
+// - 余额与推算余额不一致：以余额快照为准写一条 RECONCILE 流水（change=余额-推算余额，balance_after=余额），余额不变；
+// - 只有断链：写一条 0 变动的 RECONCILE 流水作为新锚点，之后的对账不再报告锚点之前的断链；
+// - 同时把批次剩余之和对齐到余额。
+// 修正与审计日志同事务提交。
+func (s *service) correctUser(ctx context.Context, m *Mismatch, adminID uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 1) 缺账户时按推算余额补建（不会覆盖并发创建的账户）。
+	expected, _, err := expectedBalance(ctx, tx, m.UserID)
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `
+		INSERT IGNORE INTO points_account (user_id, balance, updated_at) VALUES (?, ?, NOW())
+	`, m.UserID, expected)
+	if err != nil {
+		return err
+	}
+	created, _ := res.RowsAffected()
+
+	// 2) 锁定账户，在锁内重新计算（对账读取后可能已有新的积分变动）。
+	var balance int64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT balance FROM points_account WHERE user_id = ? FOR UPDATE
+	`, m.UserID).Scan(&balance); err != nil {
+		return err
+	}
+	expected, lastID, err := expectedBalance(ctx, tx, m.UserID)
+	if err != nil {
+		return err
+	}
+
+	// 3) 写修正流水：以余额快照为准（历史上绕过账本的扣减已真实发生）；只有断链时写 0 变动的锚点。
+	delta := balance - expected
+	var ledgerID uint64
+	if delta != 0 || (len(m.ChainBreaks) > 0 && lastID != 0) {
+		res, err := tx.ExecContext(ctx, `
+			INSERT IGNORE INTO points_ledger (user_id, change_amount, balance_after, biz_type, biz_id, remark, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, NOW())
+		`, m.UserID, delta, balance, BizTypeReconcile, strconv.FormatUint(lastID, 10), reconcileRemark(delta))
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			id, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			ledgerID = uint64(id)
+		}
+	}
+
+	// 4) 批次剩余之和对齐余额：不足补建批次，超出按先进先出扣掉。
+	if err := s.backfillLot(ctx, tx, m.UserID, balance); err != nil {
+		return err
+	}
+	var tracked int64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT IFNULL(SUM(remaining), 0) FROM points_lot WHERE user_id = ?
+	`, m.UserID).Scan(&tracked); err != nil {
+		return err
+	}
+	if tracked > balance {
+		if err := consumeLots(ctx, tx, m.UserID, tracked-balance, 0); err != nil {
+			return err
+		}
+	}
+
+	if created == 0 && ledgerID == 0 {
+		// 锁内复核已一致（或修正流水已存在）：无需修正流水与审计，但仍提交第 4 步的批次对齐。
+		return tx.Commit()
+	}
+
+	// 5) 审计日志（定时任务/命令行执行时 admin_id 为空）。
+	detail := map[string]any{
+		"user_id":        m.UserID,
+		"issues":         m.Issues,
+		"balance":        balance,
+		"expected":       expected,
+		"delta":          delta,
+		"account_create": created == 1,
+	}
+	if ledgerID != 0 {
+		detail["ledger_id"] = ledgerID
+	}
+	if err := admin.RecordAuditTx(ctx, tx, admin.AuditEntry{
+		AdminID: adminID,
+		Action:  "POINTS_RECONCILE",
+		BizType: "USER",
+		BizID:   strconv.FormatUint(m.UserID, 10),
+		Detail:  detail,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.Corrected = true
+	m.CorrectionLedgerID = ledgerID
+	return nil
+}
+
+// expectedBalance 按流水推算余额：最近锚点（最后一条 RECONCILE 流水，没有时为首条流水）的 balance_after
+// 加上其后的变动之和；同时返回最后一条流水 ID（无流水时均为 0）。
+func expectedBalance(ctx context.Context, tx *sql.Tx, userID uint64) (int64, uint64, error) {
+	var anchorID uint64
+	var base int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, balance_after FROM points_ledger
+		WHERE user_id = ? AND biz_type = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`, userID, BizTypeReconcile).Scan(&anchorID, &base)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx, `
+			SELECT id, balance_after FROM points_ledger WHERE user_id = ? ORDER BY id ASC LIMIT 1
+		`, userID).Scan(&anchorID, &base)
+	}
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	var sum int64
+	var lastID uint64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT IFNULL(SUM(change_amount), 0), IFNULL(MAX(id), 0) FROM points_ledger WHERE user_id = ? AND id > ?
+	`, userID, anchorID).Scan(&sum, &lastID); err != nil {
+		return 0, 0, err
+	}
+	if lastID == 0 {
+		lastID = anchorID
+	}
+	return base + sum, lastID, nil
+}
+
+// reconcileRemark 返回修正流水的备注。
+func reconcileRemark(delta int64) string {
+	if delta == 0 {
+		return "对账锚点：流水断链"
+	}
+	return "对账修正：余额与流水不一致"
+}
@@ -1,5 +1,320 @@
-// points 模块负责积分账户、流水账本、幂等等业务能力（后续实现）。
+// points 模块负责积分账户、流水账本、幂等等业务能力。
 package points
 
-// Service 定义 points 模块对外提供的业务接口（后续补充具体方法）。
-type Service interface{}
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 业务类型（points_ledger.biz_type）：与 biz_id 组成幂等键。
+const (
+	BizTypeRedeem = "REDEEM"
+	// BizTypeRedeemRefund: 兑换订单取消/超时未取货退回积分（biz_id 为订单号）。
+	BizTypeRedeemRefund = "REDEEM_REFUND"
+	// BizTypeExpire: 积分批次到期扣减（biz_id 为批次 ID）。
+	BizTypeExpire = "EXPIRE"
+)
+
+// mysqlErrDupEntry 是 MySQL 唯一键冲突错误码。
+const mysqlErrDupEntry = 1062
+
+// 对外暴露的业务错误（handler 直接返回给前端）。
+var (
+	ErrInsufficientPoints = errors.New("积分不足")
+	ErrInvalidAmount      = errors.New("积分数量必须大于 0")
+	ErrAccountNotFound    = errors.New("积分账户不存在")
+)
+
+// maxRemarkRunes: points_ledger.remark 列长度上限。
+const maxRemarkRunes = 255
+
+// Account 对应数据库 points_account 表的数据结构。
+type Account struct {
+	UserID    uint64     `json:"userId"`
+	Balance   int64      `json:"balance"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	// ExpiringPoints/ExpiringDate: 最近一个到期日将过期的积分（没有待过期积分时为 0/空）。
+	ExpiringPoints int64  `json:"expiringPoints"`
+	ExpiringDate   string `json:"expiringDate,omitempty"`
+}
+
+// Ledger 对应数据库 points_ledger 表的数据结构。
+type Ledger struct {
+	ID           uint64    `json:"id"`
+	UserID       uint64    `json:"userId"`
+	ChangeAmount int64     `json:"changeAmount"`
+	BalanceAfter int64     `json:"balanceAfter"`
+	BizType      string    `json:"bizType"`
+	BizID        string    `json:"bizId"`
+	Remark       string    `json:"remark"`
+	CreatedAt    time.Time `json:"createdAt"`
+	// 赠送流水（TRANSFER_IN/TRANSFER_OUT）的对方用户与赠言，仅 ListLedgers 返回。
+	CounterpartyUserID   uint64 `json:"counterpartyUserId,omitempty"`
+	CounterpartyNickname string `json:"counterpartyNickname,omitempty"`
+	TransferMessage      string `json:"transferMessage,omitempty"`
+	// Replayed: 幂等键已存在，本次调用未改动余额，返回的是首次写入的流水。
+	Replayed bool `json:"-"`
+}
+
+// Config 是 points 模块的业务配置。
+type Config struct {
+	// AdjustApprovalThreshold: 管理员调整绝对值超过该值需另一名管理员审批。
+	AdjustApprovalThreshold int64
+	// ExpireMonths: 积分获得后多少个月过期（按批次先进先出消耗）。
+	ExpireMonths int
+
+	// 用户间赠送限制（0 表示不限制）：
+	// - TransferDailyLimit/TransferDailyCount: 每人每天最多赠出的积分/次数
+	// - TransferDailyReceiveLimit: 每人每天最多受赠的积分
+	// - TransferMinAccountDays: 注册满多少天才能赠出
+	TransferDailyLimit        int64
+	TransferDailyCount        int
+	TransferDailyReceiveLimit int64
+	TransferMinAccountDays    int
+}
+
+// Service 定义 points 模块对外提供的业务接口。
+// Credit/Debit 在调用方的事务内执行：锁定账户行、写流水（含 balance_after）并更新余额快照，
+// 调用方负责提交或回滚；同一 (userID, bizType, bizID) 重复调用视为成功并返回首次写入的流水。
+type Service interface {
+	Get(ctx context.Context, userID uint64) (Account, error)
+	// ListLedgers 查询用户流水（筛选 + 游标分页）；MonthlySummary 按业务类型汇总某月获得/消耗。
+	ListLedgers(ctx context.Context, userID uint64, q LedgerQuery) (LedgerPage, error)
+	MonthlySummary(ctx context.Context, userID uint64, month string) (MonthlySummary, error)
+	Credit(ctx context.Context, tx *sql.Tx, userID uint64, bizType, bizID string, amount int64, remark string) (Ledger, error)
+	Debit(ctx context.Context, tx *sql.Tx, userID uint64, bizType, bizID string, amount int64, remark string) (Ledger, error)
+	// Refund 退回一笔扣减（debitBizType/debitBizID 为原扣减流水的幂等键）：退回的批次沿用原扣减消耗批次的到期时间。
+	Refund(ctx context.Context, tx *sql.Tx, userID uint64, bizType, bizID string, amount int64, remark, debitBizType, debitBizID string) (Ledger, error)
+	// ExpireLots 扣减已到期批次的剩余积分（写 EXPIRE 流水），返回处理的批次数。
+	ExpireLots(ctx context.Context, limit int) (int, error)
+	// Reconcile 比对余额快照与流水账本（可选写修正流水）。
+	Reconcile(ctx context.Context, opts ReconcileOptions) (ReconcileReport, error)
+	// Transfer 用户间赠送积分（一个事务内写赠送单与转出/转入两条流水）。
+	Transfer(ctx context.Context, fromUserID uint64, req TransferRequest) (Transfer, error)
+
+	// 管理员积分调整（超过阈值需审批）。
+	Adjust(ctx context.Context, adminID uint64, req AdjustRequest) (Adjustment, error)
+	ApproveAdjustment(ctx context.Context, adminID, id uint64, note string) (Adjustment, error)
+	RejectAdjustment(ctx context.Context, adminID, id uint64, note string) (Adjustment, error)
+	ListAdjustments(ctx context.Context, req ListAdjustmentRequest) ([]Adjustment, error)
+}
+
+type service struct {
+	db  *sql.DB
+	cfg Config
+}
+
+// NewService 创建 points 模块服务。
+func NewService(db *sql.DB, cfg Config) Service {
+	if cfg.ExpireMonths <= 0 {
+		cfg.ExpireMonths = 12
+	}
+	return &service{db: db, cfg: cfg}
+}
+
+// Get 查询用户积分账户；尚未开户时返回余额 0。
+func (s *service) Get(ctx context.Context, userID uint64) (Account, error) {
+	if s.db == nil {
+		return Account{}, errors.New("database disabled")
+	}
+	if userID == 0 {
+		return Account{}, errors.New("invalid userId")
+	}
+
+	a := Account{UserID: userID}
+	var updatedAt time.Time
+	err := s.db.QueryRowContext(ctx, `
+		SELECT balance, updated_at
+		FROM points_account
+		WHERE user_id = ?
+		LIMIT 1
+	`, userID).Scan(&a.Balance, &updatedAt)
+	if err == sql.ErrNoRows {
+		return a, nil
+	}
+	if err != nil {
+		return Account{}, err
+	}
+	a.UpdatedAt = &updatedAt
+
+	// 最近一个到期日（按自然日汇总）的待过期积分。
+	var expiringDate sql.NullString
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT IFNULL(SUM(remaining), 0), MIN(DATE_FORMAT(expires_at, '%Y-%m-%d'))
+		FROM points_lot
+		WHERE user_id = ? AND remaining > 0 AND expires_at > NOW()
+			AND DATE(expires_at) = (
+				SELECT MIN(DATE(expires_at)) FROM points_lot
+				WHERE user_id = ? AND remaining > 0 AND expires_at > NOW()
+			)
+	`, userID, userID).Scan(&a.ExpiringPoints, &expiringDate); err != nil {
+		return Account{}, err
+	}
+	a.ExpiringDate = expiringDate.String
+	return a, nil
+}
+
+// Credit 增加积分。
+func (s *service) Credit(ctx context.Context, tx *sql.Tx, userID uint64, bizType, bizID string, amount int64, remark string) (Ledger, error) {
+	if amount <= 0 {
+		return Ledger{}, ErrInvalidAmount
+	}
+	return s.apply(ctx, tx, userID, bizType, bizID, amount, remark, 0, nil)
+}
+
+// Debit 扣减积分；余额不足返回 ErrInsufficientPoints。
+func (s *service) Debit(ctx context.Context, tx *sql.Tx, userID uint64, bizType, bizID string, amount int64, remark string) (Ledger, error) {
+	if amount <= 0 {
+		return Ledger{}, ErrInvalidAmount
+	}
+	return s.apply(ctx, tx, userID, bizType, bizID, -amount, remark, 0, nil)
+}
+
+// Refund 退回积分：按原扣减流水的批次消耗明细恢复原到期时间（已过期的部分会在下次到期处理时扣除），
+// 退回不会延长有效期；原流水不存在或没有消耗明细（明细表上线前的扣减）时按新批次入账。
+func (s *service) Refund(ctx context.Context, tx *sql.Tx, userID uint64, bizType, bizID string, amount int64, remark, debitBizType, debitBizID string) (Ledger, error) {
+	if amount <= 0 {
+		return Ledger{}, ErrInvalidAmount
+	}
+	if tx == nil {
+		return Ledger{}, errors.New("transaction required")
+	}
+	var parts []lotPart
+	debit, err := findLedger(ctx, tx, userID, debitBizType, debitBizID)
+	switch {
+	case err == nil:
+		if parts, err = lotUsage(ctx, tx, debit.ID); err != nil {
+			return Ledger{}, err
+		}
+	case err != sql.ErrNoRows:
+		return Ledger{}, err
+	}
+	return s.apply(ctx, tx, userID, bizType, bizID, amount, remark, 0, parts)
+}
+
+// apply 在事务内记一笔流水：change 为正表示增加（生成新批次），为负表示扣减（按到期时间先进先出消耗批次）。
+// expireLotID 非 0 时只扣减该批次（到期处理）；增加时 parts 非空则新批次沿用其中的到期时间（赠送转入、退回）。
+func (s *service) apply(ctx context.Context, tx *sql.Tx, userID uint64, bizType, bizID string, change int64, remark string, expireLotID uint64, parts []lotPart) (Ledger, error) {
+	// 1) 基础校验。
+	if tx == nil {
+		return Ledger{}, errors.New("transaction required")
+	}
+	if userID == 0 {
+		return Ledger{}, errors.New("invalid userId")
+	}
+	if bizType == "" || bizID == "" {
+		return Ledger{}, errors.New("bizType/bizId is empty")
+	}
+
+	// 2) 确保账户存在并锁定账户行：同一用户的积分变动在此串行化。
+	if _, err := tx.ExecContext(ctx, `
+		INSERT IGNORE INTO points_account (user_id, balance, updated_at)
+		VALUES (?, 0, NOW())
+	`, userID); err != nil {
+		return Ledger{}, err
+	}
+	var balance int64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT balance FROM points_account WHERE user_id = ? FOR UPDATE
+	`, userID).Scan(&balance); err != nil {
+		if err == sql.ErrNoRows {
+			return Ledger{}, ErrAccountNotFound
+		}
+		return Ledger{}, err
+	}
+
+	// 3) 幂等：同一业务单号已记账则直接返回原流水（重放不受当前余额影响）。
+	if l, err := findLedger(ctx, tx, userID, bizType, bizID); err == nil {
+		return l, nil
+	} else if err != sql.ErrNoRows {
+		return Ledger{}, err
+	}
+
+	// 4) 历史余额（批次上线前的积分）补一个批次，保证批次剩余之和与余额一致。
+	if err := s.backfillLot(ctx, tx, userID, balance); err != nil {
+		return Ledger{}, err
+	}
+
+	// 5) 计算变动后余额，拒绝透支。
+	after := balance + change
+	if after < 0 {
+		return Ledger{}, ErrInsufficientPoints
+	}
+
+	// 6) 写流水；唯一键冲突说明并发写入了同一业务单号，按重放处理。
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO points_ledger (user_id, change_amount, balance_after, biz_type, biz_id, remark, created_at)
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), NOW())
+	`, userID, change, after, bizType, bizID, truncateRemark(remark))
+	if err != nil {
+		var myErr *mysql.MySQLError
+		if errors.As(err, &myErr) && myErr.Number == mysqlErrDupEntry {
+			return findLedger(ctx, tx, userID, bizType, bizID)
+		}
+		return Ledger{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Ledger{}, err
+	}
+
+	// 7) 更新余额快照。
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE points_account SET balance = ?, updated_at = NOW() WHERE user_id = ?
+	`, after, userID); err != nil {
+		return Ledger{}, err
+	}
+
+	// 8) 维护批次：增加生成新批次；扣减消耗批次并记录消耗明细。
+	switch {
+	case change > 0:
+		err = s.insertLots(ctx, tx, userID, uint64(id), change, parts)
+	case expireLotID != 0:
+		_, err = tx.ExecContext(ctx, `
+			UPDATE points_lot SET remaining = 0, updated_at = NOW() WHERE id = ? AND user_id = ?
+		`, expireLotID, userID)
+	default:
+		err = consumeLots(ctx, tx, userID, -change, uint64(id))
+	}
+	if err != nil {
+		return Ledger{}, err
+	}
+
+	return Ledger{
+		ID:           uint64(id),
+		UserID:       userID,
+		ChangeAmount: change,
+		BalanceAfter: after,
+		BizType:      bizType,
+		BizID:        bizID,
+		Remark:       truncateRemark(remark),
+		CreatedAt:    time.Now(),
+	}, nil
+}
+
+// findLedger 按幂等键读取已有流水（Replayed=true）；不存在时返回 sql.ErrNoRows。
+func findLedger(ctx context.Context, tx *sql.Tx, userID uint64, bizType, bizID string) (Ledger, error) {
+	l := Ledger{Replayed: true}
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, user_id, change_amount, balance_after, biz_type, biz_id, IFNULL(remark, ''), created_at
+		FROM points_ledger
+		WHERE user_id = ? AND biz_type = ? AND biz_id = ?
+		LIMIT 1
+	`, userID, bizType, bizID).Scan(&l.ID, &l.UserID, &l.ChangeAmount, &l.BalanceAfter, &l.BizType, &l.BizID, &l.Remark, &l.CreatedAt)
+	if err != nil {
+		return Ledger{}, err
+	}
+	return l, nil
+}
+
+func truncateRemark(s string) string {
+	r := []rune(s)
+	if len(r) <= maxRemarkRunes {
+		return s
+	}
+	return string(r[:maxRemarkRunes])
+}
@@ -0,0 +1,272 @@
+package points
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 用户间积分赠送的流水业务类型：同一笔赠送的两条流水 biz_id 相同（均为赠送单号 transfer_no）。
+const (
+	BizTypeTransferOut = "TRANSFER_OUT"
+	BizTypeTransferIn  = "TRANSFER_IN"
+)
+
+// maxTransferMessageRunes: points_transfer.message 列长度上限。
+const maxTransferMessageRunes = 64
+
+// 用户状态（user.status）：0=禁用。
+const userStatusDisabled = 0
+
+// 积分赠送的业务错误。
+var (
+	ErrTransferSelf                 = errors.New("不能给自己赠送积分")
+	ErrTransferSenderDisabled       = errors.New("账号已被禁用，无法赠送积分")
+	ErrTransferRecipientUnavailable = errors.New("对方账号不可用")
+	ErrTransferDailyCount           = errors.New("今日赠送次数已达上限")
+	ErrTransferRecipientDailyLimit  = errors.New("对方今日受赠额度已满")
+	ErrTransferMessageTooLong       = errors.New("赠言不能超过 64 个字")
+)
+
+// Transfer 对应数据库 points_transfer 表的数据结构。
+type Transfer struct {
+	ID          uint64    `json:"id"`
+	TransferNo  string    `json:"transferNo"`
+	FromUserID  uint64    `json:"fromUserId"`
+	ToUserID    uint64    `json:"toUserId"`
+	Amount      int64     `json:"amount"`
+	Message     string    `json:"message"`
+	OutLedgerID uint64    `json:"outLedgerId"`
+	InLedgerID  uint64    `json:"inLedgerId"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// TransferRequest 用户赠送积分入参。
+type TransferRequest struct {
+	ToUserID uint64 `json:"toUserId"`
+	Amount   int64  `json:"amount"`
+	// Message 赠言（可为空，最多 64 个字），受赠方在流水中可见。
+	Message string `json:"message"`
+}
+
+// transferParty 是赠送双方的用户信息。
+type transferParty struct {
+	ID        uint64
+	Nickname  string
+	Status    int
+	CreatedAt time.Time
+}
+
+// displayName 返回流水备注中展示的用户名（未设置昵称时用用户 ID）。
+func (p transferParty) displayName() string {
+	if p.Nickname != "" {
+		return p.Nickname
+	}
+	return "用户" + strconv.FormatUint(p.ID, 10)
+}
+
+// Transfer 在一个事务内把积分从 fromUserID 转给 req.ToUserID：
+// 写赠送单 + 转出/转入两条流水（biz_id 均为赠送单号），任一步失败整体回滚。
+// 校验：双方未被禁用、转出方注册满 TransferMinAccountDays 天、当日赠送次数/额度与对方当日受赠额度。
+func (s *service) Transfer(ctx context.Context, fromUserID uint64, req TransferRequest) (Transfer, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return Transfer{}, errors.New("database disabled")
+	}
+	if fromUserID == 0 {
+		return Transfer{}, errors.New("invalid userId")
+	}
+	if req.ToUserID == 0 {
+		return Transfer{}, errors.New("toUserId is empty")
+	}
+	if req.ToUserID == fromUserID {
+		return Transfer{}, ErrTransferSelf
+	}
+	if req.Amount <= 0 {
+		return Transfer{}, ErrInvalidAmount
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if utf8.RuneCountInString(req.Message) > maxTransferMessageRunes {
+		return Transfer{}, ErrTransferMessageTooLong
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Transfer{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 2) 读取双方用户并校验状态/注册时长。
+	from, err := getTransferParty(ctx, tx, fromUserID)
+	if err != nil {
+		return Transfer{}, err
+	}
+	to, err := getTransferParty(ctx, tx, req.ToUserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return Transfer{}, ErrTransferRecipientUnavailable
+		}
+		return Transfer{}, err
+	}
+	if from.Status == userStatusDisabled {
+		return Transfer{}, ErrTransferSenderDisabled
+	}
+	if to.Status == userStatusDisabled {
+		return Transfer{}, ErrTransferRecipientUnavailable
+	}
+	if days := s.cfg.TransferMinAccountDays; days > 0 && time.Since(from.CreatedAt) < time.Duration(days)*24*time.Hour {
+		return Transfer{}, fmt.Errorf("注册满 %d 天后才能赠送积分", days)
+	}
+
+	// 3) 按 user_id 顺序锁定双方账户：同一用户的赠送在此串行化，相向赠送也不会死锁。
+	if err := lockAccounts(ctx, tx, fromUserID, req.ToUserID); err != nil {
+		return Transfer{}, err
+	}
+
+	// 4) 当日限额（在账户锁内统计，并发请求不会同时通过）。
+	if err := s.checkTransferLimits(ctx, tx, fromUserID, req.ToUserID, req.Amount); err != nil {
+		return Transfer{}, err
+	}
+
+	// 5) 写赠送单，拿到单号作为两条流水的 biz_id。
+	transferNo, err := newTransferNo()
+	if err != nil {
+		return Transfer{}, err
+	}
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO points_transfer (transfer_no, from_user_id, to_user_id, amount, message, created_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NOW())
+	`, transferNo, fromUserID, req.ToUserID, req.Amount, req.Message)
+	if err != nil {
+		return Transfer{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Transfer{}, err
+	}
+
+	// 6) 转出方扣减、转入方增加（流水备注写明对方）；转入的批次沿用转出方被消耗批次的到期时间，赠送不会延长有效期。
+	out, err := s.Debit(ctx, tx, fromUserID, BizTypeTransferOut, transferNo, req.Amount, "赠送给 "+to.displayName())
+	if err != nil {
+		return Transfer{}, err
+	}
+	parts, err := lotUsage(ctx, tx, out.ID)
+	if err != nil {
+		return Transfer{}, err
+	}
+	in, err := s.apply(ctx, tx, req.ToUserID, BizTypeTransferIn, transferNo, req.Amount, "来自 "+from.displayName()+" 的赠送", 0, parts)
+	if err != nil {
+		return Transfer{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE points_transfer SET out_ledger_id = ?, in_ledger_id = ? WHERE id = ?
+	`, out.ID, in.ID, id); err != nil {
+		return Transfer{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Transfer{}, err
+	}
+	return Transfer{
+		ID:          uint64(id),
+		TransferNo:  transferNo,
+		FromUserID:  fromUserID,
+		ToUserID:    req.ToUserID,
+		Amount:      req.Amount,
+		Message:     req.Message,
+		OutLedgerID: out.ID,
+		InLedgerID:  in.ID,
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// checkTransferLimits 校验转出方当日赠送次数/额度与转入方当日受赠额度（0 表示不限制）。
+func (s *service) checkTransferLimits(ctx context.Context, tx *sql.Tx, fromUserID, toUserID uint64, amount int64) error {
+	var sentCount int
+	var sentAmount int64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*), IFNULL(SUM(amount), 0)
+		FROM points_transfer
+		WHERE from_user_id = ? AND created_at >= CURDATE()
+	`, fromUserID).Scan(&sentCount, &sentAmount); err != nil {
+		return err
+	}
+	if s.cfg.TransferDailyCount > 0 && sentCount >= s.cfg.TransferDailyCount {
+		return ErrTransferDailyCount
+	}
+	if limit := s.cfg.TransferDailyLimit; limit > 0 && sentAmount+amount > limit {
+		left := limit - sentAmount
+		if left < 0 {
+			left = 0
+		}
+		return fmt.Errorf("超出今日赠送额度，今日还可赠送 %d 积分", left)
+	}
+
+	if limit := s.cfg.TransferDailyReceiveLimit; limit > 0 {
+		var received int64
+		if err := tx.QueryRowContext(ctx, `
+			SELECT IFNULL(SUM(amount), 0)
+			FROM points_transfer
+			WHERE to_user_id = ? AND created_at >= CURDATE()
+		`, toUserID).Scan(&received); err != nil {
+			return err
+		}
+		if received+amount > limit {
+			return ErrTransferRecipientDailyLimit
+		}
+	}
+	return nil
+}
+
+// getTransferParty 读取赠送方/受赠方的用户信息；不存在时返回 ErrUserNotFound。
+func getTransferParty(ctx context.Context, q queryer, userID uint64) (transferParty, error) {
+	p := transferParty{ID: userID}
+	err := q.QueryRowContext(ctx, `
+		SELECT IFNULL(nickname, ''), status, created_at FROM user WHERE id = ? LIMIT 1
+	`, userID).Scan(&p.Nickname, &p.Status, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return transferParty{}, ErrUserNotFound
+	}
+	if err != nil {
+		return transferParty{}, err
+	}
+	return p, nil
+}
+
+// lockAccounts 确保账户存在并按 user_id 升序加行锁。
+func lockAccounts(ctx context.Context, tx *sql.Tx, a, b uint64) error {
+	if a > b {
+		a, b = b, a
+	}
+	for _, userID := range []uint64{a, b} {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT IGNORE INTO points_account (user_id, balance, updated_at)
+			VALUES (?, 0, NOW())
+		`, userID); err != nil {
+			return err
+		}
+		var balance int64
+		if err := tx.QueryRowContext(ctx, `
+			SELECT balance FROM points_account WHERE user_id = ? FOR UPDATE
+		`, userID).Scan(&balance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTransferNo() (string, error) {
+	// 赠送单号规则：T + yyyymmddhhmmss + 4 字节随机数（hex）。
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "T" + time.Now().Format("20060102150405") + hex.EncodeToString(buf), nil
+}
@@ -64,6 +64,9 @@ type Service interface {
 	Create(ctx context.Context, req CreateRequest) (QRCode, error)
 	Verify(ctx context.Context, token string) (media.QRPayload, error)
 	Use(ctx context.Context, uid uint64, req UseRequest) (UseResult, error)
+	// Consume 由服务端（如管理员扫码）核销一次性二维码：校验 token 与用途后把 ACTIVE 置为 USED。
+	// tx 非 nil 时在调用方事务内执行（与业务单据状态变更一起提交或回滚）。
+	Consume(ctx context.Context, tx *sql.Tx, token, purpose string) (UseResult, error)
 }
 
 type service struct {
@@ -283,6 +286,54 @@ func (s *service) Use(ctx context.Context, uid uint64, req UseRequest) (UseResul
 	return out, nil
 }
 
+func (s *service) Consume(ctx context.Context, tx *sql.Tx, token, purpose string) (UseResult, error) {
+	if s.db == nil {
+		return UseResult{}, errors.New("database disabled")
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return UseResult{}, errors.New("token is empty")
+	}
+
+	payload, err := s.Verify(ctx, token)
+	if err != nil {
+		return UseResult{}, err
+	}
+	if !strings.EqualFold(payload.Type, purpose) {
+		return UseResult{}, errors.New("qrcode purpose mismatch")
+	}
+
+	// 只有一次性二维码可被核销；条件更新保证并发扫码只有一个成功。
+	exec := s.db.ExecContext
+	if tx != nil {
+		exec = tx.ExecContext
+	}
+	now := time.Now()
+	res, err := exec(ctx, `
+		UPDATE qr_code
+		SET status = 'USED', used_at = ?, updated_at = NOW()
+		WHERE uuid = ? AND status = 'ACTIVE'
+	`, now, payload.UUID)
+	if err != nil {
+		return UseResult{}, err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return UseResult{}, errors.New("qrcode already used")
+	}
+
+	return UseResult{
+		UUID:      payload.UUID,
+		Type:      payload.Type,
+		Scene:     payload.Scene,
+		UserID:    payload.UserID,
+		Data:      payload.Data,
+		IssuedAt:  time.Unix(payload.IssuedAt, 0),
+		ExpiresAt: time.Unix(payload.ExpiresAt, 0),
+		UsedAt:    &now,
+		OneTime:   true,
+	}, nil
+}
+
 func nullableUint64(v uint64) any {
 	if v == 0 {
 		return nil
@@ -0,0 +1,230 @@
+package redeem
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// 超时汇总的参数错误。
+var (
+	ErrInvalidDate     = errors.New("日期格式错误，应为 yyyy-MM-dd")
+	ErrDateRangeTooBig = errors.New("查询范围不能超过 92 天")
+)
+
+// expiredSummaryMaxDays 是超时汇总单次查询的最大天数。
+const expiredSummaryMaxDays = 92
+
+// ExpiredDaySummary 是某一天超时关闭订单的汇总。
+type ExpiredDaySummary struct {
+	Date         string              `json:"date"`
+	OrderCount   int                 `json:"orderCount"`
+	UserCount    int                 `json:"userCount"`
+	RefundPoints int64               `json:"refundPoints"`
+	Goods        []ExpiredGoodsCount `json:"goods"`
+}
+
+// ExpiredGoodsCount 是某一天超时回补库存的商品数量。
+type ExpiredGoodsCount struct {
+	GoodsID   uint64 `json:"goodsId"`
+	GoodsName string `json:"goodsName"`
+	Quantity  int    `json:"quantity"`
+}
+
+// ExpireOrders 关闭已过取货期限的 CREATED 订单（-> EXPIRED）：每个订单独立事务内回补库存并退回积分
+// （REDEEM_REFUND 流水，biz_id=订单号，与取消共用幂等键，不会重复退款）；可重复执行。
+func (s *service) ExpireOrders(ctx context.Context, limit int) (int, error) {
+	if s.db == nil {
+		return 0, errors.New("database disabled")
+	}
+	if s.points == nil {
+		return 0, errors.New("points service not configured")
+	}
+	if limit <= 0 {
+		limit = 200
+	}
+
+	// 1) 取出到期订单（先读完再逐个处理，避免占用连接）。
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id
+		FROM redeem_order
+		WHERE status = 'CREATED' AND expires_at <= NOW()
+		ORDER BY expires_at ASC, id ASC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]uint64, 0, limit)
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	// 2) 逐个订单在独立事务内关闭。
+	processed := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+		ok, err := s.expireOrder(ctx, id)
+		if err != nil {
+			return processed, err
+		}
+		if ok {
+			processed++
+		}
+	}
+	return processed, nil
+}
+
+// expireOrder 关闭一个到期订单；返回 false 表示已被处理（期间被核销/取消，或并发实例已关闭）。
+func (s *service) expireOrder(ctx context.Context, id uint64) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 1) 锁定订单并复核状态与期限（与核销/取消互斥）。
+	var orderNo string
+	var ownerID uint64
+	var total int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT order_no, user_id, total_points
+		FROM redeem_order
+		WHERE id = ? AND status = 'CREATED' AND expires_at <= NOW()
+		FOR UPDATE
+	`, id).Scan(&orderNo, &ownerID, &total)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// 2) 改状态并记录关闭时间。
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE redeem_order SET status = 'EXPIRED', expired_at = NOW() WHERE id = ? AND status = 'CREATED'
+	`, id); err != nil {
+		return false, err
+	}
+
+	// 3) 回补库存并退回积分。
+	if err := s.refundOrder(ctx, tx, id, ownerID, orderNo, total, "兑换超时未取货退回 "+orderNo); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ExpiredSummary 按天汇总超时关闭的订单（按 expired_at 所在自然日，含首尾）：
+// 订单数、涉及用户数、退回积分与各商品回补数量；日期为空时默认最近 7 天，范围内无数据的日期也会返回（数量为 0）。
+func (s *service) ExpiredSummary(ctx context.Context, startDate, endDate string) ([]ExpiredDaySummary, error) {
+	// 1) 基础校验与日期范围兜底。
+	if s.db == nil {
+		return nil, errors.New("database disabled")
+	}
+	end := time.Now()
+	if endDate != "" {
+		t, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			return nil, ErrInvalidDate
+		}
+		end = t
+	}
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, 0, -6)
+	if startDate != "" {
+		t, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			return nil, ErrInvalidDate
+		}
+		start = t
+	}
+	if start.After(end) {
+		return nil, errors.New("startDate 不能晚于 endDate")
+	}
+	if end.Sub(start) >= expiredSummaryMaxDays*24*time.Hour {
+		return nil, ErrDateRangeTooBig
+	}
+
+	// 2) 预先生成每一天的汇总行。
+	days := make([]ExpiredDaySummary, 0, int(end.Sub(start).Hours()/24)+1)
+	index := make(map[string]int)
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		index[key] = len(days)
+		days = append(days, ExpiredDaySummary{Date: key, Goods: make([]ExpiredGoodsCount, 0)})
+	}
+	from := start.Format("2006-01-02 15:04:05")
+	to := end.AddDate(0, 0, 1).Format("2006-01-02 15:04:05")
+
+	// 3) 订单维度：订单数/用户数/退回积分。
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DATE_FORMAT(expired_at, '%Y-%m-%d') AS d, COUNT(*), COUNT(DISTINCT user_id), IFNULL(SUM(total_points), 0)
+		FROM redeem_order
+		WHERE status = 'EXPIRED' AND expired_at >= ? AND expired_at < ?
+		GROUP BY d
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day string
+		var it ExpiredDaySummary
+		if err := rows.Scan(&day, &it.OrderCount, &it.UserCount, &it.RefundPoints); err != nil {
+			return nil, err
+		}
+		if i, ok := index[day]; ok {
+			days[i].OrderCount = it.OrderCount
+			days[i].UserCount = it.UserCount
+			days[i].RefundPoints = it.RefundPoints
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 4) 商品维度：各商品回补数量（按数量倒序）。
+	goodsRows, err := s.db.QueryContext(ctx, `
+		SELECT DATE_FORMAT(o.expired_at, '%Y-%m-%d') AS d, i.goods_id, IFNULL(g.name, ''), SUM(i.quantity) AS qty
+		FROM redeem_order o
+		JOIN redeem_order_item i ON i.redeem_order_id = o.id
+		LEFT JOIN goods g ON g.id = i.goods_id
+		WHERE o.status = 'EXPIRED' AND o.expired_at >= ? AND o.expired_at < ?
+		GROUP BY d, i.goods_id, g.name
+		ORDER BY d, qty DESC, i.goods_id
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer goodsRows.Close()
+	for goodsRows.Next() {
+		var day string
+		var g ExpiredGoodsCount
+		if err := goodsRows.Scan(&day, &g.GoodsID, &g.GoodsName, &g.Quantity); err != nil {
+			return nil, err
+		}
+		if i, ok := index[day]; ok {
+			days[i].Goods = append(days[i].Goods, g)
+		}
+	}
+	if err := goodsRows.Err(); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
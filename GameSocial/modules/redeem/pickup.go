@@ -0,0 +1,145 @@
+package redeem
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gamesocial/modules/qrcode"
+)
+
+// QRPurposeRedeemUse 是兑换订单取货二维码的用途（qr_code.purpose）。
+const QRPurposeRedeemUse = "REDEEM_USE"
+
+// 取货二维码的业务错误。
+var (
+	ErrOrderNotPickable = errors.New("订单不是待取货状态")
+	ErrPickupQRInvalid  = errors.New("取货码无效")
+	ErrPickupQRMismatch = errors.New("取货码与订单用户不一致")
+
+	// ErrOrderPickupExpired 表示订单已过取货期限（等待定时任务关闭并退款）。
+	ErrOrderPickupExpired = errors.New("订单已过取货期限")
+)
+
+// pickupQRData 是取货二维码 Data 中携带的订单信息。
+type pickupQRData struct {
+	OrderID uint64 `json:"orderId"`
+	OrderNo string `json:"orderNo"`
+}
+
+// CreatePickupQR 为用户自己的待取货（CREATED）订单生成一次性取货二维码：
+// 绑定用户、用途 REDEEM_USE、Data 携带订单号，有效期 PickupQRTTLSeconds。
+func (s *service) CreatePickupQR(ctx context.Context, id uint64, userID uint64) (qrcode.QRCode, error) {
+	// 1) 基础校验。
+	if s.qr == nil {
+		return qrcode.QRCode{}, errors.New("qrcode service not configured")
+	}
+	if userID == 0 {
+		return qrcode.QRCode{}, errors.New("userId is empty")
+	}
+
+	// 2) 只能为自己的 CREATED 订单生成。
+	o, err := s.GetOrder(ctx, id, userID)
+	if err != nil {
+		return qrcode.QRCode{}, err
+	}
+	if o.Status != "CREATED" {
+		return qrcode.QRCode{}, ErrOrderNotPickable
+	}
+	if o.ExpiresAt != nil && !o.ExpiresAt.After(time.Now()) {
+		return qrcode.QRCode{}, ErrOrderPickupExpired
+	}
+
+	// 3) 生成一次性二维码（每次请求生成新码，旧码到期自然失效）。
+	data, err := json.Marshal(pickupQRData{OrderID: o.ID, OrderNo: o.OrderNo})
+	if err != nil {
+		return qrcode.QRCode{}, err
+	}
+	return s.qr.Create(ctx, qrcode.CreateRequest{
+		Type:       QRPurposeRedeemUse,
+		UserID:     userID,
+		TTLSeconds: s.cfg.PickupQRTTLSeconds,
+		OneTime:    true,
+		Data:       data,
+	})
+}
+
+// UseOrderByQR 管理员扫描取货二维码核销订单：
+// 校验 token（签名/有效期/用途）与订单归属后，在同一事务内核销二维码并把订单置为 USED（记录扫码管理员），
+// 任一步失败整体回滚，不会出现二维码已用而订单未核销。
+func (s *service) UseOrderByQR(ctx context.Context, token string, adminID uint64) (RedeemOrder, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return RedeemOrder{}, errors.New("database disabled")
+	}
+	if s.qr == nil {
+		return RedeemOrder{}, errors.New("qrcode service not configured")
+	}
+	if adminID == 0 {
+		return RedeemOrder{}, errors.New("invalid adminId")
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return RedeemOrder{}, errors.New("token is empty")
+	}
+
+	// 2) 解密校验 token，取出订单号。
+	payload, err := s.qr.Verify(ctx, token)
+	if err != nil {
+		return RedeemOrder{}, err
+	}
+	if !strings.EqualFold(payload.Type, QRPurposeRedeemUse) {
+		return RedeemOrder{}, ErrPickupQRInvalid
+	}
+	var data pickupQRData
+	if err := json.Unmarshal(payload.Data, &data); err != nil || data.OrderNo == "" {
+		return RedeemOrder{}, ErrPickupQRInvalid
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RedeemOrder{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 3) 锁定订单（与取消/超时关闭互斥）：须属于二维码绑定的用户，且仍待取货、未过取货期限。
+	var id, userID uint64
+	var status string
+	var overdue bool
+	if err := tx.QueryRowContext(ctx, `
+		SELECT id, user_id, status, IFNULL(expires_at <= NOW(), 0)
+		FROM redeem_order
+		WHERE order_no = ?
+		LIMIT 1
+		FOR UPDATE
+	`, data.OrderNo).Scan(&id, &userID, &status, &overdue); err != nil {
+		if err == sql.ErrNoRows {
+			return RedeemOrder{}, ErrPickupQRInvalid
+		}
+		return RedeemOrder{}, err
+	}
+	if payload.UserID == 0 || payload.UserID != userID {
+		return RedeemOrder{}, ErrPickupQRMismatch
+	}
+	if status != "CREATED" {
+		return RedeemOrder{}, ErrOrderNotPickable
+	}
+	if overdue {
+		return RedeemOrder{}, ErrOrderPickupExpired
+	}
+
+	// 4) 同一事务内核销二维码（一次性，并发扫码只有一个成功）与订单。
+	if _, err := s.qr.Consume(ctx, tx, token, QRPurposeRedeemUse); err != nil {
+		return RedeemOrder{}, err
+	}
+	if err := markOrderUsed(ctx, tx.ExecContext, id, adminID); err != nil {
+		return RedeemOrder{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return RedeemOrder{}, err
+	}
+	return s.GetOrder(ctx, id, 0)
+}
@@ -8,9 +8,24 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
+
+	"gamesocial/modules/points"
+	"gamesocial/modules/qrcode"
+)
+
+// 下单时的商品校验错误（handler 直接返回给前端）。
+var (
+	ErrGoodsNotFound   = errors.New("商品不存在")
+	ErrGoodsOffShelf   = errors.New("商品已下架")
+	ErrGoodsOutOfStock = errors.New("商品库存不足")
 )
 
+// goodsStatusOnShelf: goods.status 上架。
+const goodsStatusOnShelf = 1
+
 // RedeemOrder 对应数据库 redeem_order 表的数据结构。
 type RedeemOrder struct {
 	ID            uint64            `json:"id"`
@@ -22,6 +37,10 @@ type RedeemOrder struct {
 	UsedAt        *time.Time        `json:"usedAt,omitempty"`
 	CreatedAt     time.Time         `json:"createdAt"`
 	Items         []RedeemOrderItem `json:"items,omitempty"`
+
+	// ExpiresAt: 取货截止时间（为空表示不限期）；ExpiredAt: 超时关闭时间（status=EXPIRED 时有值）。
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	ExpiredAt *time.Time `json:"expiredAt,omitempty"`
 }
 
 // RedeemOrderItem 对应数据库 redeem_order_item 表的数据结构。
@@ -33,17 +52,17 @@ type RedeemOrderItem struct {
 	PointsPrice   int64  `json:"pointsPrice"`
 }
 
-// CreateOrderRequest 创建兑换订单入参（这里是最小 CRUD：不接入积分扣减流水）。
+// CreateOrderRequest 创建兑换订单入参（下单时通过 points 模块扣减积分并写流水）。
 type CreateOrderRequest struct {
 	UserID uint64                 `json:"userId"`
 	Items  []CreateOrderItemInput `json:"items"`
 }
 
 // CreateOrderItemInput 表示创建订单时的单条兑换明细入参。
+// 单价以下单时 goods.points_price 为准，不接受前端传入。
 type CreateOrderItemInput struct {
-	GoodsID     uint64 `json:"goodsId"`
-	Quantity    int    `json:"quantity"`
-	PointsPrice int64  `json:"pointsPrice"`
+	GoodsID  uint64 `json:"goodsId"`
+	Quantity int    `json:"quantity"`
 }
 
 // ListOrderRequest 查询订单列表入参。
@@ -61,15 +80,37 @@ type Service interface {
 	ListOrders(ctx context.Context, req ListOrderRequest) ([]RedeemOrder, error)
 	UseOrder(ctx context.Context, id uint64, adminID uint64) (RedeemOrder, error)
 	CancelOrder(ctx context.Context, id uint64, userID uint64) (RedeemOrder, error)
+
+	// 超时未取货：定时任务关闭过期订单（退回积分并回补库存），管理员按天查看汇总。
+	ExpireOrders(ctx context.Context, limit int) (int, error)
+	ExpiredSummary(ctx context.Context, startDate, endDate string) ([]ExpiredDaySummary, error)
+
+	// 取货二维码：用户为待取货订单生成一次性二维码，管理员扫码核销。
+	CreatePickupQR(ctx context.Context, id uint64, userID uint64) (qrcode.QRCode, error)
+	UseOrderByQR(ctx context.Context, token string, adminID uint64) (RedeemOrder, error)
+}
+
+// Config 是 redeem 模块的业务配置。
+type Config struct {
+	// PickupQRTTLSeconds: 取货二维码有效期（秒）。
+	PickupQRTTLSeconds int64
+	// PickupWindowHours: 全局取货期限（小时），商品未单独配置时使用；0 表示不限期。
+	PickupWindowHours int
 }
 
 type service struct {
-	db *sql.DB
+	db     *sql.DB
+	points points.Service
+	qr     qrcode.Service
+	cfg    Config
 }
 
-// NewService 创建 redeem 模块服务。
-func NewService(db *sql.DB) Service {
-	return &service{db: db}
+// NewService 创建 redeem 模块服务；积分扣减统一走 points 模块，取货二维码走 qrcode 模块（未配置时为 nil）。
+func NewService(db *sql.DB, pointsSvc points.Service, qrSvc qrcode.Service, cfg Config) Service {
+	if cfg.PickupQRTTLSeconds <= 0 {
+		cfg.PickupQRTTLSeconds = 120
+	}
+	return &service{db: db, points: pointsSvc, qr: qrSvc, cfg: cfg}
 }
 
 // CreateOrder 创建兑换订单并返回订单详情（包含 items）。
@@ -78,6 +119,9 @@ func (s *service) CreateOrder(ctx context.Context, req CreateOrderRequest) (Rede
 	if s.db == nil {
 		return RedeemOrder{}, errors.New("database disabled")
 	}
+	if s.points == nil {
+		return RedeemOrder{}, errors.New("points service not configured")
+	}
 	if req.UserID == 0 {
 		return RedeemOrder{}, errors.New("userId is empty")
 	}
@@ -85,8 +129,9 @@ func (s *service) CreateOrder(ctx context.Context, req CreateOrderRequest) (Rede
 		return RedeemOrder{}, errors.New("items is empty")
 	}
 
-	// 2) 计算总积分：sum(points_price * quantity)。
-	var total int64
+	// 2) 校验明细并按商品合并数量（同一商品多行视为一行）。
+	qty := make(map[uint64]int, len(req.Items))
+	goodsIDs := make([]uint64, 0, len(req.Items))
 	for _, it := range req.Items {
 		if it.GoodsID == 0 {
 			return RedeemOrder{}, errors.New("goodsId is empty")
@@ -94,38 +139,53 @@ func (s *service) CreateOrder(ctx context.Context, req CreateOrderRequest) (Rede
 		if it.Quantity <= 0 {
 			return RedeemOrder{}, errors.New("quantity must be > 0")
 		}
-		if it.PointsPrice < 0 {
-			return RedeemOrder{}, errors.New("pointsPrice must be >= 0")
+		if _, ok := qty[it.GoodsID]; !ok {
+			goodsIDs = append(goodsIDs, it.GoodsID)
 		}
-		total += int64(it.Quantity) * it.PointsPrice
-	}
-	//判断用户积分是否足够
-	var userPoints int64
-	if err := s.db.QueryRowContext(ctx, `
-		SELECT balance FROM points_account WHERE user_id = ? FOR UPDATE
-	`, req.UserID).Scan(&userPoints); err != nil {
-		return RedeemOrder{}, err
-	}
-	if userPoints < total {
-		return RedeemOrder{}, errors.New("积分不足")
+		qty[it.GoodsID] += it.Quantity
 	}
-	// 3) 开启事务：订单表与明细表还有余额表需要同时成功写入。
+
+	// 3) 开启事务：库存、订单、明细与积分流水需要同时成功写入。
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return RedeemOrder{}, err
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	// 4) 按 id 顺序锁定商品行，读取服务端价格/库存/状态并计算总积分与取货期限。
+	goods, err := lockGoods(ctx, tx, goodsIDs, qty)
+	if err != nil {
+		return RedeemOrder{}, err
+	}
+	var total int64
+	for _, goodsID := range goodsIDs {
+		total += int64(qty[goodsID]) * goods[goodsID].price
+	}
+	window := s.pickupWindow(goods)
+
+	// 5) 扣减库存（条件更新兜底，库存不足整单回滚）。
+	for _, goodsID := range goodsIDs {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE goods SET stock = stock - ? WHERE id = ? AND stock >= ?
+		`, qty[goodsID], goodsID, qty[goodsID])
+		if err != nil {
+			return RedeemOrder{}, err
+		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			return RedeemOrder{}, ErrGoodsOutOfStock
+		}
+	}
+
 	orderNo, err := newOrderNo()
 	if err != nil {
 		return RedeemOrder{}, err
 	}
 
-	// 4) 写入 redeem_order（初始状态 CREATED）。
+	// 6) 写入 redeem_order（初始状态 CREATED；不限期时 expires_at 为 NULL）。
 	res, err := tx.ExecContext(ctx, `
-		INSERT INTO redeem_order (order_no, user_id, status, total_points, used_by_admin_id, used_at, created_at)
-		VALUES (?, ?, 'CREATED', ?, NULL, NULL, NOW())
-	`, orderNo, req.UserID, total)
+		INSERT INTO redeem_order (order_no, user_id, status, total_points, used_by_admin_id, used_at, expires_at, created_at)
+		VALUES (?, ?, 'CREATED', ?, NULL, NULL, NOW() + INTERVAL ? HOUR, NOW())
+	`, orderNo, req.UserID, total, window)
 	if err != nil {
 		return RedeemOrder{}, err
 	}
@@ -134,31 +194,105 @@ func (s *service) CreateOrder(ctx context.Context, req CreateOrderRequest) (Rede
 		return RedeemOrder{}, err
 	}
 
-	// 5) 写入 redeem_order_item。
-	for _, it := range req.Items {
+	// 7) 写入 redeem_order_item（单价为下单时的商品价格快照）。
+	for _, goodsID := range goodsIDs {
 		if _, err := tx.ExecContext(ctx, `
 			INSERT INTO redeem_order_item (redeem_order_id, goods_id, quantity, points_price)
 			VALUES (?, ?, ?, ?)
-		`, id, it.GoodsID, it.Quantity, it.PointsPrice); err != nil {
+		`, id, goodsID, qty[goodsID], goods[goodsID].price); err != nil {
 			return RedeemOrder{}, err
 		}
 	}
-	// 6) 更新用户积分余额：减去已用积分。
-	if _, err := tx.ExecContext(ctx, `
-		UPDATE points_account SET balance = balance - ? WHERE user_id = ?
-	`, total, req.UserID); err != nil {
-		return RedeemOrder{}, err
+	// 8) 扣减积分：在同一事务内锁定账户并写流水（订单号作为幂等键），余额不足整单回滚。
+	if total > 0 {
+		if _, err := s.points.Debit(ctx, tx, req.UserID, points.BizTypeRedeem, orderNo, total, "积分兑换 "+orderNo); err != nil {
+			return RedeemOrder{}, err
+		}
 	}
 
-	// 7) 提交事务。
+	// 9) 提交事务。
 	if err := tx.Commit(); err != nil {
 		return RedeemOrder{}, err
 	}
 
-	// 8) 返回订单详情（包含 items）。
+	// 10) 返回订单详情（包含 items）。
 	return s.GetOrder(ctx, uint64(id), req.UserID)
 }
 
+// lockedGoods 是下单时锁定的商品信息。
+type lockedGoods struct {
+	price int64
+	// pickupWindowHours: 商品单独配置的取货期限（小时）；NULL 表示使用全局配置。
+	pickupWindowHours sql.NullInt64
+}
+
+// lockGoods 按 id 升序对商品加行锁（并发下单不会死锁），校验存在/上架/库存，返回商品单价与取货期限。
+func lockGoods(ctx context.Context, tx *sql.Tx, goodsIDs []uint64, qty map[uint64]int) (map[uint64]lockedGoods, error) {
+	ids := append([]uint64(nil), goodsIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	marks := make([]string, 0, len(ids))
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		marks = append(marks, "?")
+		args = append(args, id)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, points_price, stock, status, pickup_window_hours
+		FROM goods
+		WHERE id IN (`+strings.Join(marks, ", ")+`)
+		ORDER BY id
+		FOR UPDATE
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[uint64]lockedGoods, len(ids))
+	for rows.Next() {
+		var id uint64
+		var g lockedGoods
+		var stock, status int
+		if err := rows.Scan(&id, &g.price, &stock, &status, &g.pickupWindowHours); err != nil {
+			return nil, err
+		}
+		if status != goodsStatusOnShelf {
+			return nil, ErrGoodsOffShelf
+		}
+		if stock < qty[id] {
+			return nil, ErrGoodsOutOfStock
+		}
+		out[id] = g
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) != len(ids) {
+		return nil, ErrGoodsNotFound
+	}
+	return out, nil
+}
+
+// pickupWindow 计算订单取货期限（小时）：取各商品期限中最短的一个（商品未配置时用全局配置，0 表示不限期）；
+// 全部不限期时返回无效值，expires_at 写 NULL。
+func (s *service) pickupWindow(goods map[uint64]lockedGoods) sql.NullInt64 {
+	var out sql.NullInt64
+	for _, g := range goods {
+		hours := int64(s.cfg.PickupWindowHours)
+		if g.pickupWindowHours.Valid {
+			hours = g.pickupWindowHours.Int64
+		}
+		if hours <= 0 {
+			continue
+		}
+		if !out.Valid || hours < out.Int64 {
+			out = sql.NullInt64{Int64: hours, Valid: true}
+		}
+	}
+	return out
+}
+
 // GetOrder 获取兑换订单详情（包含 items）。
 func (s *service) GetOrder(ctx context.Context, id uint64, userID uint64) (RedeemOrder, error) {
 	// 1) 基础校验。
@@ -172,9 +306,9 @@ func (s *service) GetOrder(ctx context.Context, id uint64, userID uint64) (Redee
 	// 2) 读取订单主表。
 	var o RedeemOrder
 	var usedAdmin sql.NullInt64
-	var usedAt sql.NullTime
+	var usedAt, expiresAt, expiredAt sql.NullTime
 	query := `
-		SELECT id, order_no, user_id, status, total_points, used_by_admin_id, used_at, created_at
+		SELECT id, order_no, user_id, status, total_points, used_by_admin_id, used_at, expires_at, expired_at, created_at
 		FROM redeem_order
 		WHERE id = ?`
 	args := []any{id}
@@ -184,7 +318,7 @@ func (s *service) GetOrder(ctx context.Context, id uint64, userID uint64) (Redee
 	}
 	query += " LIMIT 1"
 	row := s.db.QueryRowContext(ctx, query, args...)
-	if err := row.Scan(&o.ID, &o.OrderNo, &o.UserID, &o.Status, &o.TotalPoints, &usedAdmin, &usedAt, &o.CreatedAt); err != nil {
+	if err := row.Scan(&o.ID, &o.OrderNo, &o.UserID, &o.Status, &o.TotalPoints, &usedAdmin, &usedAt, &expiresAt, &expiredAt, &o.CreatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return RedeemOrder{}, fmt.Errorf("redeem_order not found")
 		}
@@ -197,6 +331,8 @@ func (s *service) GetOrder(ctx context.Context, id uint64, userID uint64) (Redee
 		t := usedAt.Time
 		o.UsedAt = &t
 	}
+	o.ExpiresAt = nullTimePtr(expiresAt)
+	o.ExpiredAt = nullTimePtr(expiredAt)
 
 	// 3) 读取订单明细。
 	rows, err := s.db.QueryContext(ctx, `
@@ -257,7 +393,7 @@ func (s *service) ListOrders(ctx context.Context, req ListOrderRequest) ([]Redee
 
 	// 3) 查询主表列表（不带 items，避免列表请求过重）。
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, order_no, user_id, status, total_points, IFNULL(used_by_admin_id, 0), used_at, created_at
+		SELECT id, order_no, user_id, status, total_points, IFNULL(used_by_admin_id, 0), used_at, expires_at, expired_at, created_at
 		FROM redeem_order
 		`+where+`
 		ORDER BY id DESC
@@ -271,14 +407,16 @@ func (s *service) ListOrders(ctx context.Context, req ListOrderRequest) ([]Redee
 	out := make([]RedeemOrder, 0, req.Limit)
 	for rows.Next() {
 		var o RedeemOrder
-		var usedAt sql.NullTime
-		if err := rows.Scan(&o.ID, &o.OrderNo, &o.UserID, &o.Status, &o.TotalPoints, &o.UsedByAdminID, &usedAt, &o.CreatedAt); err != nil {
+		var usedAt, expiresAt, expiredAt sql.NullTime
+		if err := rows.Scan(&o.ID, &o.OrderNo, &o.UserID, &o.Status, &o.TotalPoints, &o.UsedByAdminID, &usedAt, &expiresAt, &expiredAt, &o.CreatedAt); err != nil {
 			return nil, err
 		}
 		if usedAt.Valid {
 			t := usedAt.Time
 			o.UsedAt = &t
 		}
+		o.ExpiresAt = nullTimePtr(expiresAt)
+		o.ExpiredAt = nullTimePtr(expiredAt)
 		out = append(out, o)
 	}
 	if err := rows.Err(); err != nil {
@@ -297,26 +435,34 @@ func (s *service) UseOrder(ctx context.Context, id uint64, adminID uint64) (Rede
 		return RedeemOrder{}, errors.New("invalid id")
 	}
 	if adminID == 0 {
-		adminID = 1
+		return RedeemOrder{}, errors.New("invalid adminId")
+	}
+
+	// 2) 条件更新：只有未过取货期限的 CREATED 才能被核销为 USED，防止重复核销。
+	if err := markOrderUsed(ctx, s.db.ExecContext, id, adminID); err != nil {
+		return RedeemOrder{}, err
 	}
+	return s.GetOrder(ctx, id, 0)
+}
 
-	// 2) 条件更新：只有 CREATED 才能被核销为 USED，防止重复核销。
-	result, err := s.db.ExecContext(ctx, `
+// markOrderUsed 把未过取货期限的 CREATED 订单置为 USED；exec 为 *sql.DB 或 *sql.Tx 的 ExecContext。
+func markOrderUsed(ctx context.Context, exec func(context.Context, string, ...any) (sql.Result, error), id, adminID uint64) error {
+	result, err := exec(ctx, `
 		UPDATE redeem_order
 		SET status = 'USED', used_by_admin_id = ?, used_at = NOW()
-		WHERE id = ? AND status = 'CREATED'
+		WHERE id = ? AND status = 'CREATED' AND (expires_at IS NULL OR expires_at > NOW())
 	`, adminID, id)
 	if err != nil {
-		return RedeemOrder{}, err
+		return err
 	}
-	affected, _ := result.RowsAffected()
-	if affected == 0 {
-		return RedeemOrder{}, fmt.Errorf("order not found or not creatable")
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("order not found or not creatable")
 	}
-	return s.GetOrder(ctx, id, 0)
+	return nil
 }
 
-// CancelOrder 取消兑换订单（CREATED -> CANCELED）并返回最新订单详情。
+// CancelOrder 取消兑换订单（CREATED -> CANCELED）：同一事务内退回积分（REDEEM_REFUND 流水）并回补库存；
+// 已取消的订单重复取消直接返回订单详情，不会重复退款。
 func (s *service) CancelOrder(ctx context.Context, id uint64, userID uint64) (RedeemOrder, error) {
 	// 1) 基础校验。
 	if s.db == nil {
@@ -326,27 +472,127 @@ func (s *service) CancelOrder(ctx context.Context, id uint64, userID uint64) (Re
 		return RedeemOrder{}, errors.New("invalid id")
 	}
 
-	// 2) 条件更新：只有 CREATED 才能取消。
+	if s.points == nil {
+		return RedeemOrder{}, errors.New("points service not configured")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RedeemOrder{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// 2) 锁定订单：并发取消/核销只有一个能成功。
 	query := `
-		UPDATE redeem_order
-		SET status = 'CANCELED'
-		WHERE id = ? AND status = 'CREATED'`
+		SELECT order_no, user_id, status, total_points
+		FROM redeem_order
+		WHERE id = ?`
 	args := []any{id}
 	if userID != 0 {
 		query += " AND user_id = ?"
 		args = append(args, userID)
 	}
-	result, err := s.db.ExecContext(ctx, query, args...)
-	if err != nil {
+	query += " FOR UPDATE"
+	var orderNo, status string
+	var ownerID uint64
+	var total int64
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&orderNo, &ownerID, &status, &total); err != nil {
+		if err == sql.ErrNoRows {
+			return RedeemOrder{}, fmt.Errorf("order not found or not cancelable")
+		}
 		return RedeemOrder{}, err
 	}
-	affected, _ := result.RowsAffected()
-	if affected == 0 {
+	// 重复取消直接返回订单（退款与回补库存只在首次取消时执行）。
+	if status == "CANCELED" {
+		_ = tx.Rollback()
+		return s.GetOrder(ctx, id, userID)
+	}
+	if status != "CREATED" {
 		return RedeemOrder{}, fmt.Errorf("order not found or not cancelable")
 	}
+
+	// 3) 改状态。
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE redeem_order SET status = 'CANCELED' WHERE id = ? AND status = 'CREATED'
+	`, id); err != nil {
+		return RedeemOrder{}, err
+	}
+
+	// 4) 回补库存并退回积分。
+	if err := s.refundOrder(ctx, tx, id, ownerID, orderNo, total, "兑换取消退回 "+orderNo); err != nil {
+		return RedeemOrder{}, err
+	}
+
+	// 5) 提交事务。
+	if err := tx.Commit(); err != nil {
+		return RedeemOrder{}, err
+	}
 	return s.GetOrder(ctx, id, userID)
 }
 
+// refundOrder 关闭订单时回补库存并退回积分（取消与超时共用）：
+// 按商品 ID 顺序加锁，与下单一致（goods → points_account）；订单号作为退款流水幂等键，重放不会重复入账。
+// 退回的积分沿用下单扣减（REDEEM 流水）消耗批次的到期时间，不会因退款获得新的有效期。
+func (s *service) refundOrder(ctx context.Context, tx *sql.Tx, id, ownerID uint64, orderNo string, total int64, remark string) error {
+	if err := restockOrder(ctx, tx, id); err != nil {
+		return err
+	}
+	if total > 0 {
+		if _, err := s.points.Refund(ctx, tx, ownerID, points.BizTypeRedeemRefund, orderNo, total, remark, points.BizTypeRedeem, orderNo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restockOrder 把订单明细数量加回商品库存。
+func restockOrder(ctx context.Context, tx *sql.Tx, orderID uint64) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT goods_id, SUM(quantity)
+		FROM redeem_order_item
+		WHERE redeem_order_id = ?
+		GROUP BY goods_id
+		ORDER BY goods_id
+	`, orderID)
+	if err != nil {
+		return err
+	}
+	type restock struct {
+		goodsID  uint64
+		quantity int
+	}
+	items := make([]restock, 0, 4)
+	for rows.Next() {
+		var it restock
+		if err := rows.Scan(&it.goodsID, &it.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, it := range items {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE goods SET stock = stock + ? WHERE id = ?
+		`, it.quantity, it.goodsID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nullTimePtr(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := v.Time
+	return &t
+}
+
 func newOrderNo() (string, error) {
 	// 订单号规则：R + yyyymmddhhmmss + 4 字节随机数（hex）。
 	buf := make([]byte, 4)
@@ -0,0 +1,144 @@
+package redeem_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gamesocial/internal/testdb"
+	"gamesocial/modules/points"
+	"gamesocial/modules/redeem"
+)
+
+// TestCreateOrderConcurrentNoOversell 并发下单抢同一商品：成功单数等于库存，其余返回库存不足，
+// 库存扣到 0 且积分流水扣减总额等于成功订单总积分。
+// 依赖真实 MySQL（行锁行为无法用内存替身验证）：未设置 GAMESOCIAL_TEST_DSN 时跳过，写入的数据在测试结束时清理。
+func TestCreateOrderConcurrentNoOversell(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	const (
+		buyers = 10
+		stock  = 3
+		price  = 10
+	)
+	pointsSvc := points.NewService(db, points.Config{})
+	svc := redeem.NewService(db, pointsSvc, nil, redeem.Config{})
+
+	// 1) 准备商品与买家（每人独立账户，避免账户行锁把并发串行化）。
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO goods (name, points_price, stock, status, created_at)
+		VALUES (?, ?, ?, 1, NOW())
+	`, fmt.Sprintf("test-goods-%d", time.Now().UnixNano()), price, stock)
+	if err != nil {
+		t.Fatalf("insert goods: %v", err)
+	}
+	goodsID, _ := res.LastInsertId()
+	t.Cleanup(func() { _, _ = db.Exec(`DELETE FROM goods WHERE id = ?`, goodsID) })
+	userIDs := make([]uint64, buyers)
+	for i := range userIDs {
+		userIDs[i] = newUserWithPoints(t, db, pointsSvc, 100)
+	}
+
+	// 2) 同时发起下单。
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, buyers)
+	orders := make([]redeem.RedeemOrder, buyers)
+	for i := range userIDs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			orders[i], errs[i] = svc.CreateOrder(ctx, redeem.CreateOrderRequest{
+				UserID: userIDs[i],
+				Items:  []redeem.CreateOrderItemInput{{GoodsID: uint64(goodsID), Quantity: 1}},
+			})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	// 3) 成功单数等于库存，其余均为库存不足。
+	var succeeded int
+	var orderTotal int64
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+			orderTotal += orders[i].TotalPoints
+		case errors.Is(err, redeem.ErrGoodsOutOfStock):
+		default:
+			t.Fatalf("CreateOrder(user %d): %v", userIDs[i], err)
+		}
+	}
+	if succeeded != stock {
+		t.Fatalf("succeeded = %d, want %d", succeeded, stock)
+	}
+
+	// 4) 库存扣到 0，积分扣减总额与订单总积分一致。
+	var left int
+	if err := db.QueryRowContext(ctx, `SELECT stock FROM goods WHERE id = ?`, goodsID).Scan(&left); err != nil {
+		t.Fatalf("select stock: %v", err)
+	}
+	if left != 0 {
+		t.Fatalf("stock = %d, want 0", left)
+	}
+	var debited int64
+	for _, userID := range userIDs {
+		var sum int64
+		if err := db.QueryRowContext(ctx, `
+			SELECT IFNULL(SUM(-change_amount), 0) FROM points_ledger WHERE user_id = ? AND biz_type = ?
+		`, userID, points.BizTypeRedeem).Scan(&sum); err != nil {
+			t.Fatalf("sum ledger: %v", err)
+		}
+		debited += sum
+	}
+	if debited != orderTotal || orderTotal != stock*price {
+		t.Fatalf("ledger debited = %d, order total = %d, want %d", debited, orderTotal, stock*price)
+	}
+}
+
+// newUserWithPoints 创建测试用户并发放 amount 积分；测试结束时删除该用户及其订单、积分数据。
+func newUserWithPoints(t *testing.T, db *sql.DB, pointsSvc points.Service, amount int64) uint64 {
+	t.Helper()
+	ctx := context.Background()
+	res, err := db.ExecContext(ctx, `INSERT INTO user (openid) VALUES (?)`, fmt.Sprintf("test-openid-%d", time.Now().UnixNano()))
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	userID := uint64(id)
+	t.Cleanup(func() { deleteUser(db, userID) })
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := pointsSvc.Credit(ctx, tx, userID, "INIT", fmt.Sprintf("test-%d", userID), amount, "测试发放"); err != nil {
+		t.Fatalf("Credit: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	return userID
+}
+
+// deleteUser 按外键依赖顺序删除测试用户的订单、积分与用户行。
+func deleteUser(db *sql.DB, userID uint64) {
+	for _, q := range []string{
+		`DELETE i FROM redeem_order_item i JOIN redeem_order o ON o.id = i.redeem_order_id WHERE o.user_id = ?`,
+		`DELETE FROM redeem_order WHERE user_id = ?`,
+		`DELETE u FROM points_lot_usage u JOIN points_lot l ON l.id = u.lot_id WHERE l.user_id = ?`,
+		`DELETE FROM points_lot WHERE user_id = ?`,
+		`DELETE FROM points_ledger WHERE user_id = ?`,
+		`DELETE FROM points_account WHERE user_id = ?`,
+		`DELETE FROM user WHERE id = ?`,
+	} {
+		_, _ = db.Exec(q, userID)
+	}
+}
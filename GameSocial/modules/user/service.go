@@ -9,8 +9,23 @@ import (
 	"log"
 	"strings"
 	"time"
+
+	"gamesocial/internal/pii"
+	"gamesocial/internal/wechat"
+)
+
+// 手机号绑定相关错误（handler 直接返回 message）。
+var (
+	ErrPhoneDisabled    = errors.New("手机号功能未启用")
+	ErrPhoneCodeInvalid = errors.New("手机号授权已失效，请重新授权")
+	ErrPhoneInvalid     = errors.New("手机号格式不正确")
 )
 
+// PhoneSource 用 getPhoneNumber 的 code 换取手机号（由 wechat.Messenger 实现）。
+type PhoneSource interface {
+	GetPhoneNumber(ctx context.Context, code string) (wechat.PhoneInfo, error)
+}
+
 // User 对应数据库 user 表的数据结构。
 type User struct {
 	ID        uint64    `json:"id,omitempty"`
@@ -23,6 +38,9 @@ type User struct {
 	Exp       int64     `json:"exp,omitempty"`
 	CreatedAt time.Time `json:"createdAt,omitempty"`
 	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+	// Phone: 脱敏后的手机号（如 138****1234）；未绑定为空。库中只存密文与盲索引。
+	Phone        string     `json:"phone,omitempty"`
+	PhoneBoundAt *time.Time `json:"phoneBoundAt,omitempty"`
 }
 
 // UpdateUserRequest 更新用户资料入参（管理员侧可用）。
@@ -37,6 +55,8 @@ type ListUserRequest struct {
 	Offset int `json:"offset"`
 	Limit  int `json:"limit"`
 	Status int `json:"status"`
+	// Phone: 按完整手机号精确查找（走盲索引 phone_hash，不支持模糊匹配）。
+	Phone string `json:"phone"`
 }
 
 // Service 定义 user 模块对外提供的业务接口（用户查询/封禁/更新）。
@@ -44,15 +64,21 @@ type Service interface {
 	Get(ctx context.Context, id uint64) (User, error)
 	List(ctx context.Context, req ListUserRequest) ([]User, error)
 	Update(ctx context.Context, id uint64, req UpdateUserRequest) (User, error)
+	// BindPhone 用小程序 getPhoneNumber 返回的 code 绑定（或更换）手机号。
+	BindPhone(ctx context.Context, id uint64, code string) (User, error)
 }
 
 type service struct {
 	db *sql.DB
+	// phones/cipher 任一为空时手机号功能不可用。
+	phones PhoneSource
+	cipher *pii.Cipher
 }
 
 // NewService 创建 user 模块服务。
-func NewService(db *sql.DB) Service {
-	return &service{db: db}
+// phones 负责向微信换取手机号；cipher 负责手机号落库加密与盲索引。
+func NewService(db *sql.DB, phones PhoneSource, cipher *pii.Cipher) Service {
+	return &service{db: db, phones: phones, cipher: cipher}
 }
 
 // Get 获取用户详情。
@@ -65,20 +91,23 @@ func (s *service) Get(ctx context.Context, id uint64) (User, error) {
 		return User{}, errors.New("invalid id")
 	}
 
-	// 2) 读取单条记录：unionid/nickname/avatar_url 允许为空。
+	// 2) 读取单条记录：unionid/nickname/avatar_url/phone 允许为空。
 	var u User
+	var phoneEnc sql.NullString
+	var phoneBoundAt sql.NullTime
 	row := s.db.QueryRowContext(ctx, `
-		SELECT IFNULL(nickname, ''), IFNULL(avatar_url, ''), level, exp, created_at
+		SELECT IFNULL(nickname, ''), IFNULL(avatar_url, ''), level, exp, phone_enc, phone_bound_at, created_at
 		FROM user
 		WHERE id = ?
 		LIMIT 1
 	`, id)
-	if err := row.Scan(&u.Nickname, &u.AvatarURL, &u.Level, &u.Exp, &u.CreatedAt); err != nil {
+	if err := row.Scan(&u.Nickname, &u.AvatarURL, &u.Level, &u.Exp, &phoneEnc, &phoneBoundAt, &u.CreatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return User{}, fmt.Errorf("user not found")
 		}
 		return User{}, err
 	}
+	s.fillPhone(&u, phoneEnc, phoneBoundAt)
 	log.Printf("user.Get: %+v", u)
 	return u, nil
 }
@@ -100,17 +129,34 @@ func (s *service) List(ctx context.Context, req ListUserRequest) ([]User, error)
 	}
 
 	// 2) 组装筛选条件：默认不过滤状态；如果传了 status 则按 status 过滤。
-	where := ""
-	args := make([]any, 0, 3)
+	conds := make([]string, 0, 2)
+	args := make([]any, 0, 4)
 	if req.Status != 0 {
-		where = "WHERE status = ?"
+		conds = append(conds, "status = ?")
 		args = append(args, req.Status)
 	}
+	// 手机号只存密文，按规范化后的盲索引等值匹配。
+	if phone := strings.TrimSpace(req.Phone); phone != "" {
+		if s.cipher == nil {
+			return nil, ErrPhoneDisabled
+		}
+		normalized := pii.NormalizePhone("", phone)
+		if normalized == "" {
+			return nil, ErrPhoneInvalid
+		}
+		conds = append(conds, "phone_hash = ?")
+		args = append(args, s.cipher.BlindIndex(normalized))
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
 	args = append(args, req.Limit, req.Offset)
 
 	// 3) 查询列表：按 id 倒序，便于后台先看到最近用户。
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, openid, IFNULL(unionid, ''), IFNULL(nickname, ''), IFNULL(avatar_url, ''), status, level, exp, created_at, updated_at
+		SELECT id, openid, IFNULL(unionid, ''), IFNULL(nickname, ''), IFNULL(avatar_url, ''), status, level, exp,
+			phone_enc, phone_bound_at, created_at, updated_at
 		FROM user
 		`+where+`
 		ORDER BY id DESC
@@ -124,9 +170,13 @@ func (s *service) List(ctx context.Context, req ListUserRequest) ([]User, error)
 	out := make([]User, 0, req.Limit)
 	for rows.Next() {
 		var u User
-		if err := rows.Scan(&u.ID, &u.OpenID, &u.UnionID, &u.Nickname, &u.AvatarURL, &u.Status, &u.Level, &u.Exp, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		var phoneEnc sql.NullString
+		var phoneBoundAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.OpenID, &u.UnionID, &u.Nickname, &u.AvatarURL, &u.Status, &u.Level, &u.Exp,
+			&phoneEnc, &phoneBoundAt, &u.CreatedAt, &u.UpdatedAt); err != nil {
 			return nil, err
 		}
+		s.fillPhone(&u, phoneEnc, phoneBoundAt)
 		out = append(out, u)
 	}
 	if err := rows.Err(); err != nil {
@@ -174,5 +224,90 @@ func (s *service) Update(ctx context.Context, id uint64, req UpdateUserRequest)
 	if affected == 0 {
 		return User{}, fmt.Errorf("user not found")
 	}
+
+	// 封禁时吊销该用户全部登录会话，已签发的 token 立即失效。
+	if req.Status != nil && *req.Status == 0 {
+		if _, err := s.db.ExecContext(ctx, `
+			UPDATE user_session
+			SET revoked_at = NOW(), updated_at = NOW()
+			WHERE user_id = ? AND revoked_at IS NULL
+		`, id); err != nil {
+			return User{}, err
+		}
+	}
 	return s.Get(ctx, id)
 }
+
+// BindPhone 绑定手机号：code -> 微信换号 -> 规范化 -> 加密 + 盲索引落库。重复绑定视为更换号码。
+func (s *service) BindPhone(ctx context.Context, id uint64, code string) (User, error) {
+	// 1) 基础校验。
+	if s.db == nil {
+		return User{}, errors.New("database disabled")
+	}
+	if s.phones == nil || s.cipher == nil {
+		return User{}, ErrPhoneDisabled
+	}
+	if id == 0 {
+		return User{}, errors.New("invalid id")
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return User{}, errors.New("code 不能为空")
+	}
+
+	// 2) 向微信换取手机号（code 5 分钟内有效且只能用一次）。
+	info, err := s.phones.GetPhoneNumber(ctx, code)
+	if err != nil {
+		var apiErr *wechat.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrCode == wechat.ErrCodeInvalidPhoneCode {
+			return User{}, ErrPhoneCodeInvalid
+		}
+		return User{}, err
+	}
+	number := info.PurePhoneNumber
+	if number == "" {
+		number = info.PhoneNumber
+	}
+	normalized := pii.NormalizePhone(info.CountryCode, number)
+	if normalized == "" {
+		return User{}, ErrPhoneInvalid
+	}
+
+	// 3) 落库：明文不入库，phone_hash 供后台按号码查找。
+	enc, err := s.cipher.Encrypt(normalized)
+	if err != nil {
+		return User{}, err
+	}
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE user
+		SET phone_enc = ?, phone_hash = ?, phone_bound_at = NOW(), updated_at = NOW()
+		WHERE id = ?
+	`, enc, s.cipher.BlindIndex(normalized), id)
+	if err != nil {
+		return User{}, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return User{}, fmt.Errorf("user not found")
+	}
+	return s.Get(ctx, id)
+}
+
+// fillPhone 解密并脱敏手机号；密钥未配置或解密失败时只返回“已绑定”状态，不暴露密文。
+func (s *service) fillPhone(u *User, phoneEnc sql.NullString, phoneBoundAt sql.NullTime) {
+	if !phoneEnc.Valid || phoneEnc.String == "" {
+		return
+	}
+	if phoneBoundAt.Valid {
+		t := phoneBoundAt.Time
+		u.PhoneBoundAt = &t
+	}
+	if s.cipher == nil {
+		return
+	}
+	phone, err := s.cipher.Decrypt(phoneEnc.String)
+	if err != nil {
+		log.Printf("user %d: decrypt phone: %v", u.ID, err)
+		return
+	}
+	u.Phone = pii.MaskPhone(phone)
+}